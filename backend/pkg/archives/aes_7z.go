@@ -0,0 +1,146 @@
+package archives
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/bodgit/sevenzip"
+)
+
+// the 7zAES coder of sevenzip derives keys in place of coder properties when there is no salt,
+// which breaks the iv of the folder when it is read again, so it is replaced by sevenZipAESReader.
+func init() {
+	sevenzip.RegisterDecompressor(sevenZipAESMethod, sevenzip.Decompressor(newSevenZipAESReader))
+}
+
+var (
+	sevenZipAESMethod = []byte{0x06, 0xf1, 0x07, 0x01}
+
+	errSevenZipAESProps    = errors.New("7zaes: invalid properties")
+	errSevenZipAESPassword = errors.New("7zaes: password is not set")
+	errSevenZipAESClosed   = errors.New("7zaes: already closed")
+)
+
+// sevenZipAESReader decrypts by AES-256-CBC, properties of coder are copied, so folders can be read again.
+type sevenZipAESReader struct {
+	rc     io.ReadCloser
+	cycles int
+	salt   []byte
+	iv     []byte
+	cbc    cipher.BlockMode
+	buf    []byte
+	out    []byte
+}
+
+func newSevenZipAESReader(props []byte, _ uint64, readers []io.ReadCloser) (rc io.ReadCloser, err error) {
+	if len(readers) != 1 || len(props) < 2 || props[0]&0xc0 == 0 {
+		err = errSevenZipAESProps
+		return
+	}
+	saltSize := int(props[0]>>7&1 + props[1]>>4)
+	ivSize := int(props[0]>>6&1 + props[1]&0x0f)
+	if len(props) != 2+saltSize+ivSize {
+		err = errSevenZipAESProps
+		return
+	}
+	reader := &sevenZipAESReader{
+		rc:     readers[0],
+		cycles: int(props[0] & 0x3f),
+		salt:   append([]byte(nil), props[2:2+saltSize]...),
+		iv:     make([]byte, aes.BlockSize),
+		buf:    make([]byte, 64*aes.BlockSize),
+	}
+	copy(reader.iv, props[2+saltSize:])
+	rc = reader
+	return
+}
+
+// Password implements sevenzip.CryptoReadCloser, an empty password is refused,
+// so encrypted folders fail to open without password instead of being decrypted into garbage.
+func (reader *sevenZipAESReader) Password(password string) (err error) {
+	if password == "" {
+		err = errSevenZipAESPassword
+		return
+	}
+	block, blockErr := aes.NewCipher(sevenZipAESKeys.get(password, reader.cycles, reader.salt))
+	if blockErr != nil {
+		err = blockErr
+		return
+	}
+	reader.cbc = cipher.NewCBCDecrypter(block, reader.iv)
+	return
+}
+
+func (reader *sevenZipAESReader) Read(p []byte) (n int, err error) {
+	if reader.rc == nil {
+		err = errSevenZipAESClosed
+		return
+	}
+	if reader.cbc == nil {
+		err = errSevenZipAESPassword
+		return
+	}
+	if len(reader.out) == 0 {
+		m, readErr := io.ReadFull(reader.rc, reader.buf)
+		// the rest less than a block is padding
+		m -= m % aes.BlockSize
+		if m == 0 {
+			if readErr == nil || errors.Is(readErr, io.ErrUnexpectedEOF) {
+				readErr = io.EOF
+			}
+			err = readErr
+			return
+		}
+		reader.cbc.CryptBlocks(reader.buf[:m], reader.buf[:m])
+		reader.out = reader.buf[:m]
+	}
+	n = copy(p, reader.out)
+	reader.out = reader.out[n:]
+	return
+}
+
+func (reader *sevenZipAESReader) Close() (err error) {
+	if reader.rc == nil {
+		err = errSevenZipAESClosed
+		return
+	}
+	err = reader.rc.Close()
+	reader.rc = nil
+	return
+}
+
+// sevenZipAESKeys caches derived keys, since a key is derived each time a folder is read.
+var sevenZipAESKeys = &sevenZipAESKeyCache{keys: make(map[sevenZipAESKeyId][]byte)}
+
+type sevenZipAESKeyId struct {
+	password string
+	cycles   int
+	salt     string
+}
+
+type sevenZipAESKeyCache struct {
+	locker sync.Mutex
+	keys   map[sevenZipAESKeyId][]byte
+}
+
+func (cache *sevenZipAESKeyCache) get(password string, cycles int, salt []byte) (key []byte) {
+	id := sevenZipAESKeyId{password: password, cycles: cycles, salt: string(salt)}
+	cache.locker.Lock()
+	key, has := cache.keys[id]
+	cache.locker.Unlock()
+	if has {
+		return
+	}
+	key = sevenZipAESKey(password, cycles, salt)
+	cache.locker.Lock()
+	// passwords of keyring are tried one by one, the cache is kept small
+	if len(cache.keys) >= 16 {
+		clear(cache.keys)
+	}
+	cache.keys[id] = key
+	cache.locker.Unlock()
+	return
+}
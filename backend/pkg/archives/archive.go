@@ -30,6 +30,7 @@ func New(filename string, src Reader) (file *File, err error) {
 		option:   &Option{},
 		reader:   src,
 		host:     nil,
+		keyring:  nil,
 	}
 	return
 }
@@ -40,6 +41,7 @@ type File struct {
 	option   *Option
 	reader   Reader
 	host     *File
+	keyring  Keyring
 }
 
 func (file *File) Name() string {
@@ -27,29 +27,9 @@ func (file *File) Encrypted(ctx context.Context) (ok bool, err error) {
 		if info.IsDir() {
 			return
 		}
-		switch header := info.Header.(type) {
-		case zip.FileHeader:
-			if header.IsEncrypted() {
-				err = errIsEncrypted
-				return
-			}
-			break
-		case czip.FileHeader:
-			if header.Flags&0x1 == 1 {
-				err = errIsEncrypted
-				return
-			}
-			break
-		case *rardecode.FileHeader:
-			if header.HeaderEncrypted {
-				err = errIsEncrypted
-				return
-			}
-			break
-		case sevenzip.FileHeader:
-			break
-		default:
-			break
+		if headerEncrypted(info.Header) {
+			err = errIsEncrypted
+			return
 		}
 		item, openErr := info.Open()
 		if openErr != nil {
@@ -77,3 +57,29 @@ func (file *File) Encrypted(ctx context.Context) (ok bool, err error) {
 	}
 	return
 }
+
+// headerEncrypted tells whether the entry is encrypted by its header, 7z has no flag in headers.
+func headerEncrypted(header any) bool {
+	switch h := header.(type) {
+	case zip.FileHeader:
+		return h.IsEncrypted()
+	case czip.FileHeader:
+		return h.Flags&0x1 == 1
+	case *rardecode.FileHeader:
+		return h.Encrypted || h.HeaderEncrypted
+	default:
+		return false
+	}
+}
+
+// isPasswordError tells whether err is caused by a missing or wrong password.
+func isPasswordError(err error) bool {
+	var readErr *sevenzip.ReadError
+	if errors.As(err, &readErr) && readErr.Encrypted {
+		return true
+	}
+	return errors.Is(err, ErrPasswordRequired) || errors.Is(err, ErrPasswordInvalid) ||
+		errors.Is(err, rardecode.ErrArchiveEncrypted) || errors.Is(err, rardecode.ErrArchivedFileEncrypted) ||
+		errors.Is(err, rardecode.ErrBadPassword) || errors.Is(err, zip.ErrPassword) ||
+		errors.Is(err, errSevenZipAESPassword)
+}
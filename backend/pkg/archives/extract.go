@@ -7,7 +7,6 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
-	"os"
 	"path/filepath"
 
 	"DarkestDungeonModBoxLite/backend/pkg/archives/pkg/ioutil"
@@ -22,6 +21,7 @@ func (file *File) fork(filename string, r Reader) (sub *File) {
 		option:   file.option,
 		reader:   r,
 		host:     file,
+		keyring:  file.keyring,
 	}
 	return
 }
@@ -64,40 +64,24 @@ var (
 
 type ExtractHandler func(ctx context.Context, entry *Entry) (err error)
 
-func (file *File) Extract(ctx context.Context, handler ExtractHandler) (err error) {
-	if err = file.Validate(ctx); err != nil {
-		return
-	}
-	encrypted, encryptedErr := file.Encrypted(ctx)
-	if encryptedErr != nil {
-		err = encryptedErr
-		return
-	}
-
-	var extractor archives.Extractor
-	if encrypted {
-		path := file.Path()
-		password := file.option.GetPassword(path)
-		extractor, err = file.identify(ctx, password)
-	} else {
-		extractor, err = file.identify(ctx, "")
-	}
-	if err != nil {
-		return
+// Extract walks entries of file and archived entries which are extracted in one pass per archive level,
+// encryption is detected while extracting, then the archive is extracted again with passwords from the start,
+// restart is called with the path of it before, so entries handled under the path can be dropped. restart may be nil.
+// an archived entry is handled before it is extracted, and it is handled again as PasswordInvalid
+// when it can not be read without or with passwords.
+func (file *File) Extract(ctx context.Context, restart func(path string), handler ExtractHandler) (err error) {
+	var restartWalk func()
+	if restart != nil {
+		restartWalk = func() {
+			restart(file.Path())
+		}
 	}
-
-	err = extractor.Extract(ctx, file.reader, func(ctx context.Context, info archives.FileInfo) (err error) {
+	_, err = file.walk(ctx, restartWalk, func(ctx context.Context, info archives.FileInfo, head []byte, body io.Reader) (err error) {
 		filename := filepath.ToSlash(filepath.Join(filepath.Join(file.Path()), info.NameInArchive))
 		// discard
 		if file.option.Discarded(filename) {
 			return
 		}
-		reader, openErr := info.Open()
-		if openErr != nil {
-			err = openErr
-			return
-		}
-		defer reader.Close()
 		// dir
 		if info.IsDir() {
 			err = handler(ctx, &Entry{
@@ -105,157 +89,86 @@ func (file *File) Extract(ctx context.Context, handler ExtractHandler) (err erro
 				archived: false,
 				info:     info.FileInfo,
 				header:   info.Header,
-				reader:   reader,
+				reader:   bytes.NewReader(nil),
 			})
 			if errors.Is(err, ErrSkip) {
 				err = nil
 			}
 			return
 		}
-		// file >>>
-		// header
-		head := make([]byte, 64)
-		headN, headErr := io.ReadFull(reader, head)
-		if headN == 0 {
-			if errors.Is(headErr, io.EOF) {
-				// empty file
-				err = handler(ctx, &Entry{
-					name:     filename,
-					archived: false,
-					info:     info.FileInfo,
-					header:   info.Header,
-					reader:   bytes.NewReader(nil),
-				})
-				return
-			}
-			err = errors.Join(fmt.Errorf("failed to read %s", info.NameInArchive), headErr)
-			return
-		}
-		head = head[:headN]
-		// check archived
-		_, archived := TryValidate(bytes.NewReader(head))
-		if !archived { // not archived
+		// file
+		if _, archived := TryValidate(bytes.NewReader(head)); !archived {
 			err = handler(ctx, &Entry{
 				name:     filename,
 				archived: false,
 				info:     info.FileInfo,
 				header:   info.Header,
-				reader:   ioutil.NewCompositeByteReader(head, reader),
+				reader:   ioutil.NewCompositeByteReader(head, body),
 			})
 			if errors.Is(err, ErrSkip) {
 				err = nil
 			}
 			return
 		}
-
-		// try extract entry
-		var sub *File
-		if info.Size() < 64*1024*1024 { // use memory
-			buf := bytes.NewBuffer(head)
-			cp, cpErr := io.Copy(buf, reader)
-			if cp+int64(headN) != info.Size() {
-				if errors.Is(cpErr, io.EOF) {
-					err = errors.Join(fmt.Errorf("failed to read %s", info.NameInArchive))
-				} else {
-					err = errors.Join(fmt.Errorf("failed to read %s", info.NameInArchive), cpErr)
-				}
-				return
-			}
-			sub = file.fork(info.NameInArchive, bytes.NewReader(buf.Bytes()))
-		} else { // use tmp file
-			// tmp dir
-			tmpDir, createTmpDirErr := os.MkdirTemp("", "DarkestDungeonModBox_archives_*")
-			if createTmpDirErr != nil {
-				err = createTmpDirErr
-				return
-			}
-			defer os.RemoveAll(tmpDir)
-			// tmp file
-			tmpFile, tmpFileErr := os.OpenFile(filepath.Join(tmpDir, info.Name()), os.O_RDONLY|os.O_CREATE|os.O_TRUNC, 0644)
-			if tmpFileErr != nil {
-				err = errors.Join(fmt.Errorf("failed to open %s", filepath.Join(tmpDir, info.Name())), tmpFileErr)
-				return
-			}
-			defer tmpFile.Close()
-			// cp header
-			if _, cpErr := io.Copy(tmpFile, bytes.NewBuffer(head)); cpErr != nil {
-				err = errors.Join(fmt.Errorf("failed to write %s", filepath.Join(tmpDir, info.Name())), cpErr)
-				return
-			}
-			// cp body
-			if _, cpErr := io.Copy(tmpFile, reader); cpErr != nil {
-				err = errors.Join(fmt.Errorf("failed to write %s", filepath.Join(tmpDir, info.Name())), cpErr)
-				return
-			}
-			// fork
-			sub = file.fork(info.NameInArchive, tmpFile)
-		}
-		// validate sub
-		if validateErr := sub.Validate(ctx); validateErr != nil {
-			if errors.Is(validateErr, ErrPasswordRequired) || errors.Is(validateErr, ErrPasswordInvalid) {
-				err = handler(ctx, &Entry{
-					name:            filename,
-					archived:        true,
-					encrypted:       true,
-					passwordInvalid: true,
-					extracted:       true,
-					info:            info.FileInfo,
-					header:          info.Header,
-					reader:          ioutil.NewCompositeByteReader(head, reader),
-				})
-				if errors.Is(err, ErrSkip) {
-					err = nil
-					return
-				}
-			}
-			err = validateErr
-			return
-		}
-		subEncrypted, subEncryptedErr := sub.Encrypted(ctx)
-		if subEncryptedErr != nil {
-			err = subEncryptedErr
-			return
-		}
-		err = handler(ctx, &Entry{
-			name:      filename,
-			archived:  true,
-			encrypted: subEncrypted,
-			extracted: true,
-			info:      info.FileInfo,
-			header:    info.Header,
-			reader:    ioutil.NewCompositeByteReader(head, reader),
-		})
-		if err != nil {
-			if errors.Is(err, ErrSkip) {
-				err = nil
-			}
-			return
-		}
-		if file.option.Extracted(filename) { // extract
-			if err = sub.Extract(ctx, handler); err != nil {
-				if errors.Is(err, ErrSkip) {
-					err = nil
-				}
-				return
-			}
-		}
-		// file <<<
+		err = file.extractArchivedEntry(ctx, filename, info, head, body, restart, handler)
 		return
 	})
+	return
+}
 
-	resetErr := file.reset()
-
+func (file *File) extractArchivedEntry(ctx context.Context, filename string, info archives.FileInfo, head []byte, body io.Reader, restart func(path string), handler ExtractHandler) (err error) {
+	subReader, subCloser, spoolErr := spool(ioutil.NewCompositeByteReader(head, body), info.Name(), info.Size())
+	if spoolErr != nil {
+		err = errors.Join(fmt.Errorf("failed to read %s", info.NameInArchive), spoolErr)
+		return
+	}
+	if subCloser != nil {
+		defer subCloser()
+	}
+	err = handler(ctx, &Entry{
+		name:     filename,
+		archived: true,
+		info:     info.FileInfo,
+		header:   info.Header,
+		reader:   io.NewSectionReader(subReader, 0, info.Size()),
+	})
 	if err != nil {
-		if resetErr != nil {
-			err = errors.Join(err, resetErr)
-			return
+		if errors.Is(err, ErrSkip) {
+			err = nil
 		}
 		return
 	}
-	if resetErr != nil {
-		err = resetErr
+	if !file.option.Extracted(filename) {
 		return
 	}
-
+	sub := file.fork(info.NameInArchive, subReader)
+	subErr := sub.Extract(ctx, restart, handler)
+	if subErr == nil {
+		return
+	}
+	var fileErr FileError
+	if errors.As(subErr, &fileErr) || !(errors.Is(subErr, ErrPasswordRequired) || errors.Is(subErr, ErrPasswordInvalid)) {
+		err = subErr
+		return
+	}
+	// the password of entry is missing or wrong
+	err = handler(ctx, &Entry{
+		name:            filename,
+		archived:        true,
+		encrypted:       true,
+		passwordInvalid: true,
+		extracted:       true,
+		info:            info.FileInfo,
+		header:          info.Header,
+		reader:          io.NewSectionReader(subReader, 0, info.Size()),
+	})
+	if errors.Is(err, ErrSkip) {
+		err = nil
+		return
+	}
+	if err == nil {
+		// so it is not taken as the password of file is wrong
+		err = FileError{Filename: filename, Err: subErr}
+	}
 	return
 }
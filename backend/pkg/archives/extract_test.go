@@ -1,6 +1,7 @@
 package archives_test
 
 import (
+	"bytes"
 	"context"
 	"io"
 	"os"
@@ -36,7 +37,7 @@ func TestFile_Extract(t *testing.T) {
 		`test.zip/test/foo.7z/foo/ZIMIK Arbalest skin.7z/ZIMIK Arbalest skin`: `Arbalest`,
 	}
 
-	err := file.Extract(ctx, func(ctx context.Context, entry *archives.Entry) (err error) {
+	err := file.Extract(ctx, nil, func(ctx context.Context, entry *archives.Entry) (err error) {
 		t.Log(entry.Name(), entry.Info().Name(), entry.Info().Size())
 		if entry.Info().IsDir() {
 			return
@@ -84,3 +85,63 @@ func TestFile_Extract(t *testing.T) {
 		return
 	}
 }
+
+func TestFile_Extract_OnePass(t *testing.T) {
+	for _, format := range []string{".zip", ".7z"} {
+		for _, password := range []string{"", "111"} {
+			name := "test" + format
+			data := fsTestArchive(t, format, password)
+			file, fileErr := archives.New(name, bytes.NewReader(data))
+			if fileErr != nil {
+				t.Fatal(fileErr)
+			}
+			if password != "" {
+				file.SetPassword(password)
+			}
+			file.SetEntryPassword(name+"/mod/inner.7z", "222")
+			restarts := 0
+			contents := make(map[string]string)
+			err := file.Extract(context.Background(), func(path string) {
+				restarts++
+				for filename := range contents {
+					if strings.HasPrefix(filename, path+"/") {
+						delete(contents, filename)
+					}
+				}
+			}, func(ctx context.Context, entry *archives.Entry) (err error) {
+				if entry.Info().IsDir() {
+					return
+				}
+				if ok, _, _, _ := entry.Archived(); ok {
+					file.ExtractedEntry(entry.Name())
+					return
+				}
+				b, readErr := io.ReadAll(entry)
+				if readErr != nil {
+					err = readErr
+					return
+				}
+				contents[entry.Name()] = string(b)
+				return
+			})
+			t.Log(format, password, "restarts", restarts)
+			if err != nil {
+				t.Error(format, password, err)
+				continue
+			}
+			for filename, expect := range map[string]string{
+				name + "/mod/project.xml":                "<project></project>",
+				name + "/mod/skin.zip/skin/arbalest.txt": "arbalest",
+				name + "/mod/inner.7z/inner/readme.txt":  "inner",
+			} {
+				if contents[filename] != expect {
+					t.Error(format, password, filename, "is", contents[filename])
+				}
+			}
+			// the inner 7z is extracted again with its password, and so is the encrypted archive
+			if expect := 1 + len(password)/len("111"); restarts != expect {
+				t.Error(format, password, "restarts", restarts)
+			}
+		}
+	}
+}
@@ -0,0 +1,583 @@
+package archives
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"DarkestDungeonModBoxLite/backend/pkg/archives/pkg/seeker"
+
+	"github.com/bodgit/sevenzip"
+	"github.com/nwaples/rardecode/v2"
+	"github.com/yeka/zip"
+)
+
+// FS
+// a read only file system of an archive, entries are indexed once when it is opened,
+// then files are stat, listed and read at random without extracting the whole archive.
+// entries named as archives (.zip, .7z and .rar) are mounted as dirs,
+// they are indexed at the first time when something under them is accessed.
+type FS struct {
+	ctx      context.Context
+	file     *File
+	password string
+	root     *fsNode
+	nodes    map[string]*fsNode
+	locker   sync.Mutex
+	closers  []func() error
+}
+
+// FS indexes entries of file, password of file is required when headers of it are encrypted.
+// FS must be closed, since mounted archives may be spooled into temp files.
+func (file *File) FS(ctx context.Context) (fsys *FS, err error) {
+	size, sizeErr := seeker.Size(file.reader)
+	if sizeErr != nil {
+		err = sizeErr
+		return
+	}
+	fsys = &FS{
+		ctx:      ctx,
+		file:     file,
+		password: file.option.GetPassword(file.Path()),
+		root: &fsNode{
+			name: path.Base(file.name),
+			dir:  true,
+			size: size,
+			mode: fs.ModeDir | 0o555,
+		},
+		nodes: make(map[string]*fsNode),
+	}
+	format, _ := TryValidate(io.NewSectionReader(file.reader, 0, size))
+	switch format {
+	case "zip":
+		err = fsys.indexZip(size)
+	case "7z":
+		err = fsys.index7z(size)
+	case "rar":
+		err = fsys.indexRar(size)
+	default:
+		err = fmt.Errorf("%s is not supported", file.name)
+	}
+	if err != nil {
+		fsys = nil
+		return
+	}
+	for _, node := range fsys.nodes {
+		node.sort()
+	}
+	fsys.root.sort()
+	return
+}
+
+func (fsys *FS) indexZip(size int64) (err error) {
+	reader, readerErr := zip.NewReader(fsys.file.reader, size)
+	if readerErr != nil {
+		err = readerErr
+		return
+	}
+	for _, f := range reader.File {
+		if f.IsEncrypted() && fsys.password != "" {
+			f.SetPassword(fsys.password)
+		}
+		info := f.FileInfo()
+		fsys.add(f.Name, &fsNode{
+			dir:       info.IsDir(),
+			encrypted: f.IsEncrypted(),
+			size:      info.Size(),
+			mode:      info.Mode(),
+			modTime:   info.ModTime(),
+			header:    f.FileHeader,
+			open:      f.Open,
+		})
+	}
+	return
+}
+
+func (fsys *FS) index7z(size int64) (err error) {
+	reader, readerErr := sevenzip.NewReaderWithPassword(fsys.file.reader, size, fsys.password)
+	if readerErr != nil {
+		err = fsys.failed(".", readerErr, false)
+		return
+	}
+	for _, f := range reader.File {
+		info := f.FileInfo()
+		fsys.add(f.Name, &fsNode{
+			dir:     info.IsDir(),
+			size:    info.Size(),
+			mode:    info.Mode(),
+			modTime: info.ModTime(),
+			header:  f.FileHeader,
+			open:    f.Open,
+		})
+	}
+	return
+}
+
+func (fsys *FS) indexRar(size int64) (err error) {
+	options := []rardecode.Option{rardecode.FileSystem(rarVolumeFS{reader: fsys.file.reader, size: size})}
+	if fsys.password != "" {
+		options = append(options, rardecode.Password(fsys.password))
+	}
+	files, listErr := rardecode.List(rarVolumeName, options...)
+	if listErr != nil {
+		err = fsys.failed(".", listErr, false)
+		return
+	}
+	for _, f := range files {
+		header := f.FileHeader
+		open := f.Open
+		if header.Solid {
+			// solid files depend on the preceding ones, so they are read in sequence
+			open = func() (rc io.ReadCloser, err error) {
+				reader, readerErr := rardecode.NewReader(io.NewSectionReader(fsys.file.reader, 0, size), options[1:]...)
+				if readerErr != nil {
+					err = readerErr
+					return
+				}
+				for {
+					next, nextErr := reader.Next()
+					if nextErr != nil {
+						err = nextErr
+						return
+					}
+					if next.Name == header.Name {
+						rc = io.NopCloser(reader)
+						return
+					}
+				}
+			}
+		}
+		fsys.add(header.Name, &fsNode{
+			dir:       header.IsDir,
+			encrypted: header.Encrypted,
+			size:      header.UnPackedSize,
+			mode:      header.Mode(),
+			modTime:   header.ModificationTime,
+			header:    &header,
+			open:      open,
+		})
+	}
+	return
+}
+
+// add puts node at name, parents of it are added as dirs when they are missing.
+func (fsys *FS) add(name string, node *fsNode) {
+	name = strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(name, "\\", "/")), "/")
+	if name == "" {
+		return
+	}
+	node.name = path.Base(name)
+	if node.dir {
+		node.mode = node.mode | fs.ModeDir
+	} else if isArchiveName(node.name) {
+		node.archived = true
+	}
+	if exist, has := fsys.nodes[name]; has {
+		if !exist.dir {
+			// the latter one wins
+			*exist = *node
+		} else if node.dir {
+			node.children = exist.children
+			*exist = *node
+		}
+		return
+	}
+	fsys.nodes[name] = node
+	parent := fsys.dir(path.Dir(name))
+	parent.children = append(parent.children, node)
+}
+
+func (fsys *FS) dir(name string) (node *fsNode) {
+	if name == "." {
+		node = fsys.root
+		return
+	}
+	if exist, has := fsys.nodes[name]; has && exist.dir {
+		node = exist
+		return
+	}
+	node = &fsNode{
+		name: path.Base(name),
+		dir:  true,
+		mode: fs.ModeDir | 0o555,
+	}
+	fsys.add(name, node)
+	node = fsys.nodes[name]
+	return
+}
+
+func isArchiveName(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".zip", ".7z", ".rar":
+		return true
+	}
+	return false
+}
+
+// Open opens the named file, and dirs and mounted archives can be read by ReadDir of it.
+func (fsys *FS) Open(name string) (file fs.File, err error) {
+	owner, rel, node, lookupErr := fsys.lookup("open", name)
+	if lookupErr != nil {
+		err = lookupErr
+		return
+	}
+	if node.dir || node.archived {
+		children, childrenErr := owner.children(rel, node)
+		if childrenErr != nil {
+			err = &fs.PathError{Op: "open", Path: name, Err: childrenErr}
+			return
+		}
+		file = &fsDir{name: name, node: node, children: children}
+		return
+	}
+	rc, openErr := owner.open(rel, node)
+	if openErr != nil {
+		err = &fs.PathError{Op: "open", Path: name, Err: openErr}
+		return
+	}
+	file = &fsFile{owner: owner, name: name, rel: rel, node: node, rc: rc}
+	return
+}
+
+// ReadDir reads the named dir or archive, entries are sorted by name.
+func (fsys *FS) ReadDir(name string) (entries []fs.DirEntry, err error) {
+	owner, rel, node, lookupErr := fsys.lookup("readdir", name)
+	if lookupErr != nil {
+		err = lookupErr
+		return
+	}
+	if !node.dir && !node.archived {
+		err = &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+		return
+	}
+	children, childrenErr := owner.children(rel, node)
+	if childrenErr != nil {
+		err = &fs.PathError{Op: "readdir", Path: name, Err: childrenErr}
+		return
+	}
+	entries = make([]fs.DirEntry, 0, len(children))
+	for _, child := range children {
+		entries = append(entries, child)
+	}
+	return
+}
+
+// Stat returns info of the named file, archives are dirs and Sys of info is the header in the archive.
+func (fsys *FS) Stat(name string) (info fs.FileInfo, err error) {
+	_, _, node, lookupErr := fsys.lookup("stat", name)
+	if lookupErr != nil {
+		err = lookupErr
+		return
+	}
+	info = node
+	return
+}
+
+// Close closes mounted archives and removes temp files of them.
+func (fsys *FS) Close() (err error) {
+	fsys.locker.Lock()
+	defer fsys.locker.Unlock()
+	for _, node := range fsys.nodes {
+		if node.mounted != nil {
+			err = errors.Join(err, node.mounted.Close())
+			node.mounted = nil
+		}
+	}
+	for _, closer := range fsys.closers {
+		err = errors.Join(err, closer())
+	}
+	fsys.closers = nil
+	return
+}
+
+// lookup finds the node of name, and the fs which owns it, archives in the way are mounted.
+func (fsys *FS) lookup(op string, name string) (owner *FS, rel string, node *fsNode, err error) {
+	if !fs.ValidPath(name) {
+		err = &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+		return
+	}
+	owner, rel, node = fsys, ".", fsys.root
+	if name == "." {
+		return
+	}
+	items := strings.Split(name, "/")
+	for i := 0; i < len(items); i++ {
+		rel = path.Join(items[:i+1]...)
+		node = owner.nodes[rel]
+		if node == nil {
+			err = &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+			return
+		}
+		if i == len(items)-1 {
+			return
+		}
+		if node.archived {
+			sub, mountErr := owner.mount(rel, node)
+			if mountErr != nil {
+				err = &fs.PathError{Op: op, Path: name, Err: mountErr}
+				return
+			}
+			owner, node = sub, sub.root
+			items, i = items[i+1:], -1
+			rel = "."
+		}
+	}
+	return
+}
+
+func (fsys *FS) children(rel string, node *fsNode) (children []*fsNode, err error) {
+	if node.archived {
+		sub, mountErr := fsys.mount(rel, node)
+		if mountErr != nil {
+			err = mountErr
+			return
+		}
+		node = sub.root
+	}
+	children = node.children
+	return
+}
+
+func (fsys *FS) open(rel string, node *fsNode) (rc io.ReadCloser, err error) {
+	if node.encrypted && fsys.password == "" {
+		err = fsys.failed(rel, ErrPasswordRequired, true)
+		return
+	}
+	if rc, err = node.open(); err != nil {
+		err = fsys.failed(rel, err, node.encrypted)
+		return
+	}
+	return
+}
+
+// mount indexes the archive entry once, the entry is read into memory or a temp file when it is large.
+func (fsys *FS) mount(rel string, node *fsNode) (sub *FS, err error) {
+	fsys.locker.Lock()
+	defer fsys.locker.Unlock()
+	if node.mounted != nil {
+		// mounted again when the password is changed
+		if node.mounted.password == fsys.file.option.GetPassword(node.mounted.file.Path()) {
+			sub = node.mounted
+			return
+		}
+		_ = node.mounted.Close()
+		node.mounted = nil
+	}
+	rc, openErr := fsys.open(rel, node)
+	if openErr != nil {
+		err = openErr
+		return
+	}
+	defer rc.Close()
+	reader, closer, spoolErr := spool(rc, node.name, node.size)
+	if spoolErr != nil {
+		err = fsys.failed(rel, spoolErr, node.encrypted)
+		return
+	}
+	if sub, err = fsys.file.fork(rel, reader).FS(fsys.ctx); err != nil {
+		if closer != nil {
+			_ = closer()
+		}
+		err = fsys.failed(rel, err, false)
+		return
+	}
+	if closer != nil {
+		sub.closers = append(sub.closers, closer)
+	}
+	node.mounted = sub
+	return
+}
+
+// spool reads r into memory, or into a temp file when it is larger than 64MB.
+func spool(r io.Reader, name string, size int64) (reader Reader, closer func() error, err error) {
+	if size < 64*1024*1024 {
+		buf := bytes.NewBuffer(make([]byte, 0, size))
+		if _, err = io.Copy(buf, r); err != nil {
+			return
+		}
+		reader = bytes.NewReader(buf.Bytes())
+		return
+	}
+	tmpDir, tmpDirErr := os.MkdirTemp("", "DarkestDungeonModBox_archives_*")
+	if tmpDirErr != nil {
+		err = tmpDirErr
+		return
+	}
+	tmpFile, tmpFileErr := os.OpenFile(filepath.Join(tmpDir, name), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if tmpFileErr != nil {
+		_ = os.RemoveAll(tmpDir)
+		err = tmpFileErr
+		return
+	}
+	closer = func() error {
+		return errors.Join(tmpFile.Close(), os.RemoveAll(tmpDir))
+	}
+	if _, err = io.Copy(tmpFile, r); err != nil {
+		_ = closer()
+		closer = nil
+		return
+	}
+	reader = tmpFile
+	return
+}
+
+// passwordFailed joins err with ErrPasswordRequired or ErrPasswordInvalid when it is failed by encryption.
+func (fsys *FS) passwordFailed(err error, encrypted bool) error {
+	if isPasswordError(err) {
+		encrypted = true
+	}
+	if !encrypted || errors.Is(err, ErrPasswordRequired) || errors.Is(err, ErrPasswordInvalid) {
+		return err
+	}
+	if fsys.password == "" {
+		return errors.Join(err, ErrPasswordRequired)
+	}
+	return errors.Join(err, ErrPasswordInvalid)
+}
+
+// failed tells which archive or entry is failed, filename is the same as Path of FileInfo,
+// it is the archive when it is failed by password, since passwords are set to archives.
+func (fsys *FS) failed(rel string, err error, encrypted bool) error {
+	var fileErr FileError
+	if errors.As(err, &fileErr) {
+		return err
+	}
+	err = fsys.passwordFailed(err, encrypted)
+	filename := filepath.ToSlash(fsys.file.Path())
+	if rel != "." && !errors.Is(err, ErrPasswordRequired) && !errors.Is(err, ErrPasswordInvalid) {
+		filename = path.Join(filename, rel)
+	}
+	return FileError{Filename: filename, Err: err}
+}
+
+type fsNode struct {
+	name      string
+	dir       bool
+	archived  bool
+	encrypted bool
+	size      int64
+	mode      fs.FileMode
+	modTime   time.Time
+	header    any
+	open      func() (io.ReadCloser, error)
+	children  []*fsNode
+	mounted   *FS
+}
+
+func (node *fsNode) sort() {
+	slices.SortFunc(node.children, func(a, b *fsNode) int {
+		return strings.Compare(a.name, b.name)
+	})
+}
+
+func (node *fsNode) Name() string { return node.name }
+
+func (node *fsNode) Size() int64 { return node.size }
+
+func (node *fsNode) Mode() fs.FileMode {
+	if node.archived {
+		return fs.ModeDir | 0o555
+	}
+	return node.mode
+}
+
+func (node *fsNode) ModTime() time.Time { return node.modTime }
+
+func (node *fsNode) IsDir() bool { return node.dir || node.archived }
+
+func (node *fsNode) Sys() any { return node.header }
+
+func (node *fsNode) Type() fs.FileMode { return node.Mode().Type() }
+
+func (node *fsNode) Info() (fs.FileInfo, error) { return node, nil }
+
+type fsFile struct {
+	owner *FS
+	name  string
+	rel   string
+	node  *fsNode
+	rc    io.ReadCloser
+}
+
+func (file *fsFile) Stat() (fs.FileInfo, error) { return file.node, nil }
+
+func (file *fsFile) Read(p []byte) (n int, err error) {
+	if len(p) == 0 {
+		// rardecode never returns when nothing is to be read
+		return
+	}
+	n, err = file.rc.Read(p)
+	if err != nil && err != io.EOF {
+		err = &fs.PathError{Op: "read", Path: file.name, Err: file.owner.failed(file.rel, err, file.node.encrypted)}
+	}
+	return
+}
+
+func (file *fsFile) Close() error { return file.rc.Close() }
+
+type fsDir struct {
+	name     string
+	node     *fsNode
+	children []*fsNode
+	offset   int
+}
+
+func (dir *fsDir) Stat() (fs.FileInfo, error) { return dir.node, nil }
+
+func (dir *fsDir) Read(_ []byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: dir.name, Err: errors.New("is a directory")}
+}
+
+func (dir *fsDir) ReadDir(count int) (entries []fs.DirEntry, err error) {
+	n := len(dir.children) - dir.offset
+	if count > 0 && n > count {
+		n = count
+	}
+	if n == 0 && count > 0 {
+		err = io.EOF
+		return
+	}
+	entries = make([]fs.DirEntry, 0, n)
+	for _, child := range dir.children[dir.offset : dir.offset+n] {
+		entries = append(entries, child)
+	}
+	dir.offset += n
+	return
+}
+
+func (dir *fsDir) Close() error { return nil }
+
+// rarVolumeFS opens the archive as the only volume of rar.
+type rarVolumeFS struct {
+	reader io.ReaderAt
+	size   int64
+}
+
+const rarVolumeName = "archive.rar"
+
+func (volumes rarVolumeFS) Open(name string) (fs.File, error) {
+	if name != rarVolumeName {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return &rarVolume{SectionReader: io.NewSectionReader(volumes.reader, 0, volumes.size)}, nil
+}
+
+type rarVolume struct {
+	*io.SectionReader
+}
+
+func (volume *rarVolume) Stat() (fs.FileInfo, error) {
+	return &fsNode{name: rarVolumeName, size: volume.Size()}, nil
+}
+
+func (volume *rarVolume) Close() error { return nil }
@@ -0,0 +1,221 @@
+package archives_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"DarkestDungeonModBoxLite/backend/pkg/archives"
+)
+
+func fsTestPack(t *testing.T, format string, password string, fsys fstest.MapFS) []byte {
+	entries, entriesErr := archives.DirEntries(fsys, "")
+	if entriesErr != nil {
+		t.Fatal(entriesErr)
+	}
+	buf := bytes.NewBuffer(nil)
+	if err := archives.Pack(context.Background(), buf, entries, archives.PackOptions{Format: format, Password: password}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func fsTestArchive(t *testing.T, format string, password string) []byte {
+	inner := fsTestPack(t, ".7z", "222", fstest.MapFS{
+		"inner/readme.txt": {Data: []byte("inner")},
+	})
+	skin := fsTestPack(t, ".zip", "", fstest.MapFS{
+		"skin/arbalest.txt": {Data: []byte("arbalest")},
+	})
+	return fsTestPack(t, format, password, fstest.MapFS{
+		"mod/project.xml": {Data: []byte("<project></project>")},
+		"mod/inner.7z":    {Data: inner},
+		"mod/skin.zip":    {Data: skin},
+	})
+}
+
+func TestFile_FS(t *testing.T) {
+	for _, format := range []string{".zip", ".7z"} {
+		for _, password := range []string{"", "111"} {
+			name := "test" + format
+			file, fileErr := archives.New(name, bytes.NewReader(fsTestArchive(t, format, password)))
+			if fileErr != nil {
+				t.Fatal(fileErr)
+			}
+			if password != "" {
+				file.SetPassword(password)
+			}
+			file.SetEntryPassword(name+"/mod/inner.7z", "222")
+			fsys, fsErr := file.FS(context.Background())
+			if fsErr != nil {
+				t.Fatal(format, password, fsErr)
+			}
+			if err := fstest.TestFS(fsys, "mod/project.xml", "mod/skin.zip/skin/arbalest.txt", "mod/inner.7z/inner/readme.txt"); err != nil {
+				t.Error(format, password, err)
+			}
+			for filename, expect := range map[string]string{
+				"mod/project.xml":                "<project></project>",
+				"mod/skin.zip/skin/arbalest.txt": "arbalest",
+				"mod/inner.7z/inner/readme.txt":  "inner",
+			} {
+				content, readErr := fs.ReadFile(fsys, filename)
+				if readErr != nil {
+					t.Error(format, password, filename, readErr)
+					continue
+				}
+				if string(content) != expect {
+					t.Error(format, password, filename, "is", string(content))
+				}
+			}
+			info, statErr := fsys.Stat("mod/inner.7z")
+			if statErr != nil {
+				t.Fatal(statErr)
+			}
+			t.Log(format, password, info.Name(), info.IsDir(), info.Size())
+			if err := fsys.Close(); err != nil {
+				t.Error(err)
+			}
+		}
+	}
+}
+
+func TestFile_FS_Password(t *testing.T) {
+	for _, format := range []string{".zip", ".7z"} {
+		name := "test" + format
+		file, fileErr := archives.New(name, bytes.NewReader(fsTestArchive(t, format, "111")))
+		if fileErr != nil {
+			t.Fatal(fileErr)
+		}
+		fsys, fsErr := file.FS(context.Background())
+		if fsErr != nil {
+			t.Fatal(format, fsErr)
+		}
+		// entries are listed without password
+		entries, readDirErr := fsys.ReadDir("mod")
+		if readDirErr != nil {
+			t.Fatal(format, readDirErr)
+		}
+		t.Log(format, len(entries))
+		_, readErr := fs.ReadFile(fsys, "mod/project.xml")
+		if !errors.Is(readErr, archives.ErrPasswordRequired) {
+			t.Error(format, "password is not required", readErr)
+		}
+		errs, ok := archives.IsPasswordFailed(readErr)
+		if !ok || errs[0].Filename != name || !errs[0].PasswordRequired {
+			t.Error(format, "password failed is", errs)
+		}
+		_ = fsys.Close()
+
+		file.SetPassword("000")
+		if fsys, fsErr = file.FS(context.Background()); fsErr != nil {
+			t.Fatal(format, fsErr)
+		}
+		if _, readErr = fs.ReadFile(fsys, "mod/project.xml"); !errors.Is(readErr, archives.ErrPasswordInvalid) {
+			t.Error(format, "password is not invalid", readErr)
+		}
+		_ = fsys.Close()
+
+		file.SetPassword("111")
+		if fsys, fsErr = file.FS(context.Background()); fsErr != nil {
+			t.Fatal(format, fsErr)
+		}
+		// nested archive is failed without its password
+		_, readErr = fs.ReadFile(fsys, "mod/inner.7z/inner/readme.txt")
+		errs, ok = archives.IsPasswordFailed(readErr)
+		if !ok || errs[0].Filename != name+"/mod/inner.7z" {
+			t.Error(format, "nested password failed is", readErr)
+		}
+		// then it is mounted after setting password
+		file.SetEntryPassword(name+"/mod/inner.7z", "222")
+		content, contentErr := fs.ReadFile(fsys, "mod/inner.7z/inner/readme.txt")
+		if contentErr != nil || string(content) != "inner" {
+			t.Error(format, "nested", contentErr, string(content))
+		}
+		_ = fsys.Close()
+	}
+}
+
+// fsTestRar returns a rar5 archive of stored files, which are in order of names,
+// files but the first one are marked as solid when solid is true.
+func fsTestRar(names []string, contents []string, solid bool) []byte {
+	vint := func(b []byte, v uint64) []byte {
+		for v >= 0x80 {
+			b = append(b, byte(v)|0x80)
+			v >>= 7
+		}
+		return append(b, byte(v))
+	}
+	block := func(buf *bytes.Buffer, header []byte) {
+		size := vint(nil, uint64(len(header)))
+		_ = binary.Write(buf, binary.LittleEndian, crc32.ChecksumIEEE(append(size, header...)))
+		buf.Write(size)
+		buf.Write(header)
+	}
+	buf := bytes.NewBuffer([]byte("Rar!\x1a\x07\x01\x00"))
+	archiveFlags := uint64(0)
+	if solid {
+		archiveFlags = 0x04
+	}
+	// main
+	block(buf, vint(vint(vint(nil, 1), 0), archiveFlags))
+	for i, name := range names {
+		data := []byte(contents[i])
+		header := vint(nil, 2)                   // file
+		header = vint(header, 0x02)              // has data
+		header = vint(header, uint64(len(data))) // data size
+		header = vint(header, 0x04)              // has crc
+		header = vint(header, uint64(len(data))) // unpacked size
+		header = vint(header, 0x20)              // attributes
+		header = binary.LittleEndian.AppendUint32(header, crc32.ChecksumIEEE(data))
+		compression := uint64(0) // stored
+		if solid && i > 0 {
+			compression |= 0x40
+		}
+		header = vint(header, compression)
+		header = vint(header, 0) // windows
+		header = vint(header, uint64(len(name)))
+		header = append(header, name...)
+		block(buf, header)
+		buf.Write(data)
+	}
+	// end
+	block(buf, vint(vint(vint(nil, 5), 0), 0))
+	return buf.Bytes()
+}
+
+func TestFile_FS_Rar(t *testing.T) {
+	names := []string{"mod/project.xml", "mod/heroes/readme.txt", "mod/preview_icon.png"}
+	contents := []string{"<project></project>", "readme", "icon"}
+	for _, solid := range []bool{false, true} {
+		file, fileErr := archives.New("test.rar", bytes.NewReader(fsTestRar(names, contents, solid)))
+		if fileErr != nil {
+			t.Fatal(fileErr)
+		}
+		fsys, fsErr := file.FS(context.Background())
+		if fsErr != nil {
+			t.Fatal(solid, fsErr)
+		}
+		if err := fstest.TestFS(fsys, names...); err != nil {
+			t.Error(solid, err)
+		}
+		// solid files are read again from the first one, in any order
+		for _, i := range []int{2, 0, 1, 2} {
+			content, readErr := fs.ReadFile(fsys, names[i])
+			if readErr != nil {
+				t.Error(solid, names[i], readErr)
+				continue
+			}
+			if string(content) != contents[i] {
+				t.Error(solid, names[i], "is", string(content))
+			}
+		}
+		if err := fsys.Close(); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -4,8 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"io"
 	"path/filepath"
+	"strings"
 
 	"DarkestDungeonModBoxLite/backend/pkg/archives/pkg/ioutil"
 )
@@ -94,9 +94,19 @@ func (info *FileInfo) get(filename string) (target *FileInfo) {
 	return
 }
 
+// matchName matches the base name of path when pattern has no separator,
+// since '*' does not match separators.
+func matchName(pattern string, path string) (matched bool) {
+	if !strings.ContainsAny(pattern, `/\`) {
+		path = filepath.Base(path)
+	}
+	matched, _ = filepath.Match(pattern, path)
+	return
+}
+
 func (info *FileInfo) Match(pattern string) (targets []*FileInfo) {
 	path := info.Path()
-	if matched, _ := filepath.Match(pattern, path); matched {
+	if matchName(pattern, path) {
 		targets = append(targets, info)
 	}
 	for _, child := range info.Children {
@@ -171,55 +181,23 @@ func (info *FileInfo) String() string {
 	return string(b)
 }
 
+// Info inspects file in one pass per archive level, files matched by preview patterns are read into Preview.
+// ErrPasswordRequired or ErrPasswordInvalid is returned when file can not be read,
+// and FileError of them are joined when archived entries can not be read.
 func (file *File) Info(ctx context.Context, preview ...string) (info *FileInfo, err error) {
-	encrypted, encryptedErr := file.Encrypted(ctx)
-	if encryptedErr != nil {
-		err = encryptedErr
-		return
-	}
-	password := file.option.GetPassword(file.Path())
 	info = &FileInfo{
 		Name:      file.name,
 		IsDir:     false,
 		Archived:  true,
-		Encrypted: encrypted,
-		Password:  password,
+		Encrypted: false,
+		Password:  file.option.GetPassword(file.Path()),
 		Parent:    nil,
 		Preview:   nil,
 		Children:  nil,
 	}
-	err = file.Extract(ctx, func(ctx context.Context, entry *Entry) (err error) {
-		filename := entry.Name()
-		if entry.Info().IsDir() {
-			info.mountDir(filename)
-			return
-		}
-		if ok, entryEncrypted, entryPasswordInvalid, _ := entry.Archived(); ok {
-			entryPassword := ""
-			if entryEncrypted {
-				entryPassword = file.option.GetPassword(filename)
-			}
-			info.mountArchiveFile(filename, entryEncrypted, entryPasswordInvalid, entryPassword)
-			if entryPasswordInvalid { // when password invalid then skip
-				err = ErrSkip
-				return
-			}
-			// extract sub entry
-			file.ExtractedEntry(filename)
-			return
-		}
-		var data []byte
-		for _, pv := range preview {
-			if matched, _ := filepath.Match(pv, filename); matched {
-				if data, err = io.ReadAll(entry); err != nil {
-					return
-				}
-				break
-			}
-		}
-		info.mountFile(filename, data)
-		return
-	})
+	err = file.inspect(ctx, info, info, preview)
+	// the password may be found by keyring
+	info.Password = file.option.GetPassword(file.Path())
 	if err == nil {
 		entries := info.ArchiveEntries()
 		for _, entry := range entries {
@@ -1,7 +1,9 @@
 package archives_test
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -87,3 +89,58 @@ func TestFileInfo_Path(t *testing.T) {
 	t.Log(c21.Path())
 
 }
+
+func TestFile_Info_Nested(t *testing.T) {
+	for _, format := range []string{".zip", ".7z"} {
+		name := "test" + format
+		p := fsTestArchive(t, format, "111")
+		info := func(passwords map[string]string) (*archives.FileInfo, error) {
+			file, fileErr := archives.New(name, bytes.NewReader(p))
+			if fileErr != nil {
+				t.Fatal(fileErr)
+			}
+			for path, password := range passwords {
+				if path == name {
+					file.SetPassword(password)
+				} else {
+					file.SetEntryPassword(path, password)
+				}
+			}
+			return file.Info(context.Background(), "*.txt")
+		}
+		// outer password is required
+		if _, err := info(nil); !errors.Is(err, archives.ErrPasswordRequired) {
+			t.Error(format, "outer password is not required", err)
+		} else if _, nested := archives.IsPasswordFailed(err); nested {
+			t.Error(format, "outer password is failed as nested", err)
+		}
+		if _, err := info(map[string]string{name: "000"}); !errors.Is(err, archives.ErrPasswordInvalid) {
+			t.Error(format, "outer password is not invalid", err)
+		}
+		// inner is tried with outer password
+		root, err := info(map[string]string{name: "111"})
+		errs, ok := archives.IsPasswordFailed(err)
+		if !ok || len(errs) != 1 || errs[0].Filename != name+"/mod/inner.7z" || !errs[0].PasswordInvalid {
+			t.Error(format, "inner password failed is", err)
+		}
+		if !root.Encrypted {
+			t.Error(format, "outer is not encrypted")
+		}
+		if skin := root.Match("arbalest.txt"); len(skin) != 1 || string(skin[0].Preview) != "arbalest" {
+			t.Error(format, "skin is", skin)
+		}
+		// all passwords are set
+		root, err = info(map[string]string{name: "111", name + "/mod/inner.7z": "222"})
+		if err != nil {
+			t.Fatal(format, err)
+		}
+		readme := root.Match("readme.txt")
+		if len(readme) != 1 || string(readme[0].Preview) != "inner" || readme[0].Path() != name+"/mod/inner.7z/inner/readme.txt" {
+			t.Error(format, "readme is", readme)
+		}
+		if inner := readme[0].Parent.Parent; !inner.Archived || !inner.Encrypted || inner.Password != "222" {
+			t.Error(format, "inner is", inner)
+		}
+		t.Log(root.String())
+	}
+}
@@ -0,0 +1,273 @@
+package archives
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"path"
+	"path/filepath"
+
+	"DarkestDungeonModBoxLite/backend/pkg/archives/pkg/ioutil"
+	"DarkestDungeonModBoxLite/backend/pkg/archives/pkg/seeker"
+
+	"github.com/bodgit/sevenzip"
+	"github.com/mholt/archives"
+)
+
+var (
+	errWalkEncrypted = errors.New("walk into encrypted entry")
+	errWalkChecksum  = errors.New("checksum of encrypted entry is mismatched")
+	errEntryChecksum = errors.New("checksum of entry is mismatched")
+)
+
+// walkVisitor visits an entry with the head of it, and the rest of it is read from body.
+// head and body are nil when entry is a dir.
+type walkVisitor func(ctx context.Context, info archives.FileInfo, head []byte, body io.Reader) (err error)
+
+// walk visits entries of file in one pass, encryption is detected by headers and reading heads of entries,
+// file is walked again with passwords only when it is encrypted, and restart is called before each of them.
+// Its own password is tried first, then candidates of keyring, the one which opened file is set and remembered.
+// ErrPasswordRequired or ErrPasswordInvalid is returned when file can not be read without or with passwords.
+func (file *File) walk(ctx context.Context, restart func(), visit walkVisitor) (encrypted bool, err error) {
+	var visitFailed bool
+	if visitFailed, err = file.pass(ctx, "", visit); err == nil || visitFailed {
+		return
+	}
+	if !errors.Is(err, errWalkEncrypted) && !errors.Is(err, errWalkChecksum) && !isPasswordError(err) {
+		return
+	}
+	encrypted = true
+	path := file.Path()
+	password := file.option.GetPassword(path)
+	passwords, hash, hashErr := file.passwords(password)
+	if hashErr != nil {
+		err = hashErr
+		return
+	}
+	if len(passwords) == 0 {
+		err = ErrPasswordRequired
+		return
+	}
+	for _, candidate := range passwords {
+		if restart != nil {
+			restart()
+		}
+		// set before walking, so archived entries inherit it
+		if candidate != password {
+			file.option.SetPassword(path, candidate)
+		}
+		if visitFailed, err = file.pass(ctx, candidate, visit); err == nil {
+			if file.keyring != nil {
+				file.keyring.Remember(hash, candidate)
+			}
+			return
+		}
+		if visitFailed {
+			break
+		}
+	}
+	if file.option.GetPassword(path) != password {
+		file.option.SetPassword(path, password)
+	}
+	if visitFailed {
+		return
+	}
+	if password == "" {
+		err = errors.Join(err, ErrPasswordRequired)
+	} else {
+		err = errors.Join(err, ErrPasswordInvalid)
+	}
+	return
+}
+
+// pass walks file once, entries are read with password when it is not empty,
+// and visitFailed tells err is returned by visit.
+func (file *File) pass(ctx context.Context, password string, visit walkVisitor) (visitFailed bool, err error) {
+	crypto := password != ""
+	var extractor archives.Extractor
+	if extractor, err = file.identify(ctx, password); err != nil {
+		return
+	}
+	var visitErr error
+	streams := make(map[int]bool)
+	walkErr := extractor.Extract(ctx, file.reader, func(ctx context.Context, info archives.FileInfo) (err error) {
+		if info.IsDir() {
+			if visit != nil {
+				visitErr = visit(ctx, info, nil, nil)
+				err = visitErr
+			}
+			return
+		}
+		if !crypto && headerEncrypted(info.Header) {
+			err = errWalkEncrypted
+			return
+		}
+		reader, openErr := info.Open()
+		if openErr != nil {
+			err = openErr
+			return
+		}
+		defer reader.Close()
+		var body io.Reader = reader
+		// 7z has no flag of encryption in headers, and entries are decrypted by any password without errors,
+		// so the first entry of each stream is verified by its crc, then garbage is not taken as contents.
+		var (
+			checksum hash.Hash32
+			expected uint32
+		)
+		if header, ok := info.Header.(sevenzip.FileHeader); ok && header.CRC32 != 0 && !streams[header.Stream] {
+			streams[header.Stream] = true
+			checksum = crc32.NewIEEE()
+			expected = header.CRC32
+			body = io.TeeReader(reader, checksum)
+		}
+		head := make([]byte, 64)
+		headN, headErr := io.ReadFull(body, head)
+		if headErr != nil && !errors.Is(headErr, io.EOF) && !errors.Is(headErr, io.ErrUnexpectedEOF) {
+			err = headErr
+			return
+		}
+		if visit != nil {
+			// failures of reading body are not of visit, so the file is walked again when they are by passwords
+			rest := &walkReader{reader: body}
+			if err = visit(ctx, info, head[:headN], rest); err != nil {
+				if rest.err == nil {
+					visitErr = err
+				}
+				return
+			}
+		}
+		if checksum != nil {
+			if _, err = io.Copy(io.Discard, body); err != nil {
+				return
+			}
+			if checksum.Sum32() != expected {
+				// entries of aes folders are not opened without password, so it is only a wrong one when crypto
+				if crypto && file.sevenZipEncrypted(info.Header.(sevenzip.FileHeader)) {
+					err = errWalkChecksum
+					return
+				}
+				err = errors.Join(fmt.Errorf("failed to read %s", info.NameInArchive), errEntryChecksum)
+				return
+			}
+		}
+		return
+	})
+	visitFailed = visitErr != nil
+	err = errors.Join(walkErr, file.reset())
+	return
+}
+
+// sevenZipEncrypted tells whether the folder of the 7z entry has an aes coder,
+// file is read again without password, which is refused by sevenZipAESReader.
+func (file *File) sevenZipEncrypted(header sevenzip.FileHeader) (encrypted bool) {
+	size, sizeErr := seeker.Size(file.reader)
+	if sizeErr != nil {
+		return
+	}
+	reader, readerErr := sevenzip.NewReader(file.reader, size)
+	if readerErr != nil {
+		// headers are encrypted
+		encrypted = errors.Is(readerErr, errSevenZipAESPassword)
+		return
+	}
+	for _, f := range reader.File {
+		if f.Stream != header.Stream || f.Name != header.Name {
+			continue
+		}
+		rc, openErr := f.Open()
+		if openErr != nil {
+			encrypted = errors.Is(openErr, errSevenZipAESPassword)
+			return
+		}
+		_ = rc.Close()
+		return
+	}
+	return
+}
+
+// walkReader keeps the error of reading the rest of an entry.
+type walkReader struct {
+	reader io.Reader
+	err    error
+}
+
+func (r *walkReader) Read(p []byte) (n int, err error) {
+	n, err = r.reader.Read(p)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return
+}
+
+// inspect mounts entries of file into root in one pass per archive level,
+// archived entries are read into memory or temp files and inspected too,
+// and they are marked as PasswordInvalid when they can not be read without or with passwords.
+func (file *File) inspect(ctx context.Context, root *FileInfo, node *FileInfo, preview []string) (err error) {
+	var encrypted bool
+	encrypted, err = file.walk(ctx, func() {
+		node.Children = nil
+	}, func(ctx context.Context, info archives.FileInfo, head []byte, body io.Reader) (err error) {
+		filename := filepath.ToSlash(filepath.Join(file.Path(), info.NameInArchive))
+		if file.option.Discarded(filename) {
+			return
+		}
+		if info.IsDir() {
+			root.mountDir(filename)
+			return
+		}
+		if _, archived := TryValidate(bytes.NewReader(head)); archived {
+			err = file.inspectArchivedEntry(ctx, root, filename, info, head, body, preview)
+			return
+		}
+		var data []byte
+		for _, pv := range preview {
+			if matchName(pv, filename) {
+				rest, readErr := io.ReadAll(body)
+				if readErr != nil {
+					err = errors.Join(fmt.Errorf("failed to read %s", info.NameInArchive), readErr)
+					return
+				}
+				data = append(head, rest...)
+				break
+			}
+		}
+		root.mountFile(filename, data)
+		return
+	})
+	node.Encrypted = encrypted
+	return
+}
+
+func (file *File) inspectArchivedEntry(ctx context.Context, root *FileInfo, filename string, info archives.FileInfo, head []byte, body io.Reader, preview []string) (err error) {
+	node := root.mountArchiveFile(filename, false, false, "")
+	reader, closer, spoolErr := spool(ioutil.NewCompositeByteReader(head, body), path.Base(info.NameInArchive), info.Size())
+	if spoolErr != nil {
+		err = errors.Join(fmt.Errorf("failed to read %s", info.NameInArchive), spoolErr)
+		return
+	}
+	if closer != nil {
+		defer closer()
+	}
+	sub := file.fork(info.NameInArchive, reader)
+	file.ExtractedEntry(filename)
+	subErr := sub.inspect(ctx, root, node, preview)
+	node.Password = file.option.GetPassword(filename)
+	if subErr != nil {
+		var fileErr FileError
+		if !errors.As(subErr, &fileErr) && (errors.Is(subErr, ErrPasswordRequired) || errors.Is(subErr, ErrPasswordInvalid)) {
+			// the password of entry is missing or wrong
+			node.Encrypted = true
+			node.PasswordInvalid = true
+			node.Children = nil
+			return
+		}
+		err = subErr
+		return
+	}
+	return
+}
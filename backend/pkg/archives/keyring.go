@@ -0,0 +1,59 @@
+package archives
+
+import (
+	"io"
+	"slices"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// Keyring
+// provides known passwords to open encrypted archives, archives are identified by xxhash of their contents.
+type Keyring interface {
+	// Candidates returns passwords to be tried on the archive, remembered ones should come first.
+	Candidates(hash uint64) []string
+	// Remember records the password which opened the archive.
+	Remember(hash uint64, password string)
+}
+
+// SetKeyring sets keyring of file, its candidates are tried by Validate, Info and Extract on file and archived entries
+// when they can not be read without or with their passwords, and the found ones are set to them as SetEntryPassword does.
+func (file *File) SetKeyring(keyring Keyring) {
+	file.keyring = keyring
+}
+
+// hash returns xxhash of contents of file.
+func (file *File) hash() (v uint64, err error) {
+	if err = file.reset(); err != nil {
+		return
+	}
+	h := xxhash.New()
+	if _, err = io.Copy(h, file.reader); err != nil {
+		return
+	}
+	if err = file.reset(); err != nil {
+		return
+	}
+	v = h.Sum64()
+	return
+}
+
+// passwords returns password of file and candidates of keyring without duplicates.
+func (file *File) passwords(password string) (passwords []string, hash uint64, err error) {
+	if password != "" {
+		passwords = append(passwords, password)
+	}
+	if file.keyring == nil {
+		return
+	}
+	if hash, err = file.hash(); err != nil {
+		return
+	}
+	for _, candidate := range file.keyring.Candidates(hash) {
+		if candidate == "" || slices.Contains(passwords, candidate) {
+			continue
+		}
+		passwords = append(passwords, candidate)
+	}
+	return
+}
@@ -0,0 +1,83 @@
+package archives_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"DarkestDungeonModBoxLite/backend/pkg/archives"
+)
+
+type testKeyring struct {
+	candidates []string
+	remembered map[uint64]string
+}
+
+func (keyring *testKeyring) Candidates(hash uint64) []string {
+	if password, ok := keyring.remembered[hash]; ok {
+		return append([]string{password}, keyring.candidates...)
+	}
+	return keyring.candidates
+}
+
+func (keyring *testKeyring) Remember(hash uint64, password string) {
+	keyring.remembered[hash] = password
+}
+
+func TestFile_SetKeyring(t *testing.T) {
+	ctx := context.Background()
+	for _, format := range []string{".zip", ".7z"} {
+		name := "test" + format
+		data := fsTestArchive(t, format, "111")
+		keyring := &testKeyring{candidates: []string{"000", "111", "222"}, remembered: map[uint64]string{}}
+
+		file, fileErr := archives.New(name, bytes.NewReader(data))
+		if fileErr != nil {
+			t.Fatal(fileErr)
+		}
+		file.SetKeyring(keyring)
+		info, infoErr := file.Info(ctx, "*readme.txt")
+		if infoErr != nil {
+			t.Fatal(format, infoErr)
+		}
+		if info.Password != "111" {
+			t.Error(format, "password is", info.Password)
+		}
+		inner := info.Match("*inner.7z")
+		if len(inner) != 1 || inner[0].Password != "222" || inner[0].PasswordInvalid {
+			t.Error(format, "inner is", inner)
+		}
+		if len(keyring.remembered) != 2 {
+			t.Error(format, "remembered", keyring.remembered)
+		}
+		t.Log(format, keyring.remembered)
+
+		// found passwords are used by extract
+		readme := ""
+		extractErr := file.Extract(ctx, nil, func(ctx context.Context, entry *archives.Entry) (err error) {
+			if entry.Info().Name() == "readme.txt" {
+				b, _ := io.ReadAll(entry)
+				readme = string(b)
+			}
+			return
+		})
+		if extractErr != nil || readme != "inner" {
+			t.Error(format, "extract", extractErr, readme)
+		}
+
+		// nested archive still needs input when no candidate opens it
+		keyring.candidates = []string{"111"}
+		keyring.remembered = map[uint64]string{}
+		if file, fileErr = archives.New(name, bytes.NewReader(data)); fileErr != nil {
+			t.Fatal(fileErr)
+		}
+		file.SetKeyring(keyring)
+		_, infoErr = file.Info(ctx)
+		errs, ok := archives.IsPasswordFailed(infoErr)
+		if !ok || len(errs) != 1 || errs[0].Filename != name+"/mod/inner.7z" {
+			t.Error(format, "password failed is", infoErr)
+		}
+		t.Log(format, errs)
+	}
+}
@@ -0,0 +1,221 @@
+package archives
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/yeka/zip"
+)
+
+var (
+	// PackTime is the modification time of entries when PackOptions.ModTime is zero,
+	// which is the earliest time of zip.
+	PackTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// PackOptions
+// Format is .zip or .7z, entries are encrypted by AES-256 when Password is not empty.
+type PackOptions struct {
+	Format   string
+	Password string
+	ModTime  time.Time
+}
+
+func (options PackOptions) modTime() time.Time {
+	if options.ModTime.IsZero() {
+		return PackTime
+	}
+	return options.ModTime.UTC()
+}
+
+// PackEntry
+// Name is slash-separated path in archive, Open is nil when it is a dir.
+type PackEntry struct {
+	Name string
+	Open func() (io.ReadCloser, error)
+}
+
+func (entry PackEntry) IsDir() bool {
+	return entry.Open == nil
+}
+
+// DirEntries walks files of fsys, names of entries are joined with prefix.
+func DirEntries(fsys fs.FS, prefix string) (entries []PackEntry, err error) {
+	prefix = strings.Trim(path.Clean(strings.ReplaceAll(prefix, "\\", "/")), "/")
+	if prefix == "." {
+		prefix = ""
+	}
+	err = fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, walkErr error) (err error) {
+		if walkErr != nil {
+			err = walkErr
+			return
+		}
+		if name == "." {
+			return
+		}
+		entry := PackEntry{Name: path.Join(prefix, name)}
+		if d.IsDir() {
+			entries = append(entries, entry)
+			return
+		}
+		if !d.Type().IsRegular() {
+			return
+		}
+		entry.Open = func() (io.ReadCloser, error) {
+			return fsys.Open(name)
+		}
+		entries = append(entries, entry)
+		return
+	})
+	return
+}
+
+// Pack writes entries into dst as an archive of options.Format.
+// entries are sorted by names, dirs of them are added, and all of them have the same modification time,
+// so the same entries are packed in the same order, and the archive is the same when it is not encrypted.
+func Pack(ctx context.Context, dst io.Writer, entries []PackEntry, options PackOptions) (err error) {
+	if entries, err = sortPackEntries(entries); err != nil {
+		return
+	}
+	switch strings.ToLower(options.Format) {
+	case ".zip":
+		err = packZip(ctx, dst, entries, options)
+	case ".7z":
+		err = pack7z(ctx, dst, entries, options)
+	default:
+		err = fmt.Errorf("%s is not supported to pack", options.Format)
+	}
+	return
+}
+
+// PackFile is Pack into filename, the format is told by extension of filename,
+// the file is removed when packing failed.
+func PackFile(ctx context.Context, filename string, entries []PackEntry, password string) (err error) {
+	dst, createErr := os.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if createErr != nil {
+		err = errors.Join(fmt.Errorf("failed to create %s", filename), createErr)
+		return
+	}
+	err = Pack(ctx, dst, entries, PackOptions{
+		Format:   path.Ext(strings.ReplaceAll(filename, "\\", "/")),
+		Password: password,
+	})
+	if closeErr := dst.Close(); err == nil && closeErr != nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(filename)
+	}
+	return
+}
+
+// sortPackEntries cleans names, adds missing parent dirs, and sorts entries by names.
+func sortPackEntries(entries []PackEntry) (sorted []PackEntry, err error) {
+	byName := make(map[string]PackEntry, len(entries))
+	for _, entry := range entries {
+		name := path.Clean(strings.ReplaceAll(entry.Name, "\\", "/"))
+		name = strings.TrimPrefix(name, "/")
+		if name == "." || name == "" || name == ".." || strings.HasPrefix(name, "../") {
+			err = fmt.Errorf("%s is invalid entry name", entry.Name)
+			return
+		}
+		entry.Name = name
+		if exist, has := byName[name]; has && (exist.IsDir() != entry.IsDir() || !entry.IsDir()) {
+			err = fmt.Errorf("%s is duplicated", name)
+			return
+		}
+		byName[name] = entry
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			if exist, has := byName[dir]; has {
+				if !exist.IsDir() {
+					err = fmt.Errorf("%s is a file", dir)
+					return
+				}
+				continue
+			}
+			byName[dir] = PackEntry{Name: dir}
+		}
+	}
+	sorted = make([]PackEntry, 0, len(byName))
+	for _, entry := range byName {
+		sorted = append(sorted, entry)
+	}
+	slices.SortFunc(sorted, func(a, b PackEntry) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return
+}
+
+func packZip(ctx context.Context, dst io.Writer, entries []PackEntry, options PackOptions) (err error) {
+	w := zip.NewWriter(dst)
+	modTime := options.modTime()
+	for _, entry := range entries {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		header := &zip.FileHeader{
+			Name:   entry.Name,
+			Method: zip.Deflate,
+		}
+		if !isASCII(entry.Name) {
+			header.Flags |= 0x800 // utf-8 name
+		}
+		header.SetModTime(modTime)
+		if entry.IsDir() {
+			header.Name += "/"
+			header.Method = zip.Store
+			header.SetMode(fs.ModeDir | 0755)
+			if _, err = w.CreateHeader(header); err != nil {
+				err = errors.Join(fmt.Errorf("failed to pack %s", entry.Name), err)
+				return
+			}
+			continue
+		}
+		header.SetMode(0644)
+		if options.Password != "" {
+			header.SetPassword(options.Password)
+			header.SetEncryptionMethod(zip.AES256Encryption)
+		}
+		fw, createErr := w.CreateHeader(header)
+		if createErr != nil {
+			err = errors.Join(fmt.Errorf("failed to pack %s", entry.Name), createErr)
+			return
+		}
+		if err = copyPackEntry(fw, entry); err != nil {
+			return
+		}
+	}
+	err = w.Close()
+	return
+}
+
+func copyPackEntry(dst io.Writer, entry PackEntry) (err error) {
+	src, openErr := entry.Open()
+	if openErr != nil {
+		err = errors.Join(fmt.Errorf("failed to open %s", entry.Name), openErr)
+		return
+	}
+	defer src.Close()
+	if _, cpErr := io.Copy(dst, src); cpErr != nil {
+		err = errors.Join(fmt.Errorf("failed to pack %s", entry.Name), cpErr)
+		return
+	}
+	return
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,397 @@
+package archives
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"slices"
+	"time"
+	"unicode/utf16"
+
+	"github.com/ulikunitz/xz/lzma"
+)
+
+/* 7z
+signature header | packed stream | header
+all files are in one solid folder of lzma2, which is encrypted by 7zAES when password is set,
+the header is not encoded, so names of entries are readable without password, as same as zip.
+*/
+
+const (
+	sevenZipDictCap   = 8 << 20
+	sevenZipDictProp  = 22 // (2 | 22&1) << (22/2 + 11) is 8MB
+	sevenZipAESCycles = 19
+
+	sevenZipIdEnd             = 0x00
+	sevenZipIdHeader          = 0x01
+	sevenZipIdMainStreamsInfo = 0x04
+	sevenZipIdFilesInfo       = 0x05
+	sevenZipIdPackInfo        = 0x06
+	sevenZipIdUnpackInfo      = 0x07
+	sevenZipIdSubStreamsInfo  = 0x08
+	sevenZipIdSize            = 0x09
+	sevenZipIdCRC             = 0x0A
+	sevenZipIdFolder          = 0x0B
+	sevenZipIdCodersUnpackSz  = 0x0C
+	sevenZipIdNumUnpackStream = 0x0D
+	sevenZipIdEmptyStream     = 0x0E
+	sevenZipIdEmptyFile       = 0x0F
+	sevenZipIdName            = 0x11
+	sevenZipIdMTime           = 0x14
+	sevenZipIdWinAttributes   = 0x15
+
+	sevenZipAttributeDir  = 0x10
+	sevenZipAttributeFile = 0x20
+)
+
+var (
+	sevenZipSignature = []byte{'7', 'z', 0xBC, 0xAF, 0x27, 0x1C, 0, 4}
+	sevenZipLZMA2     = []byte{0x21}
+	sevenZipAES       = []byte{0x06, 0xF1, 0x07, 0x01}
+)
+
+type sevenZipFile struct {
+	name string
+	dir  bool
+	size uint64
+	crc  uint32
+}
+
+func pack7z(ctx context.Context, dst io.Writer, entries []PackEntry, options PackOptions) (err error) {
+	// packed stream is before header, whose sizes are known after packing
+	tmp, tmpErr := os.CreateTemp("", "DarkestDungeonModBox_archives_*.7z")
+	if tmpErr != nil {
+		err = tmpErr
+		return
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+
+	packed := &countWriter{w: tmp}
+	var sink io.WriteCloser = nopWriteCloser{packed}
+	var aesProps []byte
+	if options.Password != "" {
+		if sink, aesProps, err = newSevenZipAESWriter(packed, options.Password); err != nil {
+			return
+		}
+	}
+	compressed := &countWriter{w: sink}
+	lw, lwErr := lzma.Writer2Config{DictCap: sevenZipDictCap}.NewWriter2(compressed)
+	if lwErr != nil {
+		err = lwErr
+		return
+	}
+
+	files := make([]sevenZipFile, 0, len(entries))
+	streams := 0
+	total := uint64(0)
+	for _, entry := range entries {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		file := sevenZipFile{name: entry.Name, dir: entry.IsDir()}
+		if !file.dir {
+			h := crc32.NewIEEE()
+			counter := &countWriter{w: io.MultiWriter(lw, h)}
+			if err = copyPackEntry(counter, entry); err != nil {
+				return
+			}
+			file.size = uint64(counter.n)
+			file.crc = h.Sum32()
+			if file.size > 0 {
+				streams++
+				total += file.size
+			}
+		}
+		files = append(files, file)
+	}
+	if err = lw.Close(); err != nil {
+		return
+	}
+	if err = sink.Close(); err != nil {
+		return
+	}
+	if streams == 0 {
+		packed.n = 0
+	}
+
+	header := &bytes.Buffer{}
+	writeSevenZipHeader(header, files, uint64(packed.n), uint64(compressed.n), total, aesProps, options.modTime())
+
+	start := make([]byte, 20)
+	binary.LittleEndian.PutUint64(start[0:], uint64(packed.n))
+	binary.LittleEndian.PutUint64(start[8:], uint64(header.Len()))
+	binary.LittleEndian.PutUint32(start[16:], crc32.ChecksumIEEE(header.Bytes()))
+	signature := make([]byte, 0, 32)
+	signature = append(signature, sevenZipSignature...)
+	signature = binary.LittleEndian.AppendUint32(signature, crc32.ChecksumIEEE(start))
+	signature = append(signature, start...)
+	if _, err = dst.Write(signature); err != nil {
+		return
+	}
+	if packed.n > 0 {
+		if _, err = tmp.Seek(0, io.SeekStart); err != nil {
+			return
+		}
+		if _, err = io.CopyN(dst, tmp, packed.n); err != nil {
+			err = errors.Join(errors.New("failed to write packed stream"), err)
+			return
+		}
+	}
+	_, err = dst.Write(header.Bytes())
+	return
+}
+
+func writeSevenZipHeader(b *bytes.Buffer, files []sevenZipFile, packedSize uint64, compressedSize uint64, total uint64, aesProps []byte, modTime time.Time) {
+	b.WriteByte(sevenZipIdHeader)
+	sizes := make([]uint64, 0, len(files))
+	crcs := make([]uint32, 0, len(files))
+	empties := make([]bool, len(files))
+	emptyFiles := make([]bool, 0, 1)
+	for i, file := range files {
+		if !file.dir && file.size > 0 {
+			sizes = append(sizes, file.size)
+			crcs = append(crcs, file.crc)
+			continue
+		}
+		empties[i] = true
+		emptyFiles = append(emptyFiles, !file.dir)
+	}
+
+	// streams
+	if len(sizes) > 0 {
+		b.WriteByte(sevenZipIdMainStreamsInfo)
+
+		b.WriteByte(sevenZipIdPackInfo)
+		writeSevenZipNumber(b, 0)
+		writeSevenZipNumber(b, 1)
+		b.WriteByte(sevenZipIdSize)
+		writeSevenZipNumber(b, packedSize)
+		b.WriteByte(sevenZipIdEnd)
+
+		b.WriteByte(sevenZipIdUnpackInfo)
+		b.WriteByte(sevenZipIdFolder)
+		writeSevenZipNumber(b, 1)
+		b.WriteByte(0) // not external
+		if aesProps != nil {
+			// aes is the first coder, whose output is bound to input of lzma2
+			writeSevenZipNumber(b, 2)
+			writeSevenZipCoder(b, sevenZipAES, aesProps)
+			writeSevenZipCoder(b, sevenZipLZMA2, []byte{sevenZipDictProp})
+			writeSevenZipNumber(b, 1) // in of lzma2
+			writeSevenZipNumber(b, 0) // out of aes
+		} else {
+			writeSevenZipNumber(b, 1)
+			writeSevenZipCoder(b, sevenZipLZMA2, []byte{sevenZipDictProp})
+		}
+		b.WriteByte(sevenZipIdCodersUnpackSz)
+		if aesProps != nil {
+			writeSevenZipNumber(b, compressedSize)
+		}
+		writeSevenZipNumber(b, total)
+		b.WriteByte(sevenZipIdEnd)
+
+		b.WriteByte(sevenZipIdSubStreamsInfo)
+		b.WriteByte(sevenZipIdNumUnpackStream)
+		writeSevenZipNumber(b, uint64(len(sizes)))
+		if len(sizes) > 1 {
+			b.WriteByte(sevenZipIdSize)
+			for _, size := range sizes[:len(sizes)-1] {
+				writeSevenZipNumber(b, size)
+			}
+		}
+		b.WriteByte(sevenZipIdCRC)
+		b.WriteByte(1) // all defined
+		for _, crc := range crcs {
+			_ = binary.Write(b, binary.LittleEndian, crc)
+		}
+		b.WriteByte(sevenZipIdEnd)
+
+		b.WriteByte(sevenZipIdEnd)
+	}
+
+	// files
+	b.WriteByte(sevenZipIdFilesInfo)
+	writeSevenZipNumber(b, uint64(len(files)))
+	if len(emptyFiles) > 0 {
+		writeSevenZipProperty(b, sevenZipIdEmptyStream, sevenZipBits(empties))
+		if slices.Contains(emptyFiles, true) {
+			writeSevenZipProperty(b, sevenZipIdEmptyFile, sevenZipBits(emptyFiles))
+		}
+	}
+	names := []byte{0} // not external
+	for _, file := range files {
+		for _, u := range utf16.Encode([]rune(file.name)) {
+			names = binary.LittleEndian.AppendUint16(names, u)
+		}
+		names = append(names, 0, 0)
+	}
+	writeSevenZipProperty(b, sevenZipIdName, names)
+	times := []byte{1, 0} // all defined, not external
+	attributes := []byte{1, 0}
+	filetime := uint64(modTime.UnixNano()/100) + 116444736000000000
+	for _, file := range files {
+		times = binary.LittleEndian.AppendUint64(times, filetime)
+		if file.dir {
+			attributes = binary.LittleEndian.AppendUint32(attributes, sevenZipAttributeDir)
+		} else {
+			attributes = binary.LittleEndian.AppendUint32(attributes, sevenZipAttributeFile)
+		}
+	}
+	writeSevenZipProperty(b, sevenZipIdMTime, times)
+	writeSevenZipProperty(b, sevenZipIdWinAttributes, attributes)
+	b.WriteByte(sevenZipIdEnd)
+
+	b.WriteByte(sevenZipIdEnd)
+}
+
+func writeSevenZipCoder(b *bytes.Buffer, id []byte, props []byte) {
+	b.WriteByte(byte(len(id)) | 0x20) // simple coder with properties
+	b.Write(id)
+	writeSevenZipNumber(b, uint64(len(props)))
+	b.Write(props)
+}
+
+func writeSevenZipProperty(b *bytes.Buffer, id byte, data []byte) {
+	b.WriteByte(id)
+	writeSevenZipNumber(b, uint64(len(data)))
+	b.Write(data)
+}
+
+// writeSevenZipNumber writes v in 1 to 9 bytes, leading 1 bits of the first byte are the count of following bytes.
+func writeSevenZipNumber(b *bytes.Buffer, v uint64) {
+	first := byte(0)
+	mask := byte(0x80)
+	i := 0
+	for ; i < 8; i++ {
+		if v < uint64(1)<<(7*(i+1)) {
+			first |= byte(v >> (8 * i))
+			break
+		}
+		first |= mask
+		mask >>= 1
+	}
+	b.WriteByte(first)
+	for j := 0; j < i; j++ {
+		b.WriteByte(byte(v >> (8 * j)))
+	}
+}
+
+func sevenZipBits(values []bool) []byte {
+	bits := make([]byte, (len(values)+7)/8)
+	for i, value := range values {
+		if value {
+			bits[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	return bits
+}
+
+// sevenZipAESWriter encrypts by AES-256-CBC, the last block is padded by zeros when it is closed.
+type sevenZipAESWriter struct {
+	w   io.Writer
+	cbc cipher.BlockMode
+	buf []byte
+}
+
+// newSevenZipAESWriter returns the writer and properties of 7zAES coder, which has a random iv and no salt.
+func newSevenZipAESWriter(w io.Writer, password string) (aw *sevenZipAESWriter, props []byte, err error) {
+	iv := make([]byte, aes.BlockSize)
+	if _, err = rand.Read(iv); err != nil {
+		return
+	}
+	block, blockErr := aes.NewCipher(sevenZipAESKey(password, sevenZipAESCycles, nil))
+	if blockErr != nil {
+		err = blockErr
+		return
+	}
+	aw = &sevenZipAESWriter{
+		w:   w,
+		cbc: cipher.NewCBCEncrypter(block, iv),
+		buf: make([]byte, 0, 64*aes.BlockSize),
+	}
+	props = append([]byte{sevenZipAESCycles | 0x40, aes.BlockSize - 1}, iv...)
+	return
+}
+
+// sevenZipAESKey is sha256 of (salt, password in utf-16le, counter) repeated 2^cycles times,
+// or salt and password themselves when cycles is 0x3f.
+func sevenZipAESKey(password string, cycles int, salt []byte) []byte {
+	p := slices.Clone(salt)
+	for _, u := range utf16.Encode([]rune(password)) {
+		p = binary.LittleEndian.AppendUint16(p, u)
+	}
+	if cycles == 0x3f {
+		key := make([]byte, sha256.Size)
+		copy(key, p)
+		return key
+	}
+	n := len(p)
+	p = append(p, make([]byte, 8)...)
+	h := sha256.New()
+	for i := uint64(0); i < 1<<cycles; i++ {
+		binary.LittleEndian.PutUint64(p[n:], i)
+		h.Write(p)
+	}
+	return h.Sum(nil)
+}
+
+func (aw *sevenZipAESWriter) Write(p []byte) (n int, err error) {
+	n = len(p)
+	for len(p) > 0 {
+		m := min(len(p), cap(aw.buf)-len(aw.buf))
+		aw.buf = append(aw.buf, p[:m]...)
+		p = p[m:]
+		if len(aw.buf) == cap(aw.buf) {
+			if err = aw.flush(len(aw.buf)); err != nil {
+				n -= len(p)
+				return
+			}
+		}
+	}
+	return
+}
+
+func (aw *sevenZipAESWriter) flush(size int) (err error) {
+	aw.cbc.CryptBlocks(aw.buf[:size], aw.buf[:size])
+	_, err = aw.w.Write(aw.buf[:size])
+	aw.buf = aw.buf[:copy(aw.buf, aw.buf[size:])]
+	return
+}
+
+func (aw *sevenZipAESWriter) Close() (err error) {
+	if rest := len(aw.buf) % aes.BlockSize; rest > 0 {
+		aw.buf = append(aw.buf, make([]byte, aes.BlockSize-rest)...)
+	}
+	if len(aw.buf) > 0 {
+		err = aw.flush(len(aw.buf))
+	}
+	return
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countWriter) Write(p []byte) (n int, err error) {
+	n, err = cw.w.Write(p)
+	cw.n += int64(n)
+	return
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
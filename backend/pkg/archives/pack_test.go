@@ -0,0 +1,114 @@
+package archives_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"DarkestDungeonModBoxLite/backend/pkg/archives"
+)
+
+func packTestEntries(t *testing.T) []archives.PackEntry {
+	fsys := fstest.MapFS{
+		"project.xml":                           {Data: []byte("<project></project>")},
+		"heroes/crusader/crusader.info.darkest": {Data: []byte(strings.Repeat("crusader ", 1024))},
+		"localization/中文.string_table.xml":      {Data: []byte("<root></root>")},
+		"empty.txt":                             {Data: nil},
+	}
+	entries, err := archives.DirEntries(fsys, "mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
+
+func unpackTest(t *testing.T, name string, p []byte, password string) (contents map[string]string, err error) {
+	file, fileErr := archives.New(name, bytes.NewReader(p))
+	if fileErr != nil {
+		t.Fatal(fileErr)
+	}
+	if password != "" {
+		file.SetPassword(password)
+	}
+	contents = make(map[string]string)
+	err = file.Extract(context.Background(), nil, func(ctx context.Context, entry *archives.Entry) (err error) {
+		if entry.Info().IsDir() {
+			contents[entry.Name()+"/"] = ""
+			return
+		}
+		b, readErr := io.ReadAll(entry)
+		if readErr != nil {
+			err = readErr
+			return
+		}
+		contents[entry.Name()] = string(b)
+		return
+	})
+	return
+}
+
+func TestPack(t *testing.T) {
+	for _, format := range []string{".zip", ".7z"} {
+		for _, password := range []string{"", "111"} {
+			buf := bytes.NewBuffer(nil)
+			err := archives.Pack(context.Background(), buf, packTestEntries(t), archives.PackOptions{
+				Format:   format,
+				Password: password,
+			})
+			if err != nil {
+				t.Fatal(format, password, err)
+			}
+			t.Log(format, password, buf.Len())
+			name := "test" + format
+			contents, unpackErr := unpackTest(t, name, buf.Bytes(), password)
+			if unpackErr != nil {
+				t.Fatal(format, password, unpackErr)
+			}
+			expects := map[string]string{
+				"test" + format + "/mod/":                                      "",
+				"test" + format + "/mod/project.xml":                           "<project></project>",
+				"test" + format + "/mod/heroes/crusader/crusader.info.darkest": strings.Repeat("crusader ", 1024),
+				"test" + format + "/mod/localization/中文.string_table.xml":      "<root></root>",
+				"test" + format + "/mod/empty.txt":                             "",
+			}
+			for filename, expect := range expects {
+				content, has := contents[filename]
+				if !has {
+					t.Error(format, password, "missing", filename, contents)
+					continue
+				}
+				if content != expect {
+					t.Error(format, password, "content of", filename, "is", len(content))
+				}
+			}
+			if password != "" {
+				if _, noPasswordErr := unpackTest(t, name, buf.Bytes(), ""); noPasswordErr == nil {
+					t.Error(format, "encrypted archive is extracted without password")
+				}
+			}
+		}
+	}
+}
+
+func TestPack_Deterministic(t *testing.T) {
+	for _, format := range []string{".zip", ".7z"} {
+		a, b := bytes.NewBuffer(nil), bytes.NewBuffer(nil)
+		entries := packTestEntries(t)
+		if err := archives.Pack(context.Background(), a, entries, archives.PackOptions{Format: format}); err != nil {
+			t.Fatal(err)
+		}
+		// reversed entries are sorted again
+		for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+			entries[i], entries[j] = entries[j], entries[i]
+		}
+		if err := archives.Pack(context.Background(), b, entries, archives.PackOptions{Format: format}); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(a.Bytes(), b.Bytes()) {
+			t.Error(format, "is not deterministic")
+		}
+	}
+}
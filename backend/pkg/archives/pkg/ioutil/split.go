@@ -13,7 +13,8 @@ func Split(filename string) (dirs []string, file string) {
 	}
 	dir := ""
 	dir, file = filepath.Split(filename)
-	if dir != "" {
+	// the root of an absolute filename is split into itself
+	if dir != "" && dir != filename {
 		subDirs, subFile := Split(dir)
 		if subFile != "" {
 			if len(subDirs) > 0 {
@@ -13,6 +13,8 @@ func TestSplit(t *testing.T) {
 		`./hello.txt`,
 		`foo/bar/baz/hello.txt`,
 		`./foo/bar/baz/hello.txt`,
+		`/foo/bar/hello.txt`,
+		`/`,
 	}
 
 	for _, s := range ss {
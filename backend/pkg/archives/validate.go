@@ -3,72 +3,13 @@ package archives
 import (
 	"context"
 	"encoding/hex"
-	"errors"
 	"io"
 	"strings"
-
-	"github.com/mholt/archives"
 )
 
+// Validate checks whether file can be read in one pass, with its password when it is encrypted.
 func (file *File) Validate(ctx context.Context) (err error) {
-	encrypted, encryptedErr := file.Encrypted(ctx)
-	if encryptedErr != nil {
-		err = encryptedErr
-		return
-	}
-	password := ""
-	if encrypted {
-		password = file.option.GetPassword(file.Path())
-		if password == "" {
-			err = ErrPasswordRequired
-			return
-		}
-	}
-
-	extractor, identifyErr := file.identify(ctx, password)
-	if identifyErr != nil {
-		err = identifyErr
-		return
-	}
-
-	err = extractor.Extract(ctx, file.reader, func(ctx context.Context, info archives.FileInfo) (err error) {
-		if info.IsDir() {
-			return
-		}
-		item, openErr := info.Open()
-		if openErr != nil {
-			err = openErr
-			return
-		}
-		b := make([]byte, 8)
-		_, readErr := item.Read(b)
-		_ = item.Close()
-		if readErr != nil {
-			if readErr != io.EOF {
-				err = readErr
-				return
-			}
-			return
-		}
-		return
-	})
-
-	resetErr := file.reset()
-
-	if err != nil {
-		if encrypted {
-			err = errors.Join(err, ErrPasswordInvalid)
-		}
-		if resetErr != nil {
-			err = errors.Join(err, resetErr)
-			return
-		}
-		return
-	}
-	if resetErr != nil {
-		err = resetErr
-		return
-	}
+	_, err = file.walk(ctx, nil, nil)
 	return
 }
 
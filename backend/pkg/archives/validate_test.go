@@ -1,9 +1,14 @@
 package archives_test
 
 import (
+	"bytes"
 	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
 	"os"
 	"testing"
+	"testing/fstest"
 
 	"DarkestDungeonModBoxLite/backend/pkg/archives"
 )
@@ -30,3 +35,34 @@ func TestFile_Validate(t *testing.T) {
 
 	t.Log(file.Validate(ctx))
 }
+
+func TestFile_Validate_Checksum(t *testing.T) {
+	contents := []byte("darkest dungeon")
+	data := fsTestPack(t, ".7z", "", fstest.MapFS{"readme.txt": {Data: contents}})
+
+	// break crc of the entry in header, then header and start header are signed again
+	crc := binary.LittleEndian.AppendUint32(nil, crc32.ChecksumIEEE(contents))
+	headerOffset := 32 + binary.LittleEndian.Uint64(data[12:])
+	at := bytes.LastIndex(data[headerOffset:], crc)
+	if at < 0 {
+		t.Fatal("crc is not found in header")
+	}
+	data[int(headerOffset)+at] ^= 0xff
+	binary.LittleEndian.PutUint32(data[28:], crc32.ChecksumIEEE(data[headerOffset:]))
+	binary.LittleEndian.PutUint32(data[8:], crc32.ChecksumIEEE(data[12:32]))
+
+	keyring := &testKeyring{candidates: []string{"111"}, remembered: map[uint64]string{}}
+	file, fileErr := archives.New("test.7z", bytes.NewReader(data))
+	if fileErr != nil {
+		t.Fatal(fileErr)
+	}
+	file.SetKeyring(keyring)
+	err := file.Validate(context.Background())
+	t.Log(err)
+	if err == nil {
+		t.Fatal("mismatched checksum is valid")
+	}
+	if errors.Is(err, archives.ErrPasswordRequired) || errors.Is(err, archives.ErrPasswordInvalid) || len(keyring.remembered) != 0 {
+		t.Error("plain archive is taken as encrypted", err)
+	}
+}
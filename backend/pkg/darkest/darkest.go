@@ -0,0 +1,213 @@
+// Package darkest reads and writes darkest dungeon data files (*.darkest).
+//
+// a file is a list of records, a record is a key and params, a param is a name and values.
+//
+//	combat_skill: .id "smite" .level 0 .atk 85% .dmg 0% .effect "Stun 1" "Kill Unholy"
+//
+// spaces, line breaks and comments are kept in Space fields, so that String returns the parsed text as it was.
+package darkest
+
+import (
+	"io"
+	"strings"
+)
+
+type File struct {
+	Records []*Record
+	// Trailing is spaces and comments after the last record
+	Trailing string
+}
+
+// Find returns records of key.
+func (file *File) Find(key string) (records []*Record) {
+	for _, record := range file.Records {
+		if record.Key == key {
+			records = append(records, record)
+		}
+	}
+	return
+}
+
+// Add appends record at a new line, line breaks follow the file.
+func (file *File) Add(record *Record) {
+	lineBreak := file.lineBreak()
+	if record.Space == "" {
+		if strings.Contains(file.Trailing, "\n") {
+			record.Space = file.Trailing
+			file.Trailing = lineBreak
+		} else if len(file.Records) > 0 || file.Trailing != "" {
+			record.Space = file.Trailing + lineBreak
+			file.Trailing = ""
+		}
+	}
+	file.Records = append(file.Records, record)
+}
+
+func (file *File) lineBreak() string {
+	if strings.Contains(file.Trailing, "\r\n") {
+		return "\r\n"
+	}
+	for _, record := range file.Records {
+		if strings.Contains(record.Space, "\r\n") {
+			return "\r\n"
+		}
+	}
+	return "\n"
+}
+
+func (file *File) WriteTo(w io.Writer) (n int64, err error) {
+	b := strings.Builder{}
+	for _, record := range file.Records {
+		record.write(&b)
+	}
+	b.WriteString(file.Trailing)
+	written, writeErr := io.WriteString(w, b.String())
+	n = int64(written)
+	err = writeErr
+	return
+}
+
+func (file *File) String() string {
+	b := strings.Builder{}
+	_, _ = file.WriteTo(&b)
+	return b.String()
+}
+
+type Record struct {
+	Space string
+	// Key is without ':', it is empty for texts which are not in any record, such as the head of a broken file
+	Key string
+	// Values are values before the first param
+	Values []*Value
+	Params []*Param
+}
+
+func NewRecord(key string, params ...*Param) *Record {
+	return &Record{
+		Key:    key,
+		Params: params,
+	}
+}
+
+// Id returns text of .id or .name, which identifies records of the same key.
+func (record *Record) Id() string {
+	for _, name := range []string{"id", "name"} {
+		if param := record.Param(name); param != nil && len(param.Values) > 0 {
+			return param.Values[0].Text()
+		}
+	}
+	return ""
+}
+
+// Param returns the first param of name.
+func (record *Record) Param(name string) *Param {
+	for _, param := range record.Params {
+		if param.Name == name {
+			return param
+		}
+	}
+	return nil
+}
+
+// Get returns texts of param values.
+func (record *Record) Get(name string) (texts []string) {
+	if param := record.Param(name); param != nil {
+		for _, value := range param.Values {
+			texts = append(texts, value.Text())
+		}
+	}
+	return
+}
+
+// Set replaces values of param, the param is appended when it is not existed.
+func (record *Record) Set(name string, values ...*Value) {
+	if param := record.Param(name); param != nil {
+		param.Set(values...)
+		return
+	}
+	record.Params = append(record.Params, NewParam(name, values...))
+}
+
+// Remove removes all params of name.
+func (record *Record) Remove(name string) {
+	params := record.Params[:0]
+	for _, param := range record.Params {
+		if param.Name != name {
+			params = append(params, param)
+		}
+	}
+	record.Params = params
+}
+
+func (record *Record) write(b *strings.Builder) {
+	b.WriteString(record.Space)
+	if record.Key != "" {
+		b.WriteString(record.Key)
+		b.WriteByte(':')
+	}
+	for _, value := range record.Values {
+		value.write(b)
+	}
+	for _, param := range record.Params {
+		param.write(b)
+	}
+}
+
+// String returns text of record without its leading space.
+func (record *Record) String() string {
+	b := strings.Builder{}
+	r := *record
+	r.Space = ""
+	r.write(&b)
+	return b.String()
+}
+
+type Param struct {
+	Space string
+	// Name is without '.'
+	Name   string
+	Values []*Value
+}
+
+func NewParam(name string, values ...*Value) *Param {
+	return &Param{
+		Space:  " ",
+		Name:   name,
+		Values: values,
+	}
+}
+
+// Set replaces values, spaces before values are kept as possible.
+func (param *Param) Set(values ...*Value) {
+	for i, value := range values {
+		if i < len(param.Values) && value.Space == " " {
+			value.Space = param.Values[i].Space
+		}
+	}
+	param.Values = values
+}
+
+func (param *Param) write(b *strings.Builder) {
+	b.WriteString(param.Space)
+	b.WriteByte('.')
+	b.WriteString(param.Name)
+	for _, value := range param.Values {
+		value.write(b)
+	}
+}
+
+// String returns text of param without its leading space.
+func (param *Param) String() string {
+	b := strings.Builder{}
+	p := *param
+	p.Space = ""
+	p.write(&b)
+	return b.String()
+}
+
+func (param *Param) Texts() (texts []string) {
+	for _, value := range param.Values {
+		texts = append(texts, value.Text())
+	}
+	return
+}
@@ -0,0 +1,94 @@
+package darkest_test
+
+import (
+	"strings"
+	"testing"
+
+	"DarkestDungeonModBoxLite/backend/pkg/darkest"
+)
+
+const crusader = "// crusader\r\n" +
+	"resistances: .stun 40% .poison 30%  .bleed 30% .disease 30%\r\n" +
+	"weapon: .name \"crusader_weapon_0\" .atk 0% .dmg 6 12 .crit 3% .spd 1\r\n" +
+	"\r\n" +
+	"combat_skill: .id \"smite\" .level 0 .type \"melee\" .atk 85% .dmg 0% .crit 0% .launch 21 .target 12 .is_crit_valid True // first level\r\n" +
+	"combat_skill: .id \"zealous_accusation\" .level 0 .effect \"Stun 1\" \"Kill Unholy\"\r\n" +
+	"\t.move 1 0\r\n" +
+	"tag: .id \"religious\"\r\n"
+
+func TestParseString(t *testing.T) {
+	file := darkest.ParseString(crusader)
+	if file.String() != crusader {
+		t.Fatal("text is not kept")
+	}
+	for _, record := range file.Records {
+		t.Log(record.Key, record.Id(), record.String())
+	}
+	skills := file.Find("combat_skill")
+	if len(skills) != 2 {
+		t.Fatal("expect 2 combat skills, got", len(skills))
+	}
+	if effects := skills[1].Get("effect"); len(effects) != 2 || effects[1] != "Kill Unholy" {
+		t.Error("string values are not parsed", effects)
+	}
+	if move := skills[1].Get("move"); len(move) != 2 {
+		t.Error("continued line is not parsed", move)
+	}
+	atk, ok := skills[0].Param("atk").Values[0].Number()
+	if !ok || atk != 85 || skills[0].Param("atk").Values[0].Kind() != darkest.PercentKind {
+		t.Error("percent is not parsed")
+	}
+	if b, ok := skills[0].Param("is_crit_valid").Values[0].Bool(); !ok || !b {
+		t.Error("bool is not parsed")
+	}
+	if dmg := file.Find("weapon")[0].Get("dmg"); len(dmg) != 2 || dmg[1] != "12" {
+		t.Error("multiple values are not parsed", dmg)
+	}
+}
+
+func TestFile_Edit(t *testing.T) {
+	file := darkest.ParseString(crusader)
+	smite := file.Find("combat_skill")[0]
+	smite.Set("atk", darkest.NewPercent(90))
+	smite.Set("heal", darkest.NewNumber(2), darkest.NewNumber(4))
+	file.Add(darkest.NewRecord("tag", darkest.NewParam("id", darkest.NewString("holy"))))
+	text := file.String()
+	t.Log("\n" + text)
+	if !strings.Contains(text, ".atk 90% .dmg 0%") {
+		t.Error("value is not replaced in place")
+	}
+	if !strings.HasSuffix(text, "tag: .id \"religious\"\r\ntag: .id \"holy\"\r\n") {
+		t.Error("record is not appended")
+	}
+	reparsed := darkest.ParseString(text)
+	if reparsed.String() != text || len(reparsed.Records) != len(file.Records) {
+		t.Error("edited text is not parsed as same")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	base := darkest.ParseString("combat_skill: .id \"smite\" .level 0 .atk 85%\n" +
+		"combat_skill: .id \"stunning_blow\" .level 0 .atk 90%\n" +
+		"combat_skill: .id \"holy_lance\" .level 0 .atk 85%\n")
+	a := darkest.ParseString("combat_skill: .id \"smite\" .level 0 .atk 95%\n" +
+		"combat_skill: .id \"stunning_blow\" .level 0 .atk 90%\n" +
+		"combat_skill: .id \"holy_lance\" .level 0 .atk 80%\n")
+	b := darkest.ParseString("combat_skill: .id \"smite\" .level 0  .atk 85%\n" +
+		"combat_skill: .id \"stunning_blow\" .level 0 .atk 100%\n" +
+		"combat_skill: .id \"holy_lance\" .level 0 .atk 75%\n" +
+		"combat_skill: .id \"inspiring_cry\" .level 0 .heal 1 2\n")
+	merged, conflicts := darkest.Merge(base, []darkest.Source{{Name: "a", File: a}, {Name: "b", File: b}}, func(conflict darkest.Conflict) int {
+		return conflict.Sources[0]
+	})
+	t.Log("\n" + merged.String())
+	if len(conflicts) != 1 || conflicts[0].Key != "combat_skill holy_lance 0" {
+		t.Fatal("expect conflict of holy_lance", conflicts)
+	}
+	expect := "combat_skill: .id \"smite\" .level 0 .atk 95%\n" +
+		"combat_skill: .id \"stunning_blow\" .level 0 .atk 100%\n" +
+		"combat_skill: .id \"holy_lance\" .level 0 .atk 80%\n" +
+		"combat_skill: .id \"inspiring_cry\" .level 0 .heal 1 2\n"
+	if merged.String() != expect {
+		t.Error("unexpected merged file")
+	}
+}
@@ -0,0 +1,163 @@
+package darkest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Identity identifies a record in file, such as "combat_skill smite 0" for .id "smite" .level 0.
+// records of the same identity are numbered by occurrence, such as "tag #1".
+func (record *Record) Identity() string {
+	b := strings.Builder{}
+	b.WriteString(record.Key)
+	if id := record.Id(); id != "" {
+		b.WriteByte(' ')
+		b.WriteString(id)
+	}
+	if level := record.Get("level"); len(level) > 0 {
+		b.WriteByte(' ')
+		b.WriteString(level[0])
+	}
+	return b.String()
+}
+
+// Normalized returns text of record with single spaces and without comments, for comparing.
+func (record *Record) Normalized() string {
+	b := strings.Builder{}
+	b.WriteString(record.Key)
+	b.WriteByte(':')
+	for _, value := range record.Values {
+		b.WriteByte(' ')
+		b.WriteString(value.Raw)
+	}
+	for _, param := range record.Params {
+		b.WriteString(" .")
+		b.WriteString(param.Name)
+		for _, value := range param.Values {
+			b.WriteByte(' ')
+			b.WriteString(value.Raw)
+		}
+	}
+	return b.String()
+}
+
+// identities returns identities of records in order, and records by identity.
+func (file *File) identities() (keys []string, records map[string]*Record) {
+	records = make(map[string]*Record, len(file.Records))
+	counts := make(map[string]int, len(file.Records))
+	for _, record := range file.Records {
+		key := record.Identity()
+		if n := counts[key]; n > 0 {
+			counts[key] = n + 1
+			key = fmt.Sprintf("%s #%d", key, n)
+		} else {
+			counts[key] = 1
+		}
+		keys = append(keys, key)
+		records[key] = record
+	}
+	return
+}
+
+type Source struct {
+	Name string
+	File *File
+}
+
+// Conflict
+// sources changed the same record of base differently, Records are the changed records of Sources.
+type Conflict struct {
+	Key     string
+	Base    *Record
+	Sources []int
+	Records []*Record
+}
+
+// Merge merges records of sources changed from base, later sources are after earlier ones.
+// a record changed by one source, or changed equally by many, is merged,
+// otherwise it is a conflict, choose returns the index of source which wins (by default the last one).
+// base may be nil, then records which are not equal in sources are conflicts.
+func Merge(base *File, sources []Source, choose func(conflict Conflict) int) (merged *File, conflicts []Conflict) {
+	if base == nil {
+		base = &File{}
+	}
+	baseKeys, baseRecords := base.identities()
+	sourceRecords := make([]map[string]*Record, len(sources))
+	order := append([]string(nil), baseKeys...)
+	seen := make(map[string]bool, len(baseKeys))
+	for _, key := range baseKeys {
+		seen[key] = true
+	}
+	for i, source := range sources {
+		keys, records := source.File.identities()
+		sourceRecords[i] = records
+		for _, key := range keys {
+			if !seen[key] {
+				seen[key] = true
+				order = append(order, key)
+			}
+		}
+	}
+
+	merged = &File{Trailing: base.Trailing}
+	for _, key := range order {
+		baseRecord := baseRecords[key]
+		conflict := Conflict{Key: key, Base: baseRecord}
+		for i := range sources {
+			record := sourceRecords[i][key]
+			if !changed(baseRecord, record) {
+				continue
+			}
+			conflict.Sources = append(conflict.Sources, i)
+			conflict.Records = append(conflict.Records, record)
+		}
+		result := baseRecord
+		switch {
+		case len(conflict.Sources) == 0:
+		case sameRecords(conflict.Records):
+			result = conflict.Records[0]
+		default:
+			winner := conflict.Sources[len(conflict.Sources)-1]
+			if choose != nil {
+				winner = choose(conflict)
+			}
+			result = sourceRecords[winner][key]
+			conflicts = append(conflicts, conflict)
+		}
+		if result == nil {
+			continue
+		}
+		copied := *result
+		if len(merged.Records) == 0 {
+			copied.Space = strings.TrimLeft(copied.Space, "\r\n")
+		} else if !strings.Contains(copied.Space, "\n") {
+			copied.Space = base.lineBreak() + strings.TrimLeft(copied.Space, " \t")
+		}
+		merged.Records = append(merged.Records, &copied)
+	}
+	if merged.Trailing == "" && len(sources) > 0 {
+		merged.Trailing = sources[len(sources)-1].File.Trailing
+	}
+	return
+}
+
+// changed returns whether record of source differs from base,
+// an absent record is not a change, since mods may ship a part of file only.
+func changed(base *Record, record *Record) bool {
+	if record == nil {
+		return false
+	}
+	if base == nil {
+		return true
+	}
+	return base.Normalized() != record.Normalized()
+}
+
+func sameRecords(records []*Record) bool {
+	for _, record := range records[1:] {
+		if record.Normalized() != records[0].Normalized() {
+			return false
+		}
+	}
+	return true
+}
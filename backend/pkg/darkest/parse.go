@@ -0,0 +1,128 @@
+package darkest
+
+import (
+	"io"
+	"strings"
+)
+
+func Parse(r io.Reader) (file *File, err error) {
+	p, readErr := io.ReadAll(r)
+	if readErr != nil {
+		err = readErr
+		return
+	}
+	file = ParseString(string(p))
+	return
+}
+
+// ParseString never fails, texts which are not records are kept in a record without key.
+func ParseString(text string) (file *File) {
+	file = &File{}
+	lx := lexer{text: text}
+	var (
+		record *Record
+		param  *Param
+	)
+	for {
+		space, token, lineStart := lx.next()
+		if token == "" {
+			file.Trailing = space
+			return
+		}
+		switch {
+		case lineStart && isKey(token):
+			record = &Record{Space: space, Key: strings.TrimSuffix(token, ":")}
+			param = nil
+			file.Records = append(file.Records, record)
+		case isParam(token):
+			if record == nil {
+				record = &Record{}
+				file.Records = append(file.Records, record)
+			}
+			param = &Param{Space: space, Name: token[1:]}
+			record.Params = append(record.Params, param)
+		default:
+			value := &Value{Space: space, Raw: token}
+			if record == nil {
+				record = &Record{}
+				file.Records = append(file.Records, record)
+			}
+			if param == nil {
+				record.Values = append(record.Values, value)
+			} else {
+				param.Values = append(param.Values, value)
+			}
+		}
+	}
+}
+
+func isKey(token string) bool {
+	return len(token) > 1 && strings.HasSuffix(token, ":") && !strings.HasPrefix(token, `"`)
+}
+
+// isParam such as .atk, but not .5
+func isParam(token string) bool {
+	if len(token) < 2 || token[0] != '.' {
+		return false
+	}
+	c := token[1]
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+type lexer struct {
+	text string
+	pos  int
+}
+
+// next returns spaces and comments before the token, and whether the token is the first of a line.
+// token is empty at the end.
+func (lx *lexer) next() (space string, token string, lineStart bool) {
+	beg := lx.pos
+	lineStart = lx.pos == 0
+	for lx.pos < len(lx.text) {
+		c := lx.text[lx.pos]
+		if c == '\n' {
+			lineStart = true
+			lx.pos++
+			continue
+		}
+		if c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v' {
+			lx.pos++
+			continue
+		}
+		if strings.HasPrefix(lx.text[lx.pos:], "//") {
+			if idx := strings.IndexByte(lx.text[lx.pos:], '\n'); idx > -1 {
+				lx.pos += idx
+			} else {
+				lx.pos = len(lx.text)
+			}
+			continue
+		}
+		break
+	}
+	space = lx.text[beg:lx.pos]
+	if lx.pos == len(lx.text) {
+		return
+	}
+	beg = lx.pos
+	if lx.text[lx.pos] == '"' {
+		// quoted string ends at the next quote or line end
+		lx.pos++
+		for lx.pos < len(lx.text) && lx.text[lx.pos] != '"' && lx.text[lx.pos] != '\n' {
+			lx.pos++
+		}
+		if lx.pos < len(lx.text) && lx.text[lx.pos] == '"' {
+			lx.pos++
+		}
+	} else {
+		for lx.pos < len(lx.text) {
+			c := lx.text[lx.pos]
+			if c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == '\v' {
+				break
+			}
+			lx.pos++
+		}
+	}
+	token = lx.text[beg:lx.pos]
+	return
+}
@@ -0,0 +1,102 @@
+package darkest
+
+import (
+	"strconv"
+	"strings"
+)
+
+type Kind int
+
+const (
+	WordKind Kind = iota
+	StringKind
+	NumberKind
+	PercentKind
+	BoolKind
+)
+
+type Value struct {
+	Space string
+	// Raw is the value as written, strings are quoted
+	Raw string
+}
+
+func NewValue(raw string) *Value {
+	return &Value{Space: " ", Raw: raw}
+}
+
+func NewString(s string) *Value {
+	return NewValue(`"` + s + `"`)
+}
+
+func NewNumber(n float64) *Value {
+	return NewValue(strconv.FormatFloat(n, 'f', -1, 64))
+}
+
+// NewPercent returns value such as 85%, n is 85 but not 0.85.
+func NewPercent(n float64) *Value {
+	return NewValue(strconv.FormatFloat(n, 'f', -1, 64) + "%")
+}
+
+func NewBool(b bool) *Value {
+	if b {
+		return NewValue("True")
+	}
+	return NewValue("False")
+}
+
+func (value *Value) Kind() Kind {
+	raw := value.Raw
+	switch {
+	case strings.HasPrefix(raw, `"`):
+		return StringKind
+	case strings.EqualFold(raw, "true") || strings.EqualFold(raw, "false"):
+		return BoolKind
+	case strings.HasSuffix(raw, "%"):
+		if _, err := strconv.ParseFloat(strings.TrimSuffix(raw, "%"), 64); err == nil {
+			return PercentKind
+		}
+	default:
+		if _, err := strconv.ParseFloat(raw, 64); err == nil {
+			return NumberKind
+		}
+	}
+	return WordKind
+}
+
+// Text returns the value without quotes.
+func (value *Value) Text() string {
+	if value.Kind() == StringKind {
+		return strings.TrimSuffix(strings.TrimPrefix(value.Raw, `"`), `"`)
+	}
+	return value.Raw
+}
+
+// Number returns number of numbers and percents, 85% is 85.
+func (value *Value) Number() (n float64, ok bool) {
+	var err error
+	switch value.Kind() {
+	case NumberKind:
+		n, err = strconv.ParseFloat(value.Raw, 64)
+	case PercentKind:
+		n, err = strconv.ParseFloat(strings.TrimSuffix(value.Raw, "%"), 64)
+	default:
+		return
+	}
+	ok = err == nil
+	return
+}
+
+func (value *Value) Bool() (b bool, ok bool) {
+	if value.Kind() != BoolKind {
+		return
+	}
+	b = strings.EqualFold(value.Raw, "true")
+	ok = true
+	return
+}
+
+func (value *Value) write(b *strings.Builder) {
+	b.WriteString(value.Space)
+	b.WriteString(value.Raw)
+}
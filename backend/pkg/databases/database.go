@@ -17,6 +17,7 @@ import (
 	"github.com/cespare/xxhash/v2"
 	"github.com/rs/xid"
 	"github.com/tidwall/buntdb"
+	"github.com/tidwall/gjson"
 )
 
 const (
@@ -41,6 +42,13 @@ func CreateIndex(name, pattern string, less ...func(a, b string) bool) Index {
 	}
 }
 
+// IndexJSONTime orders by time of json field path, such as RFC3339 text.
+func IndexJSONTime(path string) func(a, b string) bool {
+	return func(a, b string) bool {
+		return gjson.Get(a, path).Time().Before(gjson.Get(b, path).Time())
+	}
+}
+
 func New(filename string, indexes ...Index) (db *Database, err error) {
 	kv, kvErr := buntdb.Open(filename)
 	if kvErr != nil {
@@ -165,6 +173,52 @@ func (db *Database) Remove(key string) (err error) {
 	})
 }
 
+type Tx struct {
+	tx *buntdb.Tx
+}
+
+func (tx *Tx) Update(key string, value any) (err error) {
+	b, bErr := json.Marshal(value)
+	if bErr != nil {
+		err = bErr
+		return
+	}
+	_, _, err = tx.tx.Set(key, string(b), nil)
+	return
+}
+
+func (tx *Tx) Remove(key string) (err error) {
+	if _, err = tx.tx.Delete(key); err != nil {
+		if errors.Is(err, buntdb.ErrNotFound) {
+			err = nil
+		}
+	}
+	return
+}
+
+func (tx *Tx) Get(key string, value any) (has bool, err error) {
+	val, getErr := tx.tx.Get(key)
+	if getErr != nil {
+		if errors.Is(getErr, buntdb.ErrNotFound) {
+			return
+		}
+		err = getErr
+		return
+	}
+	if err = json.Unmarshal([]byte(val), value); err != nil {
+		return
+	}
+	has = true
+	return
+}
+
+// Transaction runs fn in a single writable transaction, all writes are discarded when fn returns an error.
+func (db *Database) Transaction(fn func(tx *Tx) error) (err error) {
+	return db.kv.Update(func(tx *buntdb.Tx) error {
+		return fn(&Tx{tx: tx})
+	})
+}
+
 func (db *Database) Get(key string, value any) (has bool, err error) {
 	err = db.kv.View(func(tx *buntdb.Tx) error {
 		val, getErr := tx.Get(key)
@@ -207,6 +261,7 @@ func (db *Database) AscendKey(key string, values any, filter func(key, value str
 			buf.WriteByte(',')
 		}
 		buf.WriteString(value)
+		i++
 		return true
 	})
 	if err != nil {
@@ -233,6 +288,7 @@ func (db *Database) AscendKeys(pattern string, values any, filter func(key, valu
 				buf.WriteByte(',')
 			}
 			buf.WriteString(value)
+			i++
 			return true
 		})
 	})
@@ -260,6 +316,35 @@ func (db *Database) Ascend(index string, values any, filter func(key, value stri
 				buf.WriteByte(',')
 			}
 			buf.WriteString(value)
+			i++
+			return true
+		})
+	})
+	if err != nil {
+		return
+	}
+	buf.WriteByte(']')
+	if buf.Len() == 2 {
+		return
+	}
+	err = json.Unmarshal(buf.Bytes(), &values)
+	return
+}
+
+func (db *Database) Descend(index string, values any, filter func(key, value string) bool) (err error) {
+	buf := bytes.NewBuffer(nil)
+	buf.WriteByte('[')
+	err = db.kv.View(func(tx *buntdb.Tx) error {
+		i := 0
+		return tx.Descend(index, func(key, value string) bool {
+			if filter != nil && !filter(key, value) {
+				return true
+			}
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(value)
+			i++
 			return true
 		})
 	})
@@ -316,6 +401,7 @@ func (db *Database) Range(index string, beg uint, end uint, values any, filter f
 				buf.WriteByte(',')
 			}
 			buf.WriteString(value)
+			i++
 			return true
 		})
 	})
@@ -0,0 +1,259 @@
+// Package diffs compares texts by lines and formats unified diffs.
+package diffs
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+type Op int
+
+const (
+	Equal Op = iota
+	Insert
+	Delete
+)
+
+// Edit
+// a line of a (Equal, Delete) or b (Insert), indexes start from 0, and are -1 when the line is not in the side.
+type Edit struct {
+	Op   Op
+	A    int
+	B    int
+	Text string
+}
+
+// SplitLines splits text by "\n", "\r\n" is treated as "\n", and the last empty line is dropped.
+func SplitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	lines := strings.Split(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// maxSnakeCost is the max cost of searching a middle snake, a range costing more is taken as replaced,
+// so time is bounded when texts are almost different.
+const maxSnakeCost = 4096
+
+// Lines returns the shortest edits from a to b (myers), in linear space by dividing at middle snakes.
+// deletes come before inserts in each change, and ranges costing more than maxSnakeCost are replaced as a whole.
+func Lines(a []string, b []string) (edits []Edit) {
+	n, m := len(a), len(b)
+	if n+m == 0 {
+		return
+	}
+	offset := min((n+m+1)/2, maxSnakeCost) + 1
+	d := &differ{
+		a:      a,
+		b:      b,
+		vf:     make([]int, 2*offset+1),
+		vb:     make([]int, 2*offset+1),
+		offset: offset,
+		edits:  make([]Edit, 0, max(n, m)),
+	}
+	d.compare(0, n, 0, m)
+	edits = d.edits
+	// deletes first in each change
+	for i := 0; i < len(edits); i++ {
+		if edits[i].Op == Equal {
+			continue
+		}
+		j := i
+		for j < len(edits) && edits[j].Op != Equal {
+			j++
+		}
+		slices.SortStableFunc(edits[i:j], func(x, y Edit) int {
+			if x.Op == y.Op {
+				return 0
+			}
+			if x.Op == Delete {
+				return -1
+			}
+			return 1
+		})
+		i = j
+	}
+	return
+}
+
+// differ
+// vf and vb are the furthest x of forward and backward paths on diagonals, which are reused by ranges.
+type differ struct {
+	a      []string
+	b      []string
+	vf     []int
+	vb     []int
+	offset int
+	edits  []Edit
+}
+
+func (d *differ) equal(x int, y int) {
+	d.edits = append(d.edits, Edit{Op: Equal, A: x, B: y, Text: d.a[x]})
+}
+
+func (d *differ) replace(aLo, aHi, bLo, bHi int) {
+	for x := aLo; x < aHi; x++ {
+		d.edits = append(d.edits, Edit{Op: Delete, A: x, B: -1, Text: d.a[x]})
+	}
+	for y := bLo; y < bHi; y++ {
+		d.edits = append(d.edits, Edit{Op: Insert, A: -1, B: y, Text: d.b[y]})
+	}
+}
+
+// compare appends edits from a[aLo:aHi] to b[bLo:bHi].
+func (d *differ) compare(aLo, aHi, bLo, bHi int) {
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		d.equal(aLo, bLo)
+		aLo++
+		bLo++
+	}
+	suffix := 0
+	for aLo < aHi-suffix && bLo < bHi-suffix && d.a[aHi-suffix-1] == d.b[bHi-suffix-1] {
+		suffix++
+	}
+	aHi, bHi = aHi-suffix, bHi-suffix
+	if aLo == aHi || bLo == bHi {
+		d.replace(aLo, aHi, bLo, bHi)
+	} else if x, y, u, v, ok := d.middleSnake(aLo, aHi, bLo, bHi); ok {
+		d.compare(aLo, x, bLo, y)
+		for ; x < u; x, y = x+1, y+1 {
+			d.equal(x, y)
+		}
+		d.compare(u, aHi, v, bHi)
+	} else {
+		d.replace(aLo, aHi, bLo, bHi)
+	}
+	for i := 0; i < suffix; i++ {
+		d.equal(aHi+i, bHi+i)
+	}
+}
+
+// middleSnake finds the snake from (x, y) to (u, v) in the middle of the shortest path,
+// where the forward and backward paths overlap. ok is false when it costs more than maxSnakeCost.
+func (d *differ) middleSnake(aLo, aHi, bLo, bHi int) (x, y, u, v int, ok bool) {
+	n, m := aHi-aLo, bHi-bLo
+	delta := n - m
+	odd := delta&1 != 0
+	off := d.offset
+	vf, vb := d.vf, d.vb
+	vf[off+1], vb[off+1] = 0, 0
+	for cost := 0; cost <= min((n+m+1)/2, maxSnakeCost); cost++ {
+		for k := -cost; k <= cost; k += 2 {
+			var fx int
+			if k == -cost || (k != cost && vf[off+k-1] < vf[off+k+1]) {
+				fx = vf[off+k+1]
+			} else {
+				fx = vf[off+k-1] + 1
+			}
+			fy := fx - k
+			sx, sy := fx, fy
+			for fx < n && fy < m && d.a[aLo+fx] == d.b[bLo+fy] {
+				fx++
+				fy++
+			}
+			vf[off+k] = fx
+			// the backward diagonal of k is delta-k
+			if odd && delta-k >= -(cost-1) && delta-k <= cost-1 && fx+vb[off+delta-k] >= n {
+				return aLo + sx, bLo + sy, aLo + fx, bLo + fy, true
+			}
+		}
+		for k := -cost; k <= cost; k += 2 {
+			var bx int
+			if k == -cost || (k != cost && vb[off+k-1] < vb[off+k+1]) {
+				bx = vb[off+k+1]
+			} else {
+				bx = vb[off+k-1] + 1
+			}
+			by := bx - k
+			sx, sy := bx, by
+			for bx < n && by < m && d.a[aHi-1-bx] == d.b[bHi-1-by] {
+				bx++
+				by++
+			}
+			vb[off+k] = bx
+			if !odd && delta-k >= -cost && delta-k <= cost && bx+vf[off+delta-k] >= n {
+				return aHi - bx, bHi - by, aHi - sx, bHi - sy, true
+			}
+		}
+	}
+	return
+}
+
+// Unified formats edits from a to b as an unified diff with context lines, it is empty when they are equal.
+func Unified(aName string, bName string, a string, b string, context int) string {
+	edits := Lines(SplitLines(a), SplitLines(b))
+	// lines of a and b before each edit
+	aPos := make([]int, len(edits)+1)
+	bPos := make([]int, len(edits)+1)
+	for i, edit := range edits {
+		aPos[i+1], bPos[i+1] = aPos[i], bPos[i]
+		if edit.Op != Insert {
+			aPos[i+1]++
+		}
+		if edit.Op != Delete {
+			bPos[i+1]++
+		}
+	}
+	buf := strings.Builder{}
+	for _, h := range hunks(edits, context) {
+		if buf.Len() == 0 {
+			buf.WriteString(fmt.Sprintf("--- %s\n+++ %s\n", aName, bName))
+		}
+		aLines := aPos[h.end] - aPos[h.beg]
+		bLines := bPos[h.end] - bPos[h.beg]
+		buf.WriteString(fmt.Sprintf("@@ -%s +%s @@\n", formatRange(aPos[h.beg], aLines), formatRange(bPos[h.beg], bLines)))
+		for _, edit := range edits[h.beg:h.end] {
+			switch edit.Op {
+			case Equal:
+				buf.WriteByte(' ')
+			case Insert:
+				buf.WriteByte('+')
+			case Delete:
+				buf.WriteByte('-')
+			}
+			buf.WriteString(edit.Text)
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.String()
+}
+
+// hunk is edits[beg:end]
+type hunk struct {
+	beg int
+	end int
+}
+
+// hunks groups changes with context lines around, changes closer than 2*context are merged into one hunk.
+func hunks(edits []Edit, context int) (v []hunk) {
+	for i := 0; i < len(edits); i++ {
+		if edits[i].Op == Equal {
+			continue
+		}
+		beg := max(i-context, 0)
+		end := min(i+1+context, len(edits))
+		if len(v) > 0 && v[len(v)-1].end >= beg {
+			v[len(v)-1].end = end
+			continue
+		}
+		v = append(v, hunk{beg: beg, end: end})
+	}
+	return
+}
+
+// formatRange formats 1-based start line and count, start is the line before when count is 0.
+func formatRange(before int, lines int) string {
+	if lines == 0 {
+		return fmt.Sprintf("%d,0", before)
+	}
+	if lines == 1 {
+		return fmt.Sprintf("%d", before+1)
+	}
+	return fmt.Sprintf("%d,%d", before+1, lines)
+}
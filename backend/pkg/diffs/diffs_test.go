@@ -0,0 +1,131 @@
+package diffs_test
+
+import (
+	"runtime"
+	"strconv"
+	"testing"
+
+	"DarkestDungeonModBoxLite/backend/pkg/diffs"
+)
+
+func TestLines(t *testing.T) {
+	a := []string{"a", "b", "c", "a", "b", "b", "a"}
+	b := []string{"c", "b", "a", "b", "a", "c"}
+	edits := diffs.Lines(a, b)
+	changes := 0
+	ai, bi := 0, 0
+	for _, edit := range edits {
+		switch edit.Op {
+		case diffs.Equal:
+			if a[ai] != b[bi] {
+				t.Fatal("equal lines are not equal", ai, bi)
+			}
+			ai++
+			bi++
+		case diffs.Delete:
+			changes++
+			ai++
+		case diffs.Insert:
+			changes++
+			bi++
+		}
+	}
+	if ai != len(a) || bi != len(b) {
+		t.Fatal("edits do not cover both sides")
+	}
+	if changes != 5 {
+		t.Error("expect 5 changes, got", changes)
+	}
+}
+
+func TestUnified(t *testing.T) {
+	a := "skill: .id \"smite\" .level 0 .atk 85%\nskill: .id \"smite\" .level 1 .atk 90%\nskill: .id \"stunning_blow\" .level 0\n"
+	b := "skill: .id \"smite\" .level 0 .atk 90%\nskill: .id \"smite\" .level 1 .atk 90%\nskill: .id \"stunning_blow\" .level 0\nskill: .id \"holy_lance\" .level 0\n"
+	unified := diffs.Unified("a/crusader.info.darkest", "b/crusader.info.darkest", a, b, 1)
+	t.Log("\n" + unified)
+	expect := "--- a/crusader.info.darkest\n+++ b/crusader.info.darkest\n" +
+		"@@ -1,3 +1,4 @@\n" +
+		"-skill: .id \"smite\" .level 0 .atk 85%\n" +
+		"+skill: .id \"smite\" .level 0 .atk 90%\n" +
+		" skill: .id \"smite\" .level 1 .atk 90%\n" +
+		" skill: .id \"stunning_blow\" .level 0\n" +
+		"+skill: .id \"holy_lance\" .level 0\n"
+	if unified != expect {
+		t.Error("unexpected unified diff")
+	}
+	// hunks are split when changes are far away
+	far := diffs.Unified("a", "b", "1\n2\n3\n4\n5\n6\n", "0\n2\n3\n4\n5\n7\n", 1)
+	t.Log("\n" + far)
+	if far != "--- a\n+++ b\n@@ -1,2 +1,2 @@\n-1\n+0\n 2\n@@ -5,2 +5,2 @@\n 5\n-6\n+7\n" {
+		t.Error("unexpected hunks")
+	}
+	if diffs.Unified("a", "b", a, a, 3) != "" {
+		t.Error("equal texts should have no diff")
+	}
+}
+
+func TestLines_Large(t *testing.T) {
+	// many scattered changes, and texts which are all different
+	a := make([]string, 200000)
+	b := make([]string, len(a))
+	for i := range a {
+		a[i] = strconv.Itoa(i)
+		b[i] = a[i]
+		if i%200 == 0 {
+			b[i] = "changed " + a[i]
+		}
+	}
+	c := make([]string, 50000)
+	for i := range c {
+		c[i] = "other " + strconv.Itoa(i)
+	}
+	for _, item := range []struct {
+		name    string
+		a       []string
+		b       []string
+		changes int
+	}{
+		{name: "scattered", a: a, b: b, changes: 2 * len(a) / 200},
+		{name: "different", a: a[:len(c)], b: c, changes: 2 * len(c)},
+	} {
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		edits := diffs.Lines(item.a, item.b)
+		runtime.ReadMemStats(&after)
+		allocated := after.TotalAlloc - before.TotalAlloc
+		t.Log(item.name, len(edits), "edits", allocated>>20, "MB allocated")
+		if allocated > 64<<20 {
+			t.Error(item.name, "allocated too much", allocated)
+		}
+		changes := 0
+		ai, bi := 0, 0
+		for _, edit := range edits {
+			switch edit.Op {
+			case diffs.Equal:
+				if edit.A != ai || edit.B != bi || item.a[ai] != item.b[bi] {
+					t.Fatal(item.name, "equal lines are not equal", ai, bi)
+				}
+				ai++
+				bi++
+			case diffs.Delete:
+				if edit.A != ai {
+					t.Fatal(item.name, "deleted line is out of order", ai)
+				}
+				changes++
+				ai++
+			case diffs.Insert:
+				if edit.B != bi {
+					t.Fatal(item.name, "inserted line is out of order", bi)
+				}
+				changes++
+				bi++
+			}
+		}
+		if ai != len(item.a) || bi != len(item.b) {
+			t.Fatal(item.name, "edits do not cover both sides")
+		}
+		if changes != item.changes {
+			t.Error(item.name, "expect", item.changes, "changes, got", changes)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package files
 
 import (
+	"io"
 	"os"
 	"path/filepath"
 )
@@ -27,7 +28,7 @@ func Mkdir(path string) (err error) {
 		return
 	}
 	if !exist {
-		err = os.MkdirAll(path, 0644)
+		err = os.MkdirAll(path, 0755)
 	}
 	return
 }
@@ -75,3 +76,37 @@ func InDesktop() (ok bool) {
 	ok = wd == desktop
 	return
 }
+
+// LinkOrCopy creates dst as a hard link of src, it falls back to copy when the filesystem refuses to link.
+func LinkOrCopy(src string, dst string) (linked bool, err error) {
+	if err = Mkdir(filepath.Dir(dst)); err != nil {
+		return
+	}
+	if linkErr := os.Link(src, dst); linkErr == nil {
+		linked = true
+		return
+	}
+	err = CopyFile(src, dst)
+	return
+}
+
+func CopyFile(src string, dst string) (err error) {
+	in, inErr := os.Open(src)
+	if inErr != nil {
+		err = inErr
+		return
+	}
+	defer in.Close()
+	out, outErr := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0644)
+	if outErr != nil {
+		err = outErr
+		return
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		_ = out.Close()
+		_ = os.Remove(dst)
+		return
+	}
+	err = out.Close()
+	return
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 )
@@ -85,6 +86,7 @@ func (df *DirFS) Dir(name string) (v *DirFS) {
 }
 
 func (df *DirFS) DirInfo(name string) (v *FileInfo, err error) {
+	name = filepath.ToSlash(name)
 	stat, statErr := fs.Stat(df.dir, name)
 	if statErr != nil {
 		err = statErr
@@ -95,7 +97,7 @@ func (df *DirFS) DirInfo(name string) (v *FileInfo, err error) {
 		return
 	}
 	v = &FileInfo{
-		Name:     name,
+		Name:     path.Base(name),
 		Filepath: filepath.Join(df.path, name),
 		Size:     stat.Size(),
 		IsDir:    stat.IsDir(),
@@ -111,13 +113,9 @@ func (df *DirFS) DirInfo(name string) (v *FileInfo, err error) {
 	}
 	v.Children = make([]*FileInfo, 0, len(entries))
 	for _, entry := range entries {
+		entryName := path.Join(name, entry.Name())
 		if entry.IsDir() {
-			subFS, subFSErr := NewDirFS(filepath.Join(df.path, entry.Name()))
-			if subFSErr != nil {
-				err = subFSErr
-				return
-			}
-			sub, subErr := subFS.DirInfo(".")
+			sub, subErr := df.DirInfo(entryName)
 			if subErr != nil {
 				err = subErr
 				return
@@ -125,7 +123,7 @@ func (df *DirFS) DirInfo(name string) (v *FileInfo, err error) {
 			v.Children = append(v.Children, sub)
 			continue
 		}
-		sub, subErr := df.FileInfo(entry.Name())
+		sub, subErr := df.FileInfo(entryName)
 		if subErr != nil {
 			err = subErr
 			return
@@ -136,6 +134,7 @@ func (df *DirFS) DirInfo(name string) (v *FileInfo, err error) {
 }
 
 func (df *DirFS) FileInfo(name string) (v *FileInfo, err error) {
+	name = filepath.ToSlash(name)
 	stat, statErr := fs.Stat(df.dir, name)
 	if statErr != nil {
 		err = statErr
@@ -146,7 +145,7 @@ func (df *DirFS) FileInfo(name string) (v *FileInfo, err error) {
 		return
 	}
 	v = &FileInfo{
-		Name:     name,
+		Name:     path.Base(name),
 		Filepath: filepath.Join(df.path, name),
 		Size:     stat.Size(),
 		IsDir:    stat.IsDir(),
@@ -155,6 +154,20 @@ func (df *DirFS) FileInfo(name string) (v *FileInfo, err error) {
 	return
 }
 
+// Files returns all files under info, names are relative to info and slash separated.
+func (info *FileInfo) Files() (names []string) {
+	for _, child := range info.Children {
+		if !child.IsDir {
+			names = append(names, child.Name)
+			continue
+		}
+		for _, name := range child.Files() {
+			names = append(names, child.Name+"/"+name)
+		}
+	}
+	return
+}
+
 func (df *DirFS) ReadFile(name string) (data []byte, err error) {
 	dir, file := filepath.Split(name)
 	if dir == "" {
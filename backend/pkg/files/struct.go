@@ -1,6 +1,7 @@
 package files
 
 import (
+	"context"
 	"io/fs"
 	"os"
 	"path/filepath"
@@ -55,6 +56,11 @@ func (st *Structure) Get(name string) (target Structure, has bool) {
 }
 
 func FileStructure(filename string) (v *Structure, err error) {
+	return FileStructureContext(context.Background(), filename)
+}
+
+// FileStructureContext is FileStructure which stops once ctx is done, ctx is checked before reading each dir.
+func FileStructureContext(ctx context.Context, filename string) (v *Structure, err error) {
 	stat, statErr := os.Stat(filename)
 	if statErr != nil {
 		err = statErr
@@ -69,6 +75,9 @@ func FileStructure(filename string) (v *Structure, err error) {
 		return
 	}
 	v.IsDir = true
+	if err = ctx.Err(); err != nil {
+		return
+	}
 
 	entries, dirErr := fs.ReadDir(os.DirFS(filename), ".")
 	if dirErr != nil {
@@ -76,7 +85,7 @@ func FileStructure(filename string) (v *Structure, err error) {
 		return
 	}
 	for _, entry := range entries {
-		child, childErr := FileStructure(filepath.Join(filename, entry.Name()))
+		child, childErr := FileStructureContext(ctx, filepath.Join(filename, entry.Name()))
 		if childErr != nil {
 			err = childErr
 			return
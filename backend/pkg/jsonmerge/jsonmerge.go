@@ -0,0 +1,214 @@
+package jsonmerge
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Object keeps keys in order of document, values are *Object, []any, string, json.Number, bool or nil.
+type Object struct {
+	Keys   []string
+	Values map[string]any
+}
+
+func NewObject() *Object {
+	return &Object{Values: make(map[string]any)}
+}
+
+func (object *Object) Get(key string) (value any, has bool) {
+	value, has = object.Values[key]
+	return
+}
+
+// Set sets value of key, the key is appended if absent.
+func (object *Object) Set(key string, value any) {
+	if _, has := object.Values[key]; !has {
+		object.Keys = append(object.Keys, key)
+	}
+	object.Values[key] = value
+}
+
+var ErrInvalidJson = errors.New("invalid json")
+
+// Parse reads one json value, objects keep the order of keys, the last one wins when a key is repeated.
+func Parse(p []byte) (value any, err error) {
+	decoder := json.NewDecoder(bytes.NewReader(bytes.TrimPrefix(p, []byte("\uFEFF"))))
+	decoder.UseNumber()
+	if value, err = parseValue(decoder); err != nil {
+		err = errors.Join(ErrInvalidJson, err)
+		return
+	}
+	if _, tokenErr := decoder.Token(); tokenErr != io.EOF {
+		value = nil
+		err = errors.Join(ErrInvalidJson, errors.New("unexpected content after value"))
+	}
+	return
+}
+
+func parseValue(decoder *json.Decoder) (value any, err error) {
+	token, tokenErr := decoder.Token()
+	if tokenErr != nil {
+		err = tokenErr
+		return
+	}
+	switch t := token.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			object := NewObject()
+			for decoder.More() {
+				keyToken, keyErr := decoder.Token()
+				if keyErr != nil {
+					err = keyErr
+					return
+				}
+				key, _ := keyToken.(string)
+				item, itemErr := parseValue(decoder)
+				if itemErr != nil {
+					err = itemErr
+					return
+				}
+				object.Set(key, item)
+			}
+			_, err = decoder.Token()
+			value = object
+		case '[':
+			array := make([]any, 0, 1)
+			for decoder.More() {
+				item, itemErr := parseValue(decoder)
+				if itemErr != nil {
+					err = itemErr
+					return
+				}
+				array = append(array, item)
+			}
+			_, err = decoder.Token()
+			value = array
+		default:
+			err = fmt.Errorf("unexpected %s", t)
+		}
+	default:
+		value = t
+	}
+	return
+}
+
+// Marshal writes value indented by tabs, with a line break at the end.
+func Marshal(value any) []byte {
+	b := bytes.Buffer{}
+	writeValue(&b, value, 0, true)
+	b.WriteByte('\n')
+	return b.Bytes()
+}
+
+// Text returns value as compact json in one line, for showing.
+func Text(value any) string {
+	b := bytes.Buffer{}
+	writeValue(&b, value, 0, false)
+	return b.String()
+}
+
+func writeValue(b *bytes.Buffer, value any, depth int, indent bool) {
+	newline := func(depth int) {
+		if indent {
+			b.WriteByte('\n')
+			b.WriteString(strings.Repeat("\t", depth))
+		}
+	}
+	switch v := value.(type) {
+	case *Object:
+		b.WriteByte('{')
+		for i, key := range v.Keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			newline(depth + 1)
+			writeString(b, key)
+			b.WriteByte(':')
+			if indent {
+				b.WriteByte(' ')
+			}
+			writeValue(b, v.Values[key], depth+1, indent)
+		}
+		if len(v.Keys) > 0 {
+			newline(depth)
+		}
+		b.WriteByte('}')
+	case []any:
+		b.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			newline(depth + 1)
+			writeValue(b, item, depth+1, indent)
+		}
+		if len(v) > 0 {
+			newline(depth)
+		}
+		b.WriteByte(']')
+	case string:
+		writeString(b, v)
+	case json.Number:
+		b.WriteString(v.String())
+	case bool:
+		b.WriteString(strconv.FormatBool(v))
+	default:
+		b.WriteString("null")
+	}
+}
+
+func writeString(b *bytes.Buffer, s string) {
+	encoder := json.NewEncoder(b)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(s)
+	// Encode ends with a line break
+	b.Truncate(b.Len() - 1)
+}
+
+// Equal compares values deeply, numbers are equal by value, such as 1 and 1.0.
+func Equal(a any, b any) bool {
+	switch x := a.(type) {
+	case *Object:
+		y, ok := b.(*Object)
+		if !ok || len(x.Values) != len(y.Values) {
+			return false
+		}
+		for key, value := range x.Values {
+			other, has := y.Values[key]
+			if !has || !Equal(value, other) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		y, ok := b.([]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !Equal(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		if x == y {
+			return true
+		}
+		fx, xErr := x.Float64()
+		fy, yErr := y.Float64()
+		return xErr == nil && yErr == nil && fx == fy
+	default:
+		return a == b
+	}
+}
@@ -0,0 +1,92 @@
+package jsonmerge_test
+
+import (
+	"strings"
+	"testing"
+
+	"DarkestDungeonModBoxLite/backend/pkg/jsonmerge"
+)
+
+const campingSkills = `{
+	"configuration": {"class_specific_number_of_classes_threshold": 1},
+	"skills": [
+		{"id": "encourage", "cost": 2, "use_limit": 1, "hero_classes": ["crusader"]},
+		{"id": "first_aid", "cost": 2, "use_limit": 1}
+	]
+}`
+
+func parse(t *testing.T, text string) any {
+	value, err := jsonmerge.Parse([]byte(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+
+func TestParse(t *testing.T) {
+	value := parse(t, "\uFEFF"+campingSkills)
+	object := value.(*jsonmerge.Object)
+	if strings.Join(object.Keys, ",") != "configuration,skills" {
+		t.Error("keys are not in order", object.Keys)
+	}
+	text := string(jsonmerge.Marshal(value))
+	t.Log("\n" + text)
+	if !jsonmerge.Equal(parse(t, text), value) {
+		t.Error("marshaled json is not parsed as same")
+	}
+	if jsonmerge.Text(parse(t, `{"a": [1, 2.0, "<b>"], "b": {}}`)) != `{"a":[1,2.0,"<b>"],"b":{}}` {
+		t.Error("compact text is wrong", jsonmerge.Text(value))
+	}
+	if !jsonmerge.Equal(parse(t, `{"a": 1, "b": 2}`), parse(t, `{"b": 2.0, "a": 1}`)) {
+		t.Error("equal objects are not equal")
+	}
+	if _, err := jsonmerge.Parse([]byte(`{"a": 1} {}`)); err == nil {
+		t.Error("content after value is parsed")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	base := parse(t, campingSkills)
+	a := parse(t, `{
+	"configuration": {"class_specific_number_of_classes_threshold": 1},
+	"skills": [
+		{"id": "encourage", "cost": 1, "use_limit": 1, "hero_classes": ["crusader"]},
+		{"id": "first_aid", "cost": 2, "use_limit": 1},
+		{"id": "pray", "cost": 3}
+	]
+}`)
+	b := parse(t, `{
+	"configuration": {"class_specific_number_of_classes_threshold": 2},
+	"skills": [
+		{"id": "encourage", "cost": 3, "use_limit": 2, "hero_classes": ["crusader"]},
+		{"id": "first_aid", "cost": 2, "use_limit": 1},
+		{"id": "sing", "cost": 1}
+	]
+}`)
+	merged, collisions := jsonmerge.Merge(base, []jsonmerge.Source{{Name: "a", Value: a}, {Name: "b", Value: b}})
+	t.Log("\n" + string(jsonmerge.Marshal(merged)))
+	expect := parse(t, `{
+	"configuration": {"class_specific_number_of_classes_threshold": 2},
+	"skills": [
+		{"id": "encourage", "cost": 3, "use_limit": 2, "hero_classes": ["crusader"]},
+		{"id": "first_aid", "cost": 2, "use_limit": 1},
+		{"id": "pray", "cost": 3},
+		{"id": "sing", "cost": 1}
+	]
+}`)
+	if !jsonmerge.Equal(merged, expect) {
+		t.Error("merged json is wrong")
+	}
+	for _, collision := range collisions {
+		t.Log(collision.Path, jsonmerge.Text(collision.Base), collision.Sources, jsonmerge.Text(collision.Values))
+	}
+	if len(collisions) != 1 || collisions[0].Path != "$.skills[encourage].cost" {
+		t.Fatal("expect only cost of encourage collided")
+	}
+
+	// without base, values differ are collisions
+	_, collisions = jsonmerge.Merge(nil, []jsonmerge.Source{{Name: "a", Value: parse(t, `{"x": [1, 2], "y": 1}`)}, {Name: "b", Value: parse(t, `{"x": [1, 3], "z": 1}`)}})
+	if len(collisions) != 1 || collisions[0].Path != "$.x" {
+		t.Error("expect x collided", collisions)
+	}
+}
@@ -0,0 +1,222 @@
+package jsonmerge
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+type Source struct {
+	Name  string
+	Value any
+}
+
+// Collision
+// sources changed the same value of base differently, Values are by Sources.
+// Path is such as $.skills[encourage].cost, where encourage is the id of an element.
+type Collision struct {
+	Path    string
+	Base    any
+	Sources []int
+	Values  []any
+}
+
+// Merge merges values of sources changed from base, later sources are after earlier ones.
+// objects are merged by keys, arrays whose elements are objects with unique ids are merged by ids,
+// other values changed by more than one source differently are collisions, the last source wins.
+// an absent key or element is not a change, since mods may ship a part of file only.
+// base may be nil, then values which are not equal in sources are collisions.
+func Merge(base any, sources []Source) (merged any, collisions []Collision) {
+	values := make([]slot, len(sources))
+	for i, source := range sources {
+		values[i] = slot{value: source.Value, present: true}
+	}
+	m := merger{}
+	merged, _ = m.merge("$", slot{value: base, present: base != nil}, values)
+	collisions = m.collisions
+	return
+}
+
+// slot is a value which may be absent.
+type slot struct {
+	value   any
+	present bool
+}
+
+type merger struct {
+	collisions []Collision
+}
+
+func (m *merger) merge(path string, base slot, sources []slot) (merged any, present bool) {
+	changed := make([]int, 0, len(sources))
+	for i, source := range sources {
+		if source.present && (!base.present || !Equal(base.value, source.value)) {
+			changed = append(changed, i)
+		}
+	}
+	switch {
+	case len(changed) == 0:
+		return base.value, base.present
+	case len(changed) == 1 || sameValues(sources, changed):
+		return sources[changed[len(changed)-1]].value, true
+	case allObjects(base, sources, changed):
+		return m.mergeObjects(path, base, sources, changed), true
+	case allIdArrays(base, sources, changed):
+		return m.mergeIdArrays(path, base, sources, changed), true
+	}
+	collision := Collision{Path: path, Base: base.value}
+	for _, i := range changed {
+		collision.Sources = append(collision.Sources, i)
+		collision.Values = append(collision.Values, sources[i].value)
+	}
+	m.collisions = append(m.collisions, collision)
+	return sources[changed[len(changed)-1]].value, true
+}
+
+func (m *merger) mergeObjects(path string, base slot, sources []slot, changed []int) *Object {
+	objects := make([]*Object, 0, len(changed)+1)
+	var baseObject *Object
+	if base.present {
+		baseObject = base.value.(*Object)
+		objects = append(objects, baseObject)
+	}
+	for _, i := range changed {
+		objects = append(objects, sources[i].value.(*Object))
+	}
+	merged := NewObject()
+	for _, key := range orderedKeys(objects) {
+		keyBase := slot{}
+		if baseObject != nil {
+			keyBase.value, keyBase.present = baseObject.Get(key)
+		}
+		keySources := make([]slot, len(sources))
+		for _, i := range changed {
+			keySources[i].value, keySources[i].present = sources[i].value.(*Object).Get(key)
+		}
+		if value, present := m.merge(path+"."+key, keyBase, keySources); present {
+			merged.Set(key, value)
+		}
+	}
+	return merged
+}
+
+func (m *merger) mergeIdArrays(path string, base slot, sources []slot, changed []int) []any {
+	arrays := make([]*Object, 0, len(changed)+1)
+	var baseArray *Object
+	if base.present {
+		baseArray = idObject(base.value.([]any))
+		arrays = append(arrays, baseArray)
+	}
+	sourceArrays := make([]*Object, len(sources))
+	for _, i := range changed {
+		sourceArrays[i] = idObject(sources[i].value.([]any))
+		arrays = append(arrays, sourceArrays[i])
+	}
+	merged := make([]any, 0, len(arrays[0].Keys))
+	for _, id := range orderedKeys(arrays) {
+		idBase := slot{}
+		if baseArray != nil {
+			idBase.value, idBase.present = baseArray.Get(id)
+		}
+		idSources := make([]slot, len(sources))
+		for _, i := range changed {
+			idSources[i].value, idSources[i].present = sourceArrays[i].Get(id)
+		}
+		if value, present := m.merge(fmt.Sprintf("%s[%s]", path, id), idBase, idSources); present {
+			merged = append(merged, value)
+		}
+	}
+	return merged
+}
+
+// orderedKeys returns keys of objects in order of appearance.
+func orderedKeys(objects []*Object) (keys []string) {
+	seen := make(map[string]bool)
+	for _, object := range objects {
+		for _, key := range object.Keys {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	return
+}
+
+func sameValues(sources []slot, changed []int) bool {
+	for _, i := range changed[1:] {
+		if !Equal(sources[i].value, sources[changed[0]].value) {
+			return false
+		}
+	}
+	return true
+}
+
+func allObjects(base slot, sources []slot, changed []int) bool {
+	if _, ok := base.value.(*Object); base.present && !ok {
+		return false
+	}
+	for _, i := range changed {
+		if _, ok := sources[i].value.(*Object); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func allIdArrays(base slot, sources []slot, changed []int) bool {
+	if base.present && !isIdArray(base.value) {
+		return false
+	}
+	for _, i := range changed {
+		if !isIdArray(sources[i].value) {
+			return false
+		}
+	}
+	return true
+}
+
+// elementId returns id of element, which is a string or number field named id.
+func elementId(element any) (id string, ok bool) {
+	object, isObject := element.(*Object)
+	if !isObject {
+		return
+	}
+	value, has := object.Get("id")
+	if !has {
+		return
+	}
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	}
+	return
+}
+
+// isIdArray such as [{"id": "a", ...}, {"id": "b", ...}], ids are unique.
+func isIdArray(value any) bool {
+	array, ok := value.([]any)
+	if !ok || len(array) == 0 {
+		return false
+	}
+	seen := make(map[string]bool, len(array))
+	for _, element := range array {
+		id, hasId := elementId(element)
+		if !hasId || seen[id] {
+			return false
+		}
+		seen[id] = true
+	}
+	return true
+}
+
+// idObject indexes elements of an id array by ids in order.
+func idObject(array []any) *Object {
+	object := NewObject()
+	for _, element := range array {
+		id, _ := elementId(element)
+		object.Set(id, element)
+	}
+	return object
+}
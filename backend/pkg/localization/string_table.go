@@ -0,0 +1,214 @@
+package localization
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+// StringTable
+/* xml
+<?xml version="1.0" encoding="UTF-8"?>
+<root>
+	<language id="english">
+		<entry id="str_bar_name"><![CDATA[The Hamlet]]></entry>
+	</language>
+</root>
+*/
+type StringTable struct {
+	Languages []*Language
+}
+
+type Language struct {
+	Id      string
+	Entries []*Entry
+	index   map[string]int // entry index by id
+}
+
+type Entry struct {
+	Id   string
+	Text string
+}
+
+var ErrInvalidStringTable = errors.New("invalid string table")
+
+// Language returns the language of id, nil if absent.
+func (table *StringTable) Language(id string) *Language {
+	for _, language := range table.Languages {
+		if language.Id == id {
+			return language
+		}
+	}
+	return nil
+}
+
+// Set sets text of entry, the language and the entry are appended if absent.
+func (table *StringTable) Set(language string, id string, text string) {
+	target := table.Language(language)
+	if target == nil {
+		target = &Language{Id: language}
+		table.Languages = append(table.Languages, target)
+	}
+	if entry := target.Entry(id); entry != nil {
+		entry.Text = text
+		return
+	}
+	target.index[id] = len(target.Entries)
+	target.Entries = append(target.Entries, &Entry{Id: id, Text: text})
+}
+
+// Entry returns the entry of id, nil if absent.
+func (language *Language) Entry(id string) *Entry {
+	// entries may be appended directly
+	if language.index == nil || len(language.index) != len(language.Entries) {
+		language.reindex()
+	}
+	if i, has := language.index[id]; has {
+		return language.Entries[i]
+	}
+	return nil
+}
+
+// reindex indexes entries by id, the last one wins when id is repeated.
+func (language *Language) reindex() {
+	language.index = make(map[string]int, len(language.Entries))
+	for i, entry := range language.Entries {
+		language.index[entry.Id] = i
+	}
+}
+
+type xmlStringTable struct {
+	XMLName   xml.Name      `xml:"root"`
+	Languages []xmlLanguage `xml:"language"`
+}
+
+type xmlLanguage struct {
+	Id      string     `xml:"id,attr"`
+	Entries []xmlEntry `xml:"entry"`
+}
+
+type xmlEntry struct {
+	Id   string `xml:"id,attr"`
+	Text string `xml:",chardata"`
+}
+
+// Parse reads a string table, entries keep the order of file.
+func Parse(reader io.Reader) (table *StringTable, err error) {
+	p, readErr := io.ReadAll(reader)
+	if readErr != nil {
+		err = readErr
+		return
+	}
+	p = bytes.TrimPrefix(p, []byte("\uFEFF"))
+	decoder := xml.NewDecoder(bytes.NewReader(p))
+	// tables are always utf-8 even declared otherwise
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	decoder.Strict = false
+	root := xmlStringTable{}
+	if decodeErr := decoder.Decode(&root); decodeErr != nil {
+		err = errors.Join(ErrInvalidStringTable, decodeErr)
+		return
+	}
+	table = &StringTable{Languages: make([]*Language, 0, len(root.Languages))}
+	for _, language := range root.Languages {
+		for _, entry := range language.Entries {
+			table.Set(language.Id, entry.Id, entry.Text)
+		}
+		if table.Language(language.Id) == nil {
+			table.Languages = append(table.Languages, &Language{Id: language.Id})
+		}
+	}
+	return
+}
+
+// WriteTo writes table as xml, texts are written in CDATA.
+func (table *StringTable) WriteTo(writer io.Writer) (n int64, err error) {
+	b := strings.Builder{}
+	b.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<root>\n")
+	for _, language := range table.Languages {
+		b.WriteString("\t<language id=\"")
+		_ = xml.EscapeText(&b, []byte(language.Id))
+		b.WriteString("\">\n")
+		for _, entry := range language.Entries {
+			b.WriteString("\t\t<entry id=\"")
+			_ = xml.EscapeText(&b, []byte(entry.Id))
+			b.WriteString("\"><![CDATA[")
+			// ]]> can not be in CDATA, split it into two sections
+			b.WriteString(strings.ReplaceAll(entry.Text, "]]>", "]]]]><![CDATA[>"))
+			b.WriteString("]]></entry>\n")
+		}
+		b.WriteString("\t</language>\n")
+	}
+	b.WriteString("</root>\n")
+	written, writeErr := io.WriteString(writer, b.String())
+	n = int64(written)
+	err = writeErr
+	return
+}
+
+func (table *StringTable) Bytes() []byte {
+	buf := bytes.Buffer{}
+	_, _ = table.WriteTo(&buf)
+	return buf.Bytes()
+}
+
+type Source struct {
+	Name  string
+	Table *StringTable
+}
+
+// Duplicate
+// sources have the same entry with different texts, Texts are by Sources.
+type Duplicate struct {
+	Language string
+	Id       string
+	Sources  []int
+	Texts    []string
+}
+
+// Merge merges entries of sources by language and id, later sources win.
+// entries of the same language and id with different texts are duplicates.
+func Merge(sources []Source) (merged *StringTable, duplicates []Duplicate) {
+	merged = &StringTable{}
+	type key struct {
+		language string
+		id       string
+	}
+	found := make(map[key]*Duplicate)
+	order := make([]key, 0, 1)
+	for i, source := range sources {
+		for _, language := range source.Table.Languages {
+			for _, entry := range language.Entries {
+				k := key{language: language.Id, id: entry.Id}
+				duplicate, has := found[k]
+				if !has {
+					duplicate = &Duplicate{Language: language.Id, Id: entry.Id}
+					found[k] = duplicate
+					order = append(order, k)
+				}
+				// repeated in one source, the last one wins
+				if n := len(duplicate.Sources); n > 0 && duplicate.Sources[n-1] == i {
+					duplicate.Texts[n-1] = entry.Text
+				} else {
+					duplicate.Sources = append(duplicate.Sources, i)
+					duplicate.Texts = append(duplicate.Texts, entry.Text)
+				}
+				merged.Set(language.Id, entry.Id, entry.Text)
+			}
+		}
+	}
+	for _, k := range order {
+		duplicate := found[k]
+		for _, text := range duplicate.Texts[1:] {
+			if text != duplicate.Texts[0] {
+				duplicates = append(duplicates, *duplicate)
+				break
+			}
+		}
+	}
+	return
+}
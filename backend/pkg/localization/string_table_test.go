@@ -0,0 +1,70 @@
+package localization_test
+
+import (
+	"strings"
+	"testing"
+
+	"DarkestDungeonModBoxLite/backend/pkg/localization"
+)
+
+const dialogue = "\uFEFF<?xml version=\"1.0\" encoding=\"utf-8\"?>\r\n" +
+	"<root>\r\n" +
+	"<language id=\"english\">\r\n" +
+	"<entry id=\"str_bar_name\"><![CDATA[The Tavern]]></entry>\r\n" +
+	"<entry id=\"str_abbey_name\"><![CDATA[The <b>Abbey</b>]]></entry>\r\n" +
+	"</language>\r\n" +
+	"<language id=\"schinese\">\r\n" +
+	"<entry id=\"str_bar_name\"><![CDATA[酒馆]]></entry>\r\n" +
+	"</language>\r\n" +
+	"</root>\r\n"
+
+func TestParse(t *testing.T) {
+	table, err := localization.Parse(strings.NewReader(dialogue))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(table.Languages) != 2 {
+		t.Fatal("expect 2 languages, got", len(table.Languages))
+	}
+	if entry := table.Language("english").Entry("str_abbey_name"); entry == nil || entry.Text != "The <b>Abbey</b>" {
+		t.Error("CDATA is not parsed", entry)
+	}
+	text := string(table.Bytes())
+	t.Log("\n" + text)
+	reparsed, reparseErr := localization.Parse(strings.NewReader(text))
+	if reparseErr != nil {
+		t.Fatal(reparseErr)
+	}
+	if entry := reparsed.Language("schinese").Entry("str_bar_name"); entry == nil || entry.Text != "酒馆" {
+		t.Error("written table is not parsed as same", entry)
+	}
+	if _, err = localization.Parse(strings.NewReader("not xml")); err == nil {
+		t.Error("invalid table is parsed")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	translation, _ := localization.Parse(strings.NewReader(dialogue))
+	hero := &localization.StringTable{}
+	hero.Set("english", "hero_class_name_paladin", "Paladin")
+	hero.Set("english", "str_bar_name", "The Bar")
+	hero.Set("schinese", "str_bar_name", "酒馆")
+	hero.Set("english", "str_odd", "a ]]> b")
+	merged, duplicates := localization.Merge([]localization.Source{{Name: "translation", Table: translation}, {Name: "hero", Table: hero}})
+	t.Log("\n" + string(merged.Bytes()))
+	english := merged.Language("english")
+	if len(english.Entries) != 4 || english.Entry("hero_class_name_paladin") == nil || english.Entry("str_abbey_name") == nil {
+		t.Error("entries are not merged")
+	}
+	if english.Entry("str_bar_name").Text != "The Bar" {
+		t.Error("later source does not win")
+	}
+	if len(duplicates) != 1 || duplicates[0].Id != "str_bar_name" || duplicates[0].Language != "english" {
+		t.Fatal("expect str_bar_name of english duplicated, got", duplicates)
+	}
+	t.Log(duplicates[0].Sources, duplicates[0].Texts)
+	reparsed, err := localization.Parse(strings.NewReader(string(merged.Bytes())))
+	if err != nil || reparsed.Language("english").Entry("str_odd").Text != "a ]]> b" {
+		t.Error("CDATA end is not escaped", err)
+	}
+}
@@ -0,0 +1,136 @@
+package programs
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"DarkestDungeonModBoxLite/backend/pkg/programs/vdf"
+)
+
+type AppManifest struct {
+	AppId       string    `json:"appId"`
+	Name        string    `json:"name"`
+	InstallDir  string    `json:"installDir"`
+	BuildId     string    `json:"buildId"`
+	SizeOnDisk  int64     `json:"sizeOnDisk"`
+	LastUpdated time.Time `json:"lastUpdated"`
+}
+
+// ReadAppManifest reads {library}/steamapps/appmanifest_{appId}.acf
+func ReadAppManifest(library string, appId string) (manifest AppManifest, err error) {
+	root, parseErr := vdf.ParseFile(filepath.Join(library, "steamapps", fmt.Sprintf("appmanifest_%s.acf", appId)))
+	if parseErr != nil {
+		err = parseErr
+		return
+	}
+	state := root.Child("AppState")
+	if state == nil {
+		err = fmt.Errorf("appmanifest_%s.acf has no AppState", appId)
+		return
+	}
+	manifest = AppManifest{
+		AppId:       state.Get("appid"),
+		Name:        state.Get("name"),
+		InstallDir:  state.Get("installdir"),
+		BuildId:     state.Get("buildid"),
+		SizeOnDisk:  state.Int("SizeOnDisk"),
+		LastUpdated: unix(state.Int("LastUpdated")),
+	}
+	return
+}
+
+type WorkshopItem struct {
+	Id          string    `json:"id"`
+	Size        int64     `json:"size"`
+	TimeUpdated time.Time `json:"timeUpdated"`
+	Manifest    string    `json:"manifest"`
+}
+
+type WorkshopManifest struct {
+	AppId           string                  `json:"appId"`
+	SizeOnDisk      int64                   `json:"sizeOnDisk"`
+	TimeLastUpdated time.Time               `json:"timeLastUpdated"`
+	Items           map[string]WorkshopItem `json:"items"`
+}
+
+// WorkshopManifestFilename returns the manifest of workshop content dir,
+// such as {library}/steamapps/workshop/appworkshop_262060.acf for {library}/steamapps/workshop/content/262060.
+func WorkshopManifestFilename(content string) string {
+	appId := filepath.Base(content)
+	return filepath.Join(filepath.Dir(filepath.Dir(content)), fmt.Sprintf("appworkshop_%s.acf", appId))
+}
+
+// ReadWorkshopManifest reads appworkshop_{appId}.acf
+// items are read from WorkshopItemsInstalled, and WorkshopItemDetails fills the missing.
+/* vdf
+"AppWorkshop"
+{
+	"appid"		"262060"
+	"WorkshopItemsInstalled"
+	{
+		"2936390129"
+		{
+			"size"		"1627385"
+			"timeupdated"		"1690000000"
+			"manifest"		"5310428385417040573"
+		}
+	}
+	"WorkshopItemDetails"
+	{
+		"2936390129"
+		{
+			"manifest"		"5310428385417040573"
+			"timeupdated"		"1690000000"
+		}
+	}
+}
+*/
+func ReadWorkshopManifest(filename string) (manifest WorkshopManifest, err error) {
+	root, parseErr := vdf.ParseFile(filename)
+	if parseErr != nil {
+		err = parseErr
+		return
+	}
+	workshop := root.Child("AppWorkshop")
+	if workshop == nil {
+		err = fmt.Errorf("%s has no AppWorkshop", filepath.Base(filename))
+		return
+	}
+	manifest = WorkshopManifest{
+		AppId:           workshop.Get("appid"),
+		SizeOnDisk:      workshop.Int("SizeOnDisk"),
+		TimeLastUpdated: unix(workshop.Int("TimeLastUpdated")),
+		Items:           make(map[string]WorkshopItem),
+	}
+	for _, key := range []string{"WorkshopItemsInstalled", "WorkshopItemDetails"} {
+		items := workshop.Child(key)
+		if items == nil {
+			continue
+		}
+		for _, node := range items.Children {
+			item, has := manifest.Items[node.Key]
+			if !has {
+				item.Id = node.Key
+			}
+			if item.Size == 0 {
+				item.Size = node.Int("size")
+			}
+			if item.TimeUpdated.IsZero() {
+				item.TimeUpdated = unix(node.Int("timeupdated"))
+			}
+			if item.Manifest == "" {
+				item.Manifest = node.Get("manifest")
+			}
+			manifest.Items[node.Key] = item
+		}
+	}
+	return
+}
+
+func unix(sec int64) time.Time {
+	if sec <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
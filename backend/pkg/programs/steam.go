@@ -1,32 +1,192 @@
 package programs
 
 import (
-	"golang.org/x/sys/windows/registry"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"DarkestDungeonModBoxLite/backend/pkg/programs/vdf"
+)
+
+const (
+	DarkestDungeonAppId      = "262060"
+	DarkestDungeonInstallDir = "DarkestDungeon"
+)
+
+var (
+	ErrSteamNotFound = errors.New("steam not found")
+	ErrAppNotFound   = errors.New("app not found in steam libraries")
 )
 
 func FindSteam() (string, error) {
-	// 尝试从 64 位注册表读取
-	key, err := registry.OpenKey(
-		registry.LOCAL_MACHINE,
-		`SOFTWARE\WOW6432Node\Valve\Steam`,
-		registry.QUERY_VALUE,
-	)
+	dirs, err := findSteamDirs()
 	if err != nil {
-		// 尝试从 32 位注册表读取
-		key, err = registry.OpenKey(
-			registry.LOCAL_MACHINE,
-			`SOFTWARE\Valve\Steam`,
-			registry.QUERY_VALUE,
-		)
-		if err != nil {
-			return "", err
+		return "", err
+	}
+	if len(dirs) == 0 {
+		return "", ErrSteamNotFound
+	}
+	return dirs[0], nil
+}
+
+// FindLibrary returns the steam library folder which contains app.
+// libraries listed in steamapps/libraryfolders.vdf of every found steam are searched.
+func FindLibrary(appId string) (steam string, library string, err error) {
+	dirs, dirsErr := findSteamDirs()
+	if dirsErr != nil {
+		err = dirsErr
+		return
+	}
+	if len(dirs) == 0 {
+		err = ErrSteamNotFound
+		return
+	}
+	for _, dir := range dirs {
+		libraries, _ := readLibraryFolders(dir)
+		for _, lib := range libraries {
+			if slices.Contains(lib.apps, appId) || existAppManifest(lib.path, appId) {
+				steam = dir
+				library = lib.path
+				return
+			}
 		}
 	}
-	defer key.Close()
+	err = ErrAppNotFound
+	return
+}
 
-	path, _, err := key.GetStringValue("InstallPath")
-	if err != nil {
-		return "", err
+type Game struct {
+	Steam    string `json:"steam"`
+	Library  string `json:"library"`
+	Dir      string `json:"dir"`
+	Workshop string `json:"workshop"`
+	BuildId  string `json:"buildId"`
+}
+
+func FindDarkestDungeon() (game Game, err error) {
+	steam, library, findErr := FindLibrary(DarkestDungeonAppId)
+	if findErr != nil {
+		err = findErr
+		return
+	}
+	installDir := DarkestDungeonInstallDir
+	manifest, manifestErr := ReadAppManifest(library, DarkestDungeonAppId)
+	if manifestErr == nil && manifest.InstallDir != "" {
+		installDir = manifest.InstallDir
+	}
+	game = Game{
+		Steam:    steam,
+		Library:  library,
+		Dir:      filepath.Join(library, "steamapps", "common", installDir),
+		Workshop: filepath.Join(library, "steamapps", "workshop", "content", DarkestDungeonAppId),
+		BuildId:  manifest.BuildId,
+	}
+	return
+}
+
+type libraryFolder struct {
+	path string
+	apps []string
+}
+
+// readLibraryFolders
+// steam itself is always a library, when libraryfolders.vdf is missing, only steam is returned.
+/* vdf
+"libraryfolders"
+{
+	"0"
+	{
+		"path"		"C:\\Program Files (x86)\\Steam"
+		"apps"
+		{
+			"262060"		"2936390129"
+		}
+	}
+}
+*/
+func readLibraryFolders(steam string) (libraries []libraryFolder, err error) {
+	libraries = append(libraries, libraryFolder{path: steam})
+	root, parseErr := vdf.ParseFile(filepath.Join(steam, "steamapps", "libraryfolders.vdf"))
+	if parseErr != nil {
+		if !os.IsNotExist(parseErr) {
+			err = parseErr
+		}
+		return
+	}
+	folders := root.Child("libraryfolders")
+	if folders == nil {
+		return
+	}
+	for _, folder := range folders.Children {
+		lib := libraryFolder{}
+		if !folder.IsObject() {
+			// old format: "1" "D:\\SteamLibrary"
+			if !isNumeric(folder.Key) {
+				continue
+			}
+			lib.path = folder.Value
+		} else {
+			lib.path = folder.Get("path")
+			if apps := folder.Child("apps"); apps != nil {
+				for _, app := range apps.Children {
+					lib.apps = append(lib.apps, app.Key)
+				}
+			}
+		}
+		if lib.path == "" {
+			continue
+		}
+		if idx := slices.IndexFunc(libraries, func(l libraryFolder) bool {
+			return sameDir(l.path, lib.path)
+		}); idx > -1 {
+			libraries[idx].apps = append(libraries[idx].apps, lib.apps...)
+			continue
+		}
+		libraries = append(libraries, lib)
+	}
+	return
+}
+
+func existAppManifest(library string, appId string) bool {
+	_, err := os.Stat(filepath.Join(library, "steamapps", fmt.Sprintf("appmanifest_%s.acf", appId)))
+	return err == nil
+}
+
+func existedSteamDirs(candidates []string) (dirs []string) {
+	for _, candidate := range candidates {
+		info, err := os.Stat(filepath.Join(candidate, "steamapps"))
+		if err != nil || !info.IsDir() {
+			continue
+		}
+		if slices.ContainsFunc(dirs, func(dir string) bool {
+			return sameDir(dir, candidate)
+		}) {
+			continue
+		}
+		dirs = append(dirs, candidate)
+	}
+	return
+}
+
+func sameDir(a string, b string) bool {
+	if filepath.Clean(a) == filepath.Clean(b) {
+		return true
+	}
+	ra, aErr := filepath.EvalSymlinks(a)
+	rb, bErr := filepath.EvalSymlinks(b)
+	return aErr == nil && bErr == nil && ra == rb
+}
+
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
 	}
-	return path, nil
+	return true
 }
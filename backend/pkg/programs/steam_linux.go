@@ -0,0 +1,29 @@
+//go:build linux
+
+package programs
+
+import (
+	"os"
+	"path/filepath"
+)
+
+func findSteamDirs() (dirs []string, err error) {
+	home, homeErr := os.UserHomeDir()
+	if homeErr != nil {
+		err = homeErr
+		return
+	}
+	candidates := []string{
+		filepath.Join(home, ".steam", "steam"),
+		filepath.Join(home, ".steam", "root"),
+		filepath.Join(home, ".local", "share", "Steam"),
+		// flatpak
+		filepath.Join(home, ".var", "app", "com.valvesoftware.Steam", ".steam", "steam"),
+		filepath.Join(home, ".var", "app", "com.valvesoftware.Steam", ".local", "share", "Steam"),
+	}
+	if dataHome := os.Getenv("XDG_DATA_HOME"); dataHome != "" {
+		candidates = append(candidates, filepath.Join(dataHome, "Steam"))
+	}
+	dirs = existedSteamDirs(candidates)
+	return
+}
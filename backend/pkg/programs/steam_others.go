@@ -0,0 +1,21 @@
+//go:build !windows && !linux
+
+package programs
+
+import (
+	"os"
+	"path/filepath"
+)
+
+func findSteamDirs() (dirs []string, err error) {
+	home, homeErr := os.UserHomeDir()
+	if homeErr != nil {
+		err = homeErr
+		return
+	}
+	candidates := []string{
+		filepath.Join(home, "Library", "Application Support", "Steam"),
+	}
+	dirs = existedSteamDirs(candidates)
+	return
+}
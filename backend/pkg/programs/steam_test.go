@@ -0,0 +1,103 @@
+package programs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadLibraryFolders(t *testing.T) {
+	steam := t.TempDir()
+	second := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(steam, "steamapps"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	vdf := `"libraryfolders"
+{
+	"0"
+	{
+		"path"		"` + steam + `"
+		"apps"
+		{
+			"228980"		"424242"
+		}
+	}
+	"1"
+	{
+		"path"		"` + second + `"
+		"apps"
+		{
+			"262060"		"2936390129"
+		}
+	}
+}
+`
+	if err := os.WriteFile(filepath.Join(steam, "steamapps", "libraryfolders.vdf"), []byte(vdf), 0644); err != nil {
+		t.Fatal(err)
+	}
+	libraries, err := readLibraryFolders(steam)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(libraries) != 2 {
+		t.Fatalf("expect 2 libraries, got %d", len(libraries))
+	}
+	for _, library := range libraries {
+		t.Log(library.path, library.apps)
+	}
+	if libraries[1].path != second || libraries[1].apps[0] != DarkestDungeonAppId {
+		t.Error("second library is not found")
+	}
+}
+
+func TestReadWorkshopManifest(t *testing.T) {
+	library := t.TempDir()
+	content := filepath.Join(library, "steamapps", "workshop", "content", DarkestDungeonAppId)
+	if err := os.MkdirAll(content, 0755); err != nil {
+		t.Fatal(err)
+	}
+	acf := `"AppWorkshop"
+{
+	"appid"		"262060"
+	"SizeOnDisk"		"1627385"
+	"WorkshopItemsInstalled"
+	{
+		"2936390129"
+		{
+			"size"		"1627385"
+			"timeupdated"		"1690000000"
+			"manifest"		"5310428385417040573"
+		}
+	}
+	"WorkshopItemDetails"
+	{
+		"2936390129"
+		{
+			"manifest"		"5310428385417040573"
+			"timeupdated"		"1690000000"
+		}
+		"885957080"
+		{
+			"manifest"		"2276153826380287312"
+			"timeupdated"		"1500000000"
+		}
+	}
+}
+`
+	if err := os.WriteFile(WorkshopManifestFilename(content), []byte(acf), 0644); err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := ReadWorkshopManifest(WorkshopManifestFilename(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, item := range manifest.Items {
+		t.Log(id, item.Size, item.TimeUpdated)
+	}
+	if item := manifest.Items["2936390129"]; item.Size != 1627385 || item.TimeUpdated.Unix() != 1690000000 {
+		t.Error("installed item is not parsed")
+	}
+	if item := manifest.Items["885957080"]; item.TimeUpdated.Unix() != 1500000000 {
+		t.Error("item details are not parsed")
+	}
+}
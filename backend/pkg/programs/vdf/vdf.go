@@ -0,0 +1,215 @@
+// Package vdf reads valve key values text, such as libraryfolders.vdf and appmanifest_*.acf.
+package vdf
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Node
+// children is nil when it is a value, keys are case-insensitive.
+/* vdf
+"AppState"
+{
+	"appid"		"262060"
+	"buildid"		"6452436"
+}
+*/
+type Node struct {
+	Key      string
+	Value    string
+	Children []*Node
+}
+
+func (node *Node) IsObject() bool {
+	return node.Children != nil
+}
+
+func (node *Node) Child(key string) *Node {
+	if node == nil {
+		return nil
+	}
+	for _, child := range node.Children {
+		if strings.EqualFold(child.Key, key) {
+			return child
+		}
+	}
+	return nil
+}
+
+// Lookup finds node by path of keys.
+func (node *Node) Lookup(keys ...string) *Node {
+	for _, key := range keys {
+		if node = node.Child(key); node == nil {
+			return nil
+		}
+	}
+	return node
+}
+
+func (node *Node) Get(key string) string {
+	if child := node.Child(key); child != nil {
+		return child.Value
+	}
+	return ""
+}
+
+func (node *Node) Int(key string) int64 {
+	n, _ := strconv.ParseInt(strings.TrimSpace(node.Get(key)), 10, 64)
+	return n
+}
+
+func ParseFile(filename string) (root *Node, err error) {
+	file, openErr := os.Open(filename)
+	if openErr != nil {
+		err = openErr
+		return
+	}
+	defer file.Close()
+	if root, err = Parse(file); err != nil {
+		err = fmt.Errorf("vdf: parse %s failed: %w", filename, err)
+		return
+	}
+	return
+}
+
+// Parse returns a root node whose children are top level nodes.
+func Parse(reader io.Reader) (root *Node, err error) {
+	s := &scanner{reader: bufio.NewReader(reader), line: 1}
+	root = &Node{Children: make([]*Node, 0, 1)}
+	stack := []*Node{root}
+	var key *string
+	for {
+		token, quoted, scanErr := s.next()
+		if scanErr != nil {
+			if errors.Is(scanErr, io.EOF) {
+				break
+			}
+			err = scanErr
+			return
+		}
+		current := stack[len(stack)-1]
+		switch {
+		case !quoted && token == "{":
+			if key == nil {
+				err = fmt.Errorf("line %d: unexpected {", s.line)
+				return
+			}
+			node := &Node{Key: *key, Children: make([]*Node, 0, 1)}
+			current.Children = append(current.Children, node)
+			stack = append(stack, node)
+			key = nil
+		case !quoted && token == "}":
+			if key != nil || len(stack) == 1 {
+				err = fmt.Errorf("line %d: unexpected }", s.line)
+				return
+			}
+			stack = stack[:len(stack)-1]
+		case !quoted && strings.HasPrefix(token, "[") && strings.HasSuffix(token, "]"):
+			// conditional, such as [$WIN32]
+		case key == nil:
+			k := token
+			key = &k
+		default:
+			current.Children = append(current.Children, &Node{Key: *key, Value: token})
+			key = nil
+		}
+	}
+	if key != nil || len(stack) != 1 {
+		err = fmt.Errorf("line %d: unexpected end", s.line)
+		return
+	}
+	return
+}
+
+type scanner struct {
+	reader *bufio.Reader
+	line   int
+}
+
+func (s *scanner) next() (token string, quoted bool, err error) {
+	var c rune
+	// skip spaces and comments
+	for {
+		if c, _, err = s.reader.ReadRune(); err != nil {
+			return
+		}
+		if c == '\n' {
+			s.line++
+			continue
+		}
+		if c == ' ' || c == '\t' || c == '\r' || c == '\uFEFF' {
+			continue
+		}
+		if c == '/' {
+			if n, _, _ := s.reader.ReadRune(); n == '/' {
+				if _, err = s.reader.ReadString('\n'); err != nil {
+					return
+				}
+				s.line++
+				continue
+			}
+			_ = s.reader.UnreadRune()
+		}
+		break
+	}
+	switch c {
+	case '{', '}':
+		token = string(c)
+		return
+	case '"':
+		quoted = true
+		b := strings.Builder{}
+		for {
+			if c, _, err = s.reader.ReadRune(); err != nil {
+				err = fmt.Errorf("line %d: unterminated string", s.line)
+				return
+			}
+			if c == '"' {
+				break
+			}
+			if c == '\n' {
+				s.line++
+			}
+			if c == '\\' {
+				n, _, nErr := s.reader.ReadRune()
+				if nErr != nil {
+					err = fmt.Errorf("line %d: unterminated string", s.line)
+					return
+				}
+				switch n {
+				case 'n':
+					c = '\n'
+				case 't':
+					c = '\t'
+				default:
+					c = n
+				}
+			}
+			b.WriteRune(c)
+		}
+		token = b.String()
+		return
+	default:
+		b := strings.Builder{}
+		b.WriteRune(c)
+		for {
+			n, _, nErr := s.reader.ReadRune()
+			if nErr != nil {
+				break
+			}
+			if n == ' ' || n == '\t' || n == '\r' || n == '\n' || n == '{' || n == '}' || n == '"' {
+				_ = s.reader.UnreadRune()
+				break
+			}
+			b.WriteRune(n)
+		}
+		token = b.String()
+		return
+	}
+}
@@ -0,0 +1,44 @@
+package vdf_test
+
+import (
+	"strings"
+	"testing"
+
+	"DarkestDungeonModBoxLite/backend/pkg/programs/vdf"
+)
+
+func TestParse(t *testing.T) {
+	text := `// comment
+"AppState"
+{
+	"appid"		"262060"
+	"name"		"Darkest Dungeon"
+	"installdir"		"DarkestDungeon"
+	"buildid"		"6452436"
+	"UserConfig"
+	{
+		"language"		"schinese"
+		"path"		"C:\\Program Files (x86)\\Steam"
+	}
+	"platform"		"win"	[$WIN32]
+}
+`
+	root, err := vdf.Parse(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := root.Child("appstate")
+	if state == nil {
+		t.Fatal("AppState not found")
+	}
+	t.Log(state.Get("name"), state.Int("buildid"))
+	if state.Int("buildid") != 6452436 {
+		t.Error("buildid is not parsed")
+	}
+	if path := root.Lookup("AppState", "UserConfig").Get("path"); path != `C:\Program Files (x86)\Steam` {
+		t.Error("escaped path is not parsed", path)
+	}
+	if _, err = vdf.Parse(strings.NewReader(`"AppState" { "appid" "1"`)); err == nil {
+		t.Error("unexpected end is not reported")
+	}
+}
@@ -0,0 +1,122 @@
+package tasks
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	progressInterval = 100 * time.Millisecond
+)
+
+type State struct {
+	Step       string `json:"step"`
+	FilesDone  int    `json:"filesDone"`
+	FilesTotal int    `json:"filesTotal"`
+	BytesDone  int64  `json:"bytesDone"`
+	BytesTotal int64  `json:"bytesTotal"`
+	Filename   string `json:"filename"`
+}
+
+// Progress
+// reports state of a task, a nil progress discards everything.
+// state is notified at most once per progressInterval, except when step changes.
+type Progress struct {
+	mutex    sync.Mutex
+	state    State
+	notified time.Time
+	notify   func(state State)
+}
+
+func newProgress(notify func(state State)) *Progress {
+	return &Progress{
+		notify: notify,
+	}
+}
+
+func (p *Progress) update(force bool, fn func(state *State)) {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	fn(&p.state)
+	now := time.Now()
+	if !force && now.Sub(p.notified) < progressInterval {
+		p.mutex.Unlock()
+		return
+	}
+	p.notified = now
+	state := p.state
+	p.mutex.Unlock()
+	if p.notify != nil {
+		p.notify(state)
+	}
+}
+
+func (p *Progress) State() (state State) {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	state = p.state
+	p.mutex.Unlock()
+	return
+}
+
+func (p *Progress) Step(step string) {
+	p.update(true, func(state *State) {
+		state.Step = step
+		state.Filename = ""
+	})
+}
+
+// Total adds files and bytes to the totals.
+func (p *Progress) Total(files int, bytes int64) {
+	p.update(false, func(state *State) {
+		state.FilesTotal += files
+		state.BytesTotal += bytes
+	})
+}
+
+func (p *Progress) File(filename string) {
+	p.update(false, func(state *State) {
+		state.Filename = filename
+	})
+}
+
+func (p *Progress) FileDone() {
+	p.update(false, func(state *State) {
+		state.FilesDone++
+	})
+}
+
+func (p *Progress) Bytes(n int64) {
+	p.update(false, func(state *State) {
+		state.BytesDone += n
+	})
+}
+
+// Reader counts bytes read from reader into progress.
+func (p *Progress) Reader(reader io.Reader) io.Reader {
+	if p == nil {
+		return reader
+	}
+	return &progressReader{
+		progress: p,
+		reader:   reader,
+	}
+}
+
+type progressReader struct {
+	progress *Progress
+	reader   io.Reader
+}
+
+func (r *progressReader) Read(b []byte) (n int, err error) {
+	n, err = r.reader.Read(b)
+	if n > 0 {
+		r.progress.Bytes(int64(n))
+	}
+	return
+}
@@ -2,54 +2,162 @@ package tasks
 
 import (
 	"context"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/rs/xid"
 )
 
 type Task interface {
-	Handle(ctx context.Context)
+	Handle(ctx context.Context, progress *Progress) (err error)
 }
 
+type TaskFunc func(ctx context.Context, progress *Progress) (err error)
+
+func (fn TaskFunc) Handle(ctx context.Context, progress *Progress) (err error) {
+	return fn(ctx, progress)
+}
+
+type Info struct {
+	Id       string    `json:"id"`
+	Name     string    `json:"name"`
+	State    State     `json:"state"`
+	StartAT  time.Time `json:"startAT"`
+	Done     bool      `json:"done"`
+	Canceled bool      `json:"canceled"`
+	Error    string    `json:"error"`
+}
+
+// Listener receives info of a task when it starts, makes progress and is done.
+type Listener func(info Info)
+
 type Handler struct {
-	id     string
-	cancel context.CancelFunc
-	done   func()
+	id       string
+	name     string
+	startAT  time.Time
+	progress *Progress
+	cancel   context.CancelFunc
+	done     func(err error)
 }
 
+// Execute runs task in background with ctx, which is derived by the manager and canceled by Cancel.
 func (h *Handler) Execute(ctx context.Context, task Task) {
-	ctx, h.cancel = context.WithCancel(ctx)
-	go func(ctx context.Context, task Task, done func()) {
-		task.Handle(ctx)
-		done()
-	}(ctx, task, h.done)
+	go func(ctx context.Context, task Task) {
+		h.done(h.run(ctx, task))
+	}(ctx, task)
+}
+
+func (h *Handler) run(ctx context.Context, task Task) (err error) {
+	err = task.Handle(ctx, h.progress)
+	if err == nil {
+		err = ctx.Err()
+	}
+	return
 }
 
 func (h *Handler) Cancel() {
 	h.cancel()
-	h.done()
 }
 
-func New() *Manager {
+func (h *Handler) Info() Info {
+	return Info{
+		Id:      h.id,
+		Name:    h.name,
+		State:   h.progress.State(),
+		StartAT: h.startAT,
+	}
+}
+
+func New(listener Listener) *Manager {
 	return &Manager{
 		handlers: sync.Map{},
+		listener: listener,
 	}
 }
 
 type Manager struct {
 	handlers sync.Map
+	listener Listener
 }
 
-func (manager *Manager) Execute(ctx context.Context, task Task) (pid string) {
-	pid = xid.New().String()
-	handler := &Handler{
-		id: pid,
-		done: func() {
-			manager.handlers.Delete(pid)
-		},
+// handler creates the handler of task, the cancelable ctx of it is created before it is listed,
+// so it can be canceled as soon as it is notified.
+func (manager *Manager) handler(ctx context.Context, name string) (handler *Handler, handlerCtx context.Context) {
+	pid := xid.New().String()
+	handler = &Handler{
+		id:      pid,
+		name:    name,
+		startAT: time.Now(),
+	}
+	handlerCtx, handler.cancel = context.WithCancel(ctx)
+	handler.progress = newProgress(func(state State) {
+		info := handler.Info()
+		info.State = state
+		manager.notify(info)
+	})
+	handler.done = func(err error) {
+		manager.handlers.Delete(pid)
+		info := handler.Info()
+		info.Done = true
+		if err != nil {
+			info.Canceled = err == context.Canceled || err == context.DeadlineExceeded
+			info.Error = err.Error()
+		}
+		manager.notify(info)
 	}
-	handler.Execute(ctx, task)
 	manager.handlers.Store(pid, handler)
+	manager.notify(handler.Info())
+	return
+}
+
+func (manager *Manager) notify(info Info) {
+	if manager.listener != nil {
+		manager.listener(info)
+	}
+}
+
+// Execute runs task in background.
+func (manager *Manager) Execute(ctx context.Context, name string, task Task) (pid string) {
+	handler, handlerCtx := manager.handler(ctx, name)
+	handler.Execute(handlerCtx, task)
+	pid = handler.id
+	return
+}
+
+// Run runs task and waits for it, it is listed while running.
+func (manager *Manager) Run(ctx context.Context, name string, task Task) (pid string, err error) {
+	handler, handlerCtx := manager.handler(ctx, name)
+	pid = handler.id
+	defer handler.cancel()
+	err = task.Handle(handlerCtx, handler.progress)
+	handler.done(err)
+	return
+}
+
+func (manager *Manager) Running(pid string) (ok bool) {
+	_, ok = manager.handlers.Load(pid)
+	return
+}
+
+func (manager *Manager) Get(pid string) (info Info, ok bool) {
+	handler0, has := manager.handlers.Load(pid)
+	if !has {
+		return
+	}
+	info = handler0.(*Handler).Info()
+	ok = true
+	return
+}
+
+func (manager *Manager) List() (infos []Info) {
+	manager.handlers.Range(func(k, v interface{}) bool {
+		infos = append(infos, v.(*Handler).Info())
+		return true
+	})
+	slices.SortFunc(infos, func(a, b Info) int {
+		return a.StartAT.Compare(b.StartAT)
+	})
 	return
 }
 
@@ -59,14 +167,14 @@ func (manager *Manager) Cancel(pid string) (ok bool) {
 		return
 	}
 	handler := handler0.(*Handler)
-	handler.cancel()
+	handler.Cancel()
 	ok = true
 	return
 }
 
 func (manager *Manager) Shutdown() {
 	manager.handlers.Range(func(k, v interface{}) bool {
-		v.(*Handler).cancel()
+		v.(*Handler).Cancel()
 		return true
 	})
 	manager.handlers.Clear()
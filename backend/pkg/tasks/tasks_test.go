@@ -0,0 +1,54 @@
+package tasks_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"DarkestDungeonModBoxLite/backend/pkg/tasks"
+)
+
+func TestManager_CancelOnStart(t *testing.T) {
+	var manager *tasks.Manager
+	var wg sync.WaitGroup
+	wg.Add(2)
+	manager = tasks.New(func(info tasks.Info) {
+		if info.Done {
+			t.Log(info.Name, info.Canceled, info.Error)
+			wg.Done()
+			return
+		}
+		// canceled as soon as it is listed
+		manager.Cancel(info.Id)
+	})
+	task := tasks.TaskFunc(func(ctx context.Context, progress *tasks.Progress) (err error) {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	manager.Execute(context.Background(), "execute", task)
+	if _, err := manager.Run(context.Background(), "run", task); !errors.Is(err, context.Canceled) {
+		t.Error("run is not canceled", err)
+	}
+	wg.Wait()
+	if list := manager.List(); len(list) != 0 {
+		t.Error("tasks are still listed", list)
+	}
+}
+
+func TestManager_Shutdown(t *testing.T) {
+	manager := tasks.New(nil)
+	started := make(chan struct{})
+	done := make(chan error)
+	manager.Execute(context.Background(), "execute", tasks.TaskFunc(func(ctx context.Context, progress *tasks.Progress) (err error) {
+		close(started)
+		<-ctx.Done()
+		done <- ctx.Err()
+		return
+	}))
+	<-started
+	manager.Shutdown()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Error("task is not canceled", err)
+	}
+}
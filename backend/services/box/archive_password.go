@@ -0,0 +1,175 @@
+package box
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"DarkestDungeonModBoxLite/backend/pkg/databases"
+	"DarkestDungeonModBoxLite/backend/pkg/failure"
+)
+
+// ArchivePassword
+// a known password of archive files, which is tried when an archive file or its archived entries need passwords.
+// Hits is the count of archive files opened by it.
+type ArchivePassword struct {
+	Password string    `json:"password"`
+	Hits     int       `json:"hits"`
+	ModifyAT time.Time `json:"modifyAT"`
+}
+
+func (password *ArchivePassword) Key() string {
+	return archivePasswordKey(password.Password)
+}
+
+func archivePasswordKey(password string) string {
+	return fmt.Sprintf("archive_password:%s", password)
+}
+
+// archiveHashPassword
+// the password which opened the archive file of hash.
+type archiveHashPassword struct {
+	Hash     string `json:"hash"`
+	Password string `json:"password"`
+}
+
+func archiveHashPasswordKey(hash string) string {
+	return fmt.Sprintf("archive_hash:%s", hash)
+}
+
+// ListArchivePasswords returns known passwords, the most used ones come first.
+func (bx *Box) ListArchivePasswords() (passwords []ArchivePassword, err error) {
+	var (
+		db *databases.Database
+	)
+	if db, err = bx.database(); err != nil {
+		return
+	}
+	passwords = make([]ArchivePassword, 0, 1)
+	if err = db.AscendKeys(archivePasswordKey("*"), &passwords, nil); err != nil {
+		err = failure.Failed("获取压缩包密码失败", err.Error())
+		return
+	}
+	sort.SliceStable(passwords, func(i, j int) bool {
+		if passwords[i].Hits != passwords[j].Hits {
+			return passwords[i].Hits > passwords[j].Hits
+		}
+		return passwords[i].ModifyAT.After(passwords[j].ModifyAT)
+	})
+	return
+}
+
+// SaveArchivePassword adds password into known passwords, hits of it are kept when it exists.
+func (bx *Box) SaveArchivePassword(password string) (err error) {
+	var (
+		db *databases.Database
+	)
+	password = strings.TrimSpace(password)
+	if password == "" {
+		err = failure.Failed("保存压缩包密码失败", "密码为空")
+		return
+	}
+	if db, err = bx.database(); err != nil {
+		return
+	}
+	v := ArchivePassword{Password: password}
+	if _, err = db.Get(v.Key(), &v); err != nil {
+		err = failure.Failed("保存压缩包密码失败", err.Error())
+		return
+	}
+	v.ModifyAT = time.Now()
+	if err = db.Update(v.Key(), v); err != nil {
+		err = failure.Failed("保存压缩包密码失败", err.Error())
+		return
+	}
+	return
+}
+
+// RemoveArchivePassword removes password from known passwords, and archive files remembered with it are forgotten.
+func (bx *Box) RemoveArchivePassword(password string) (err error) {
+	var (
+		db *databases.Database
+	)
+	if db, err = bx.database(); err != nil {
+		return
+	}
+	remembered := make([]archiveHashPassword, 0, 1)
+	if err = db.AscendKeys(archiveHashPasswordKey("*"), &remembered, nil); err != nil {
+		err = failure.Failed("删除压缩包密码失败", err.Error())
+		return
+	}
+	err = db.Transaction(func(tx *databases.Tx) (err error) {
+		for _, item := range remembered {
+			if item.Password != password {
+				continue
+			}
+			if err = tx.Remove(archiveHashPasswordKey(item.Hash)); err != nil {
+				return
+			}
+		}
+		err = tx.Remove(archivePasswordKey(password))
+		return
+	})
+	if err != nil {
+		err = failure.Failed("删除压缩包密码失败", err.Error())
+		return
+	}
+	return
+}
+
+// archiveKeyring
+// known passwords as candidates of archive files, the remembered one of an archive file comes first.
+type archiveKeyring struct {
+	db        *databases.Database
+	passwords []string
+}
+
+func (bx *Box) archiveKeyring() (keyring *archiveKeyring, err error) {
+	var (
+		db        *databases.Database
+		passwords []ArchivePassword
+	)
+	if db, err = bx.database(); err != nil {
+		return
+	}
+	if passwords, err = bx.ListArchivePasswords(); err != nil {
+		return
+	}
+	keyring = &archiveKeyring{
+		db:        db,
+		passwords: make([]string, 0, len(passwords)),
+	}
+	for _, password := range passwords {
+		keyring.passwords = append(keyring.passwords, password.Password)
+	}
+	return
+}
+
+func (keyring *archiveKeyring) Candidates(hash uint64) (passwords []string) {
+	remembered := archiveHashPassword{}
+	if has, _ := keyring.db.Get(archiveHashPasswordKey(strconv.FormatUint(hash, 16)), &remembered); has && remembered.Password != "" {
+		passwords = append(passwords, remembered.Password)
+	}
+	passwords = append(passwords, keyring.passwords...)
+	return
+}
+
+// Remember records password of the archive file, and counts a hit of it, it is added when it is a new one.
+func (keyring *archiveKeyring) Remember(hash uint64, password string) {
+	key := strconv.FormatUint(hash, 16)
+	_ = keyring.db.Transaction(func(tx *databases.Tx) (err error) {
+		v := ArchivePassword{Password: password}
+		if _, err = tx.Get(v.Key(), &v); err != nil {
+			return
+		}
+		v.Hits++
+		v.ModifyAT = time.Now()
+		if err = tx.Update(v.Key(), v); err != nil {
+			return
+		}
+		err = tx.Update(archiveHashPasswordKey(key), archiveHashPassword{Hash: key, Password: password})
+		return
+	})
+}
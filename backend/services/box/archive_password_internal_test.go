@@ -0,0 +1,103 @@
+package box
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestArchiveKeyring(t *testing.T) {
+	bx := newTestBox(t)
+	for _, password := range []string{"111", "222"} {
+		if err := bx.SaveArchivePassword(password); err != nil {
+			t.Fatal(err)
+		}
+	}
+	keyring, keyringErr := bx.archiveKeyring()
+	if keyringErr != nil {
+		t.Fatal(keyringErr)
+	}
+	const hash = uint64(0xdd)
+	candidates := keyring.Candidates(hash)
+	t.Log(candidates)
+	if len(candidates) != 2 || !slices.Contains(candidates, "111") || !slices.Contains(candidates, "222") {
+		t.Fatal("candidates without remembered", candidates)
+	}
+
+	// a known one counts a hit, and the archive file remembers it
+	keyring.Remember(hash, "222")
+	if candidates = keyring.Candidates(hash); len(candidates) != 3 || candidates[0] != "222" {
+		t.Error("remembered is not the first", candidates)
+	}
+	if candidates = keyring.Candidates(hash + 1); len(candidates) != 2 {
+		t.Error("other archive file has remembered", candidates)
+	}
+	remembered := archiveHashPassword{}
+	if has, err := bx.db.Get(archiveHashPasswordKey(strconv.FormatUint(hash, 16)), &remembered); !has || err != nil || remembered.Password != "222" {
+		t.Error("hash is not remembered", has, err, remembered)
+	}
+
+	// a new one is added
+	keyring.Remember(hash+1, "333")
+	passwords, listErr := bx.ListArchivePasswords()
+	if listErr != nil {
+		t.Fatal(listErr)
+	}
+	t.Log(passwords)
+	hits := make(map[string]int)
+	for _, password := range passwords {
+		hits[password.Password] = password.Hits
+	}
+	if len(hits) != 3 || hits["111"] != 0 || hits["222"] != 1 || hits["333"] != 1 {
+		t.Error("hits are", hits)
+	}
+	if passwords[len(passwords)-1].Password != "111" {
+		t.Error("unused password is not the last", passwords)
+	}
+}
+
+func TestBox_RemoveArchivePassword(t *testing.T) {
+	bx := newTestBox(t)
+	for _, password := range []string{"111", "222"} {
+		if err := bx.SaveArchivePassword(password); err != nil {
+			t.Fatal(err)
+		}
+	}
+	keyring, keyringErr := bx.archiveKeyring()
+	if keyringErr != nil {
+		t.Fatal(keyringErr)
+	}
+	keyring.Remember(1, "111")
+	keyring.Remember(2, "111")
+	keyring.Remember(3, "222")
+
+	if err := bx.RemoveArchivePassword("111"); err != nil {
+		t.Fatal(err)
+	}
+	passwords, listErr := bx.ListArchivePasswords()
+	if listErr != nil {
+		t.Fatal(listErr)
+	}
+	t.Log(passwords)
+	if len(passwords) != 1 || passwords[0].Password != "222" {
+		t.Error("passwords are", passwords)
+	}
+	for hash, expect := range map[uint64]string{1: "", 2: "", 3: "222"} {
+		remembered := archiveHashPassword{}
+		has, err := bx.db.Get(archiveHashPasswordKey(strconv.FormatUint(hash, 16)), &remembered)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if has != (expect != "") || remembered.Password != expect {
+			t.Error("hash", hash, "remembers", has, remembered.Password)
+		}
+	}
+
+	// a keyring made after removing does not offer it
+	if keyring, keyringErr = bx.archiveKeyring(); keyringErr != nil {
+		t.Fatal(keyringErr)
+	}
+	if candidates := keyring.Candidates(1); !slices.Equal(candidates, []string{"222"}) {
+		t.Error("candidates after removing", candidates)
+	}
+}
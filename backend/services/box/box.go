@@ -9,18 +9,17 @@ import (
 	"DarkestDungeonModBoxLite/backend/pkg/databases"
 	"DarkestDungeonModBoxLite/backend/pkg/failure"
 	"DarkestDungeonModBoxLite/backend/pkg/files"
+	"DarkestDungeonModBoxLite/backend/pkg/tasks"
 )
 
-type Process struct {
-	Id string
-}
-
 type Box struct {
 	ctx       context.Context
 	cancel    context.CancelFunc
 	db        *databases.Database
 	moduleFS  *files.DirFS
-	processes sync.Map
+	processes *tasks.Manager
+	syncing   sync.Mutex
+	syncPid   string
 	err       error
 }
 
@@ -65,9 +64,13 @@ func (bx *Box) startup(ctx context.Context) {
 		return
 	}
 	bx.db = db
+	// game, a broken saved structure falls back to the embedded one
+	_ = bx.loadGameFileStruct()
 
 	// ctx
 	bx.ctx, bx.cancel = context.WithCancel(ctx)
+	// tasks
+	bx.processes = tasks.New(bx.emitTask)
 	return
 }
 
@@ -75,6 +78,7 @@ func (bx *Box) shutdown(_ context.Context) {
 	if bx.err != nil {
 		return
 	}
+	bx.processes.Shutdown()
 	bx.cancel()
 	bx.db.Close()
 	return
@@ -0,0 +1,69 @@
+package box
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"DarkestDungeonModBoxLite/backend/pkg/databases"
+	"DarkestDungeonModBoxLite/backend/pkg/files"
+	"DarkestDungeonModBoxLite/backend/pkg/tasks"
+)
+
+// newTestBox returns a box with a database and a mods dir in a temp dir.
+func newTestBox(t *testing.T) (bx *Box) {
+	dir := t.TempDir()
+	modsDir := filepath.Join(dir, "mods")
+	if err := os.MkdirAll(modsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	moduleFS, moduleErr := files.NewDirFS(modsDir)
+	if moduleErr != nil {
+		t.Fatal(moduleErr)
+	}
+	db, dbErr := databases.New(filepath.Join(dir, "database.db"), databaseIndexes()...)
+	if dbErr != nil {
+		t.Fatal(dbErr)
+	}
+	t.Cleanup(db.Close)
+	bx = &Box{
+		ctx:       context.Background(),
+		db:        db,
+		moduleFS:  moduleFS,
+		processes: tasks.New(nil),
+	}
+	return
+}
+
+// saveTestModule saves module of version 1.0.0 with files, keys of files are slash-relative names.
+func saveTestModule(t *testing.T, bx *Box, id string, files map[string]string) (module *Module) {
+	version := Version{Major: 1}
+	for name, content := range files {
+		filename := filepath.Join(bx.moduleFS.Path(), id, version.String(), filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	module = &Module{Id: id, Title: id}
+	module.Add(VersionedModule{Version: version})
+	if err := bx.db.Update(moduleKey(id), module); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+// testPreviewIcon is a png of one pixel.
+func testPreviewIcon(t *testing.T) []byte {
+	icon := bytes.NewBuffer(nil)
+	if err := png.Encode(icon, image.NewRGBA(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	return icon.Bytes()
+}
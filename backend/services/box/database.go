@@ -7,20 +7,24 @@ import (
 )
 
 const (
-	schemaIdIndex    = "schema_id"
-	moduleIdIndex    = "module_id"
-	moduleKindIndex  = "module_kind"
-	moduleTitleIndex = "module_title"
+	schemaIdIndex     = "schema_id"
+	schemaModuleIndex = "schema_module"
+	moduleIdIndex     = "module_id"
+	moduleKindIndex   = "module_kind"
+	moduleTitleIndex  = "module_title"
+	moduleModifyIndex = "module_modify"
 )
 
 func databaseIndexes() (v []databases.Index) {
 	v = append(v,
 		// schema
 		databases.CreateIndex(schemaIdIndex, "schema:*", buntdb.IndexJSON("id")),
+		databases.CreateIndex(schemaModuleIndex, "plan:*", buntdb.IndexJSON("index")),
 		// module
-		databases.CreateIndex(moduleIdIndex, "module:*", buntdb.IndexJSON("id")),
-		databases.CreateIndex(moduleKindIndex, "module:*", buntdb.IndexJSON("kind"), buntdb.IndexJSON("id")),
-		databases.CreateIndex(moduleTitleIndex, "module:*", buntdb.IndexJSON("title"), buntdb.IndexJSON("id")),
+		databases.CreateIndex(moduleIdIndex, "mod:*", buntdb.IndexJSON("id")),
+		databases.CreateIndex(moduleKindIndex, "mod:*", buntdb.IndexJSON("kind"), buntdb.IndexJSON("id")),
+		databases.CreateIndex(moduleTitleIndex, "mod:*", buntdb.IndexJSON("title"), buntdb.IndexJSON("id")),
+		databases.CreateIndex(moduleModifyIndex, "mod:*", databases.IndexJSONTime("modifyAT"), buntdb.IndexJSON("id")),
 	)
 	return
 }
@@ -1,40 +1,252 @@
 package box
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
+	"DarkestDungeonModBoxLite/backend/pkg/databases"
+	"DarkestDungeonModBoxLite/backend/pkg/failure"
 	"DarkestDungeonModBoxLite/backend/pkg/files"
+	"DarkestDungeonModBoxLite/backend/pkg/tasks"
 	"DarkestDungeonModBoxLite/backend/services/box/internal/resources"
 )
 
+const (
+	gameFileStructKey = "game:struct"
+)
+
 var (
 	_gameFileStructOnce sync.Once
 	_gameFileStruct     *files.Structure
+	// _savedGameFileStruct is scanned from the game, which is preferred to the embedded one
+	_savedGameFileStruct       *files.Structure
+	_savedGameFileStructLocker sync.RWMutex
 )
 
+// GetModuleFileStruct returns file structure of game resources, with project.xml and preview icon of module.
+// the structure saved by RegenerateGameFileStruct is preferred, otherwise the embedded one is returned.
 func GetModuleFileStruct() *files.Structure {
+	_savedGameFileStructLocker.RLock()
+	saved := _savedGameFileStruct
+	_savedGameFileStructLocker.RUnlock()
+	if saved != nil {
+		return saved
+	}
 	_gameFileStructOnce.Do(func() {
-		s := &files.Structure{}
-		if err := json.Unmarshal(resources.GameResStruct, s); err != nil {
-			panic(fmt.Errorf("decode game file struct failed, %v", err))
-		}
-		s.Children = append(s.Children,
-			files.Structure{
-				Name:     "project.xml",
-				IsDir:    false,
-				Children: nil,
-			},
-			files.Structure{
-				Name:     "preview_icon.png",
-				IsDir:    false,
-				Children: nil,
-			},
-		)
-		_gameFileStruct = s
+		_gameFileStruct = withModuleFiles(embeddedGameFileStruct())
 	})
 	return _gameFileStruct
 }
 
-// ------------------
+func embeddedGameFileStruct() *files.Structure {
+	s := &files.Structure{}
+	if err := json.Unmarshal(resources.GameResStruct, s); err != nil {
+		panic(fmt.Errorf("decode game file struct failed, %v", err))
+	}
+	return s
+}
+
+func withModuleFiles(s *files.Structure) *files.Structure {
+	module := *s
+	module.Children = append(slices.Clip(s.Children),
+		files.Structure{
+			Name:     "project.xml",
+			IsDir:    false,
+			Children: nil,
+		},
+		files.Structure{
+			Name:     "preview_icon.png",
+			IsDir:    false,
+			Children: nil,
+		},
+	)
+	return &module
+}
+
+func setSavedGameFileStruct(s *files.Structure) {
+	if s != nil {
+		s = withModuleFiles(s)
+	}
+	_savedGameFileStructLocker.Lock()
+	_savedGameFileStruct = s
+	_savedGameFileStructLocker.Unlock()
+}
+
+// GameFileStruct
+// scanned from the game dir, BuildId is of steam when the game is installed by steam.
+type GameFileStruct struct {
+	Game      string          `json:"game"`
+	BuildId   string          `json:"buildId"`
+	ScanAT    time.Time       `json:"scanAT"`
+	Structure files.Structure `json:"structure"`
+}
+
+// GameFileStructDiff
+// Added and Removed are slash-relative paths compared with the embedded structure,
+// only the topmost one of an added or removed dir is listed.
+type GameFileStructDiff struct {
+	Game    string    `json:"game"`
+	BuildId string    `json:"buildId"`
+	ScanAT  time.Time `json:"scanAT"`
+	Files   int       `json:"files"`
+	Added   []string  `json:"added"`
+	Removed []string  `json:"removed"`
+}
+
+// loadGameFileStruct uses the saved structure when box starts.
+func (bx *Box) loadGameFileStruct() (err error) {
+	var (
+		db  *databases.Database
+		has bool
+	)
+	if db, err = bx.database(); err != nil {
+		return
+	}
+	saved := GameFileStruct{}
+	if has, err = db.Get(gameFileStructKey, &saved); err != nil {
+		err = failure.Failed("游戏", "读取游戏文件结构失败").Wrap(err)
+		return
+	}
+	if has {
+		setSavedGameFileStruct(&saved.Structure)
+	}
+	return
+}
+
+// RegenerateGameFileStruct scans resources of the game dir, and saves the structure,
+// which is used to classify and validate modules instead of the embedded one.
+func (bx *Box) RegenerateGameFileStruct() (diff *GameFileStructDiff, err error) {
+	var (
+		db       *databases.Database
+		settings Settings
+	)
+	if db, err = bx.database(); err != nil {
+		return
+	}
+	if settings, err = bx.Settings(); err != nil {
+		return
+	}
+	if exist, _ := files.Exist(settings.Game); settings.Game == "" || !exist {
+		err = failure.Failed("游戏", "游戏目录不存在").Append("位置", settings.Game)
+		return
+	}
+	saved := GameFileStruct{
+		Game:   settings.Game,
+		ScanAT: time.Now(),
+	}
+	if game, gameErr := bx.GetSteamGame(); gameErr == nil && sameGameDir(game.Dir, settings.Game) {
+		saved.BuildId = game.BuildId
+	}
+	_, err = bx.processes.Run(bx.ctx, gameScanTaskName, tasks.TaskFunc(func(ctx context.Context, progress *tasks.Progress) (err error) {
+		progress.Step(fmt.Sprintf("扫描 %s", settings.Game))
+		// a canceled scan is not saved
+		scanned, scanErr := files.FileStructureContext(ctx, settings.Game)
+		if scanErr != nil {
+			err = failure.Failed("游戏", "扫描游戏目录失败").Append("位置", settings.Game).Wrap(scanErr)
+			return
+		}
+		saved.Structure = *gameResources(scanned)
+		return
+	}))
+	if err != nil {
+		return
+	}
+	if err = db.Update(gameFileStructKey, saved); err != nil {
+		err = failure.Failed("游戏", "保存游戏文件结构失败").Wrap(err)
+		return
+	}
+	setSavedGameFileStruct(&saved.Structure)
+	diff = diffGameFileStruct(embeddedGameFileStruct(), &saved.Structure)
+	diff.Game = saved.Game
+	diff.BuildId = saved.BuildId
+	diff.ScanAT = saved.ScanAT
+	return
+}
+
+// ResetGameFileStruct removes the saved structure, the embedded one is used again.
+func (bx *Box) ResetGameFileStruct() (err error) {
+	var (
+		db *databases.Database
+	)
+	if db, err = bx.database(); err != nil {
+		return
+	}
+	if err = db.Remove(gameFileStructKey); err != nil {
+		err = failure.Failed("游戏", "删除游戏文件结构失败").Wrap(err)
+		return
+	}
+	setSavedGameFileStruct(nil)
+	return
+}
+
+// gameResources keeps resources of scanned game dir,
+// the mods dir, platform dirs such as _windows, and programs are not resources.
+func gameResources(scanned *files.Structure) *files.Structure {
+	kept := &files.Structure{Name: scanned.Name, IsDir: true}
+	for _, child := range scanned.Children {
+		if child.IsDir && (strings.EqualFold(child.Name, "mods") || strings.HasPrefix(child.Name, "_")) {
+			continue
+		}
+		if !child.IsDir && slices.Contains([]string{".exe", ".dll", ".so", ".dylib", ".pdb"}, strings.ToLower(filepath.Ext(child.Name))) {
+			continue
+		}
+		kept.Children = append(kept.Children, child)
+	}
+	return kept
+}
+
+func diffGameFileStruct(embedded *files.Structure, scanned *files.Structure) (diff *GameFileStructDiff) {
+	diff = &GameFileStructDiff{}
+	var count func(children []files.Structure) int
+	count = func(children []files.Structure) (n int) {
+		for _, child := range children {
+			if child.IsDir {
+				n += count(child.Children)
+			} else {
+				n++
+			}
+		}
+		return
+	}
+	diff.Files = count(scanned.Children)
+	var walk func(parent string, a []files.Structure, b []files.Structure)
+	walk = func(parent string, a []files.Structure, b []files.Structure) {
+		aChildren := make(map[string]files.Structure, len(a))
+		for _, child := range a {
+			aChildren[child.Name] = child
+		}
+		bChildren := make(map[string]files.Structure, len(b))
+		for _, child := range b {
+			bChildren[child.Name] = child
+			name := path.Join(parent, child.Name)
+			aChild, has := aChildren[child.Name]
+			switch {
+			case !has || aChild.IsDir != child.IsDir:
+				diff.Added = append(diff.Added, name)
+			case child.IsDir:
+				walk(name, aChild.Children, child.Children)
+			}
+		}
+		for _, child := range a {
+			if bChild, has := bChildren[child.Name]; !has || bChild.IsDir != child.IsDir {
+				diff.Removed = append(diff.Removed, path.Join(parent, child.Name))
+			}
+		}
+	}
+	walk("", embedded.Children, scanned.Children)
+	slices.Sort(diff.Added)
+	slices.Sort(diff.Removed)
+	return
+}
+
+func sameGameDir(a string, b string) bool {
+	a, b = filepath.Clean(a), filepath.Clean(b)
+	return a == b || strings.EqualFold(a, b)
+}
@@ -0,0 +1,144 @@
+package box
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"DarkestDungeonModBoxLite/backend/pkg/files"
+)
+
+func testStructFile(name string) files.Structure {
+	return files.Structure{Name: name}
+}
+
+func testStructDir(name string, children ...files.Structure) files.Structure {
+	return files.Structure{Name: name, IsDir: true, Children: children}
+}
+
+func TestGameResources(t *testing.T) {
+	cases := []struct {
+		name     string
+		children []files.Structure
+		expect   []string
+	}{
+		{"resources", []files.Structure{testStructDir("heroes"), testStructFile("svn_revision.txt")}, []string{"heroes", "svn_revision.txt"}},
+		{"mods", []files.Structure{testStructDir("heroes"), testStructDir("mods"), testStructDir("Mods")}, []string{"heroes"}},
+		{"platform dirs", []files.Structure{testStructDir("_windows"), testStructDir("_linux"), testStructDir("shared")}, []string{"shared"}},
+		{"programs", []files.Structure{testStructFile("Darkest.exe"), testStructFile("steam_api.DLL"), testStructFile("libfmod.so"), testStructFile("readme.txt")}, []string{"readme.txt"}},
+		{"file named as a dir", []files.Structure{testStructFile("mods"), testStructFile("_notes")}, []string{"mods", "_notes"}},
+	}
+	for _, c := range cases {
+		kept := gameResources(&files.Structure{Name: "DarkestDungeon", IsDir: true, Children: c.children})
+		names := make([]string, 0, len(kept.Children))
+		for _, child := range kept.Children {
+			names = append(names, child.Name)
+		}
+		if !slices.Equal(names, c.expect) || kept.Name != "DarkestDungeon" || !kept.IsDir {
+			t.Error(c.name, "expect", c.expect, "got", names)
+		}
+	}
+}
+
+func TestDiffGameFileStruct(t *testing.T) {
+	embedded := []files.Structure{
+		testStructDir("heroes",
+			testStructDir("crusader", testStructFile("crusader.info.darkest")),
+			testStructDir("vestal", testStructFile("vestal.info.darkest")),
+		),
+		testStructDir("trinkets", testStructFile("base.entries.trinkets.json")),
+		testStructFile("svn_revision.txt"),
+	}
+	cases := []struct {
+		name    string
+		scanned []files.Structure
+		files   int
+		added   []string
+		removed []string
+	}{
+		{"same", embedded, 4, nil, nil},
+		{
+			"added",
+			append(slices.Clone(embedded),
+				testStructDir("dlc", testStructFile("a.txt"), testStructFile("b.txt")),
+				testStructFile("readme.txt"),
+			),
+			7,
+			[]string{"dlc", "readme.txt"},
+			nil,
+		},
+		{
+			"removed",
+			[]files.Structure{
+				testStructDir("heroes", testStructDir("crusader", testStructFile("crusader.info.darkest"))),
+				testStructFile("svn_revision.txt"),
+			},
+			2,
+			nil,
+			[]string{"heroes/vestal", "trinkets"},
+		},
+		{
+			"nested",
+			[]files.Structure{
+				testStructDir("heroes",
+					testStructDir("crusader", testStructFile("crusader.info.darkest"), testStructFile("crusader.art.darkest")),
+					testStructDir("vestal"),
+				),
+				testStructDir("trinkets", testStructFile("base.entries.trinkets.json")),
+				testStructFile("svn_revision.txt"),
+			},
+			4,
+			[]string{"heroes/crusader/crusader.art.darkest"},
+			[]string{"heroes/vestal/vestal.info.darkest"},
+		},
+		{
+			"changed between file and dir",
+			[]files.Structure{
+				testStructFile("heroes"),
+				testStructDir("trinkets", testStructFile("base.entries.trinkets.json")),
+				testStructDir("svn_revision.txt"),
+			},
+			2,
+			[]string{"heroes", "svn_revision.txt"},
+			[]string{"heroes", "svn_revision.txt"},
+		},
+	}
+	for _, c := range cases {
+		diff := diffGameFileStruct(
+			&files.Structure{Name: "DarkestDungeon", IsDir: true, Children: embedded},
+			&files.Structure{Name: "DarkestDungeon", IsDir: true, Children: c.scanned},
+		)
+		t.Log(c.name, diff.Files, diff.Added, diff.Removed)
+		if diff.Files != c.files || !slices.Equal(diff.Added, c.added) || !slices.Equal(diff.Removed, c.removed) {
+			t.Error(c.name, "expect", c.files, c.added, c.removed, "got", diff.Files, diff.Added, diff.Removed)
+		}
+	}
+}
+
+func TestBox_RegenerateGameFileStruct_Canceled(t *testing.T) {
+	bx := newTestBox(t)
+	game := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(game, "heroes", "crusader"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := bx.UpdateSettings(Settings{Game: game}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	bx.ctx = ctx
+	embedded := GetModuleFileStruct()
+	diff, err := bx.RegenerateGameFileStruct()
+	t.Log(err)
+	if err == nil || diff != nil {
+		t.Fatal("canceled scan is done", diff)
+	}
+	if has, _ := bx.db.Get(gameFileStructKey, &GameFileStruct{}); has {
+		t.Error("canceled scan is saved")
+	}
+	if GetModuleFileStruct() != embedded {
+		t.Error("canceled scan is used")
+	}
+}
@@ -0,0 +1,445 @@
+package box
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"DarkestDungeonModBoxLite/backend/pkg/archives"
+	"DarkestDungeonModBoxLite/backend/pkg/databases"
+	"DarkestDungeonModBoxLite/backend/pkg/failure"
+	"DarkestDungeonModBoxLite/backend/pkg/images"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+const (
+	modpackFormat           = 1
+	modpackManifestFilename = "modpack.json"
+	modpackModulesDir       = "modules"
+)
+
+// Modpack
+// manifest of a modpack, which is an archive of a schema with files of its modules.
+/* archive
+modpack.json
+modules/
+ {mod id}/
+  {version}/
+	project.xml
+	...
+*/
+type Modpack struct {
+	Format   int             `json:"format"`
+	Name     string          `json:"name"`
+	ExportAT time.Time       `json:"exportAT"`
+	Modules  []ModpackModule `json:"modules"`
+}
+
+// ModpackModule
+// SchemaModule is in load order with its pinned version, Packed is the version of packed files, Hash is of their contents.
+type ModpackModule struct {
+	SchemaModule
+	PublishId string  `json:"publishId"`
+	Title     string  `json:"title"`
+	Kind      string  `json:"kind"`
+	Packed    Version `json:"packed"`
+	Hash      string  `json:"hash"`
+}
+
+func (module *ModpackModule) dir() string {
+	return path.Join(modpackModulesDir, module.ModId, module.Packed.String())
+}
+
+// ExportModpack packs schema with files of its modules into filename, which is .zip or .7z.
+func (bx *Box) ExportModpack(id string, filename string, password string) (modpack *Modpack, err error) {
+	var (
+		schema  *Schema
+		sources []schemaModuleSource
+	)
+	if schema, err = bx.GetSchema(id); err != nil {
+		return
+	}
+	if err = checkPackFilename(filename); err != nil {
+		return
+	}
+	if sources, err = bx.listSchemaModuleSources(id); err != nil {
+		return
+	}
+	if len(sources) == 0 {
+		err = failure.Failed("整合包", fmt.Sprintf("%s 没有模组", schema.Name))
+		return
+	}
+	modpack = &Modpack{
+		Format:   modpackFormat,
+		Name:     schema.Name,
+		ExportAT: time.Now(),
+		Modules:  make([]ModpackModule, 0, len(sources)),
+	}
+	entries := make([]archives.PackEntry, 0, 1)
+	for _, source := range sources {
+		module := ModpackModule{
+			SchemaModule: source.SchemaModule,
+			PublishId:    source.module.PublishId,
+			Title:        source.module.Title,
+			Kind:         source.module.Kind,
+			Packed:       source.version,
+		}
+		module.PlanId = ""
+		dir := source.dir(bx.moduleFS)
+		hash, hashErr := moduleContentHash(dir)
+		if hashErr != nil {
+			err = failure.Failed("整合包", fmt.Sprintf("读取 %s 失败", source.module.Title)).Wrap(hashErr)
+			return
+		}
+		module.Hash = hash
+		moduleEntries, entriesErr := archives.DirEntries(os.DirFS(dir), module.dir())
+		if entriesErr != nil {
+			err = failure.Failed("整合包", fmt.Sprintf("读取 %s 失败", source.module.Title)).Wrap(entriesErr)
+			return
+		}
+		entries = append(entries, moduleEntries...)
+		modpack.Modules = append(modpack.Modules, module)
+	}
+	manifest, encodeErr := json.MarshalIndent(modpack, "", "\t")
+	if encodeErr != nil {
+		err = failure.Failed("整合包", "生成清单失败").Wrap(encodeErr)
+		return
+	}
+	entries = append(entries, contentEntry(modpackManifestFilename, manifest))
+	err = bx.pack(filename, entries, password)
+	return
+}
+
+// moduleContentHash is xxhash of names and contents of files in dir.
+func moduleContentHash(dir string) (hash string, err error) {
+	hashes, hashErr := hashDirFiles(dir)
+	if hashErr != nil {
+		err = hashErr
+		return
+	}
+	names := make([]string, 0, len(hashes))
+	for name := range hashes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	h := xxhash.New()
+	for _, name := range names {
+		_, _ = h.WriteString(name)
+		_, _ = h.Write(binary.LittleEndian.AppendUint64([]byte{0}, hashes[name]))
+	}
+	hash = strconv.FormatUint(h.Sum64(), 16)
+	return
+}
+
+// ModpackImportPlan
+// Modules are by modules of Modpack, Import is the plan of modules which are missing or changed locally,
+// it is nil when all modules are reused.
+type ModpackImportPlan struct {
+	Source   string              `json:"source"`
+	Password string              `json:"password"`
+	Modpack  Modpack             `json:"modpack"`
+	Modules  []ModpackModulePlan `json:"modules"`
+	Import   *ImportPlan         `json:"import"`
+}
+
+// ModpackModulePlan
+// the local module LocalId is reused when it has the same version and contents,
+// otherwise Plan is the index of module in Import.
+type ModpackModulePlan struct {
+	ModId   string `json:"modId"`
+	Reused  bool   `json:"reused"`
+	LocalId string `json:"localId"`
+	Plan    int    `json:"plan"`
+}
+
+// MakeModpackImportPlan reads manifest of modpack, and plans modules which are not reused.
+// a module is reused when the local one of the same publish id, or the same id when it is not published,
+// has the same version and contents, otherwise it is added or overridden as same as importing modules.
+func (bx *Box) MakeModpackImportPlan(filename string, password string) (plan *ModpackImportPlan, err error) {
+	src, srcErr := os.Open(filename)
+	if srcErr != nil {
+		err = failure.Failed("导入整合包失败", "无法打开 "+filename)
+		return
+	}
+	defer src.Close()
+	file, fileErr := archives.New(filename, src)
+	if fileErr != nil {
+		err = failure.Failed("导入整合包失败", "无法解压 "+filename)
+		return
+	}
+	if password != "" {
+		file.SetPassword(password)
+	}
+	keyring, keyringErr := bx.archiveKeyring()
+	if keyringErr != nil {
+		err = keyringErr
+		return
+	}
+	file.SetKeyring(keyring)
+	info, infoErr := file.Info(bx.ctx, modpackManifestFilename, "project.xml", "preview_icon.png")
+	if infoErr != nil {
+		// nested archives failed by passwords are not packed by modpack
+		_, nested := archives.IsPasswordFailed(infoErr)
+		if !nested && errors.Is(infoErr, archives.ErrPasswordRequired) {
+			err = failure.Failed("导入整合包失败", "需要密码").Append("文件", filename)
+		} else if !nested && errors.Is(infoErr, archives.ErrPasswordInvalid) {
+			err = failure.Failed("导入整合包失败", "密码错误").Append("文件", filename)
+		} else {
+			err = failure.Failed("导入整合包失败", "扫描 "+filename+" 失败").Wrap(infoErr)
+		}
+		return
+	}
+	// the password may be found by keyring
+	password = info.Password
+	manifest := archiveInfoChild(info, modpackManifestFilename)
+	if manifest == nil || len(manifest.Preview) == 0 {
+		err = failure.Failed("导入整合包失败", fmt.Sprintf("%s 不是整合包", filename))
+		return
+	}
+	plan = &ModpackImportPlan{
+		Source:   filepath.ToSlash(filename),
+		Password: password,
+	}
+	if decodeErr := json.Unmarshal(manifest.Preview, &plan.Modpack); decodeErr != nil {
+		plan = nil
+		err = failure.Failed("导入整合包失败", fmt.Sprintf("解析 %s 失败", modpackManifestFilename)).Wrap(decodeErr)
+		return
+	}
+	if plan.Modpack.Format > modpackFormat {
+		err = failure.Failed("导入整合包失败", "整合包版本过新，请升级模组盒子")
+		plan = nil
+		return
+	}
+	importPlan := &ImportPlan{
+		Source:   plan.Source,
+		Archived: &ImportArchiveFileStats{},
+	}
+	importPlan.Archived.Password.Password = password
+	for _, module := range plan.Modpack.Modules {
+		node := archiveInfoChild(info, module.dir())
+		if node == nil {
+			plan = nil
+			err = failure.Failed("导入整合包失败", fmt.Sprintf("缺失 %s 的文件", module.Title))
+			return
+		}
+		modulePlan := makeModpackModulePlan(module, node)
+		item := ModpackModulePlan{ModId: module.ModId, Plan: -1}
+		if item.Reused, err = bx.resolveModpackModulePlan(module, &modulePlan); err != nil {
+			plan = nil
+			return
+		}
+		if item.Reused {
+			item.LocalId = modulePlan.Dst.Id
+		} else {
+			item.Plan = len(importPlan.Modules)
+			importPlan.Modules = append(importPlan.Modules, modulePlan)
+		}
+		plan.Modules = append(plan.Modules, item)
+	}
+	if len(importPlan.Modules) > 0 {
+		plan.Import = importPlan
+	}
+	return
+}
+
+// resolveModpackModulePlan finds the local module of modpack module, reused is true when it has the same contents.
+func (bx *Box) resolveModpackModulePlan(module ModpackModule, modulePlan *ModulePlan) (reused bool, err error) {
+	exists := false
+	if module.PublishId == "" {
+		exists, _ = bx.ExistsModule(module.ModId)
+	}
+	switch {
+	case exists:
+		// exported by this box
+		if modulePlan.Dst, err = bx.GetModule(module.ModId); err != nil {
+			return
+		}
+		modulePlan.Existed = true
+		if idx, ok := modulePlan.Dst.ExistVersion(module.Packed); ok {
+			modulePlan.Override = &modulePlan.Dst.Versions[idx].Version
+		}
+	default:
+		if err = bx.resolveModulePlanDst(modulePlan); err != nil {
+			return
+		}
+	}
+	if modulePlan.Dst == nil {
+		// imported from modpack before, then it has the same title, version and contents
+		for _, similar := range modulePlan.Similar {
+			if idx, ok := similar.ExistVersion(module.Packed); ok && bx.sameModuleContent(similar.Id, module.Packed, module.Hash) {
+				modulePlan.Dst = similar
+				modulePlan.Override = &similar.Versions[idx].Version
+				reused = true
+				return
+			}
+		}
+		return
+	}
+	if modulePlan.Override == nil {
+		return
+	}
+	reused = bx.sameModuleContent(modulePlan.Dst.Id, *modulePlan.Override, module.Hash)
+	return
+}
+
+func (bx *Box) sameModuleContent(id string, version Version, hash string) bool {
+	local, hashErr := moduleContentHash(filepath.Join(bx.moduleFS.Path(), id, version.String()))
+	return hashErr == nil && local == hash
+}
+
+func makeModpackModulePlan(module ModpackModule, node *archives.FileInfo) (modulePlan ModulePlan) {
+	modulePlan = ModulePlan{
+		Kind:          module.Kind,
+		PublishFileId: module.PublishId,
+		Version:       module.Packed,
+		Title:         module.Title,
+		Filename:      node.Path(),
+		IsDir:         false,
+	}
+	root := ImportEntry{}
+	root.mountArchiveFileInfo(node, true)
+	modulePlan.Entries = root.Children
+	project := ModuleProject{}
+	if projectInfo := archiveInfoChild(node, "project.xml"); projectInfo != nil {
+		_ = xml.Unmarshal(projectInfo.Preview, &project)
+	}
+	icon := strings.TrimSpace(project.PreviewIconFile)
+	if icon == "" {
+		icon = "preview_icon.png"
+	}
+	if iconInfo := archiveInfoChild(node, path.Clean(strings.ReplaceAll(icon, "\\", "/"))); iconInfo != nil && len(iconInfo.Preview) > 0 {
+		modulePlan.IconBase64, _ = images.EncodeBytes(path.Base(icon), iconInfo.Preview)
+	}
+	if st, stErr := modulePlan.FileStructure(); stErr == nil {
+		modulePlan.Kinds = ClassifyModuleByFileStructure(st, project.ListTags())
+	}
+	if modulePlan.Kind == "" {
+		modulePlan.Kind = UnknownMod
+		if len(modulePlan.Kinds) > 0 {
+			modulePlan.Kind = modulePlan.Kinds[0].Kind
+		}
+	}
+	return
+}
+
+// archiveInfoChild returns the descendant of info by slash-relative name.
+func archiveInfoChild(info *archives.FileInfo, name string) *archives.FileInfo {
+	for _, part := range strings.Split(name, "/") {
+		var found *archives.FileInfo
+		for _, child := range info.Children {
+			if child.Name == part {
+				found = child
+				break
+			}
+		}
+		if found == nil {
+			return nil
+		}
+		info = found
+	}
+	return info
+}
+
+// ImportModpack imports modules of plan which are not reused, and creates a schema of the modpack,
+// modules are pinned to the packed versions, unless they are unpinned in modpack and are the latest versions.
+func (bx *Box) ImportModpack(plan *ModpackImportPlan) (schema *Schema, err error) {
+	var (
+		db *databases.Database
+	)
+	if db, err = bx.database(); err != nil {
+		return
+	}
+	if plan == nil || len(plan.Modules) != len(plan.Modpack.Modules) {
+		err = failure.Failed("导入整合包失败", "无效导入计划")
+		return
+	}
+	if plan.Import != nil {
+		if _, err = bx.ImportModules(plan.Import); err != nil {
+			return
+		}
+	}
+	schemaModules := make([]SchemaModule, 0, len(plan.Modules))
+	for i, item := range plan.Modules {
+		packed := plan.Modpack.Modules[i]
+		localId := item.LocalId
+		if !item.Reused {
+			if item.Plan < 0 || plan.Import == nil || item.Plan >= len(plan.Import.Modules) {
+				err = failure.Failed("导入整合包失败", "无效导入计划")
+				return
+			}
+			localId = plan.Import.Modules[item.Plan].Id
+		}
+		module, moduleErr := bx.GetModule(localId)
+		if moduleErr != nil {
+			err = moduleErr
+			return
+		}
+		schemaModule := SchemaModule{
+			ModId: module.Id,
+			Index: uint(len(schemaModules)),
+		}
+		if packed.Version != nil || module.Version.Compare(packed.Packed) != 0 {
+			version := packed.Packed
+			schemaModule.Version = &version
+		}
+		schemaModules = append(schemaModules, schemaModule)
+	}
+	name, nameErr := bx.uniqueSchemaName(plan.Modpack.Name)
+	if nameErr != nil {
+		err = nameErr
+		return
+	}
+	// the schema and its modules are saved together, no empty schema is left when it fails
+	created := &Schema{
+		Id:       Id(),
+		Name:     name,
+		Deployed: false,
+		CreateAT: time.Now(),
+	}
+	err = db.Transaction(func(tx *databases.Tx) (err error) {
+		if err = tx.Update(schemaKey(created.Id), created); err != nil {
+			return
+		}
+		for _, schemaModule := range schemaModules {
+			schemaModule.PlanId = created.Id
+			if err = tx.Update(schemaModule.Key(), schemaModule); err != nil {
+				return
+			}
+		}
+		return
+	})
+	if err != nil {
+		err = failure.Failed("导入整合包失败", fmt.Sprintf("保存 %s 失败", name)).Wrap(err)
+		return
+	}
+	schema = created
+	return
+}
+
+// uniqueSchemaName returns name, or name with a number when it is used by other schemas.
+func (bx *Box) uniqueSchemaName(name string) (unique string, err error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "整合包"
+	}
+	schemas, listErr := bx.ListSchemas()
+	if listErr != nil {
+		err = listErr
+		return
+	}
+	unique = name
+	for n := 2; slices.ContainsFunc(schemas, func(schema Schema) bool { return schema.Name == unique }); n++ {
+		unique = fmt.Sprintf("%s (%d)", name, n)
+	}
+	return
+}
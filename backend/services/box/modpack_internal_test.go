@@ -0,0 +1,86 @@
+package box
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBox_ImportModpack(t *testing.T) {
+	project := func(title string) string {
+		return "<project><Title>" + title + "</Title><VersionMajor>1</VersionMajor><VersionMinor>0</VersionMinor></project>"
+	}
+	moduleFiles := map[string]map[string]string{
+		"a": {"project.xml": project("a"), "trinkets/a.trinkets.json": `{"a": 1}`},
+		"b": {"project.xml": project("b"), "heroes/paladin/paladin.info.darkest": "hero: .id \"paladin\""},
+	}
+
+	// export a schema of a, and b which is pinned
+	src := newTestBox(t)
+	schema, schemaErr := src.CreateSchema("pack")
+	if schemaErr != nil {
+		t.Fatal(schemaErr)
+	}
+	for _, id := range []string{"a", "b"} {
+		saveTestModule(t, src, id, moduleFiles[id])
+		if err := src.AddSchemaModule(schema.Id, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := src.PinSchemaModule(schema.Id, "b", &Version{Major: 1}); err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(t.TempDir(), "pack.zip")
+	if _, err := src.ExportModpack(schema.Id, filename, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// a is the same locally, b is missing
+	dst := newTestBox(t)
+	saveTestModule(t, dst, "a", moduleFiles["a"])
+	plan, planErr := dst.MakeModpackImportPlan(filename, "")
+	if planErr != nil {
+		t.Fatal(planErr)
+	}
+	t.Log(plan.Modules)
+	if len(plan.Modules) != 2 {
+		t.Fatal("planned modules", plan.Modules)
+	}
+	if item := plan.Modules[0]; !item.Reused || item.LocalId != "a" {
+		t.Error("a is not reused", item)
+	}
+	if item := plan.Modules[1]; item.Reused || item.Plan != 0 || plan.Import == nil || len(plan.Import.Modules) != 1 {
+		t.Fatal("b is not imported", item)
+	}
+
+	imported, importErr := dst.ImportModpack(plan)
+	if importErr != nil {
+		t.Fatal(importErr)
+	}
+	if imported.Name != "pack" {
+		t.Error("schema is named", imported.Name)
+	}
+	schemaModules, listErr := dst.ListSchemaModules(imported.Id)
+	if listErr != nil {
+		t.Fatal(listErr)
+	}
+	t.Log(schemaModules)
+	if len(schemaModules) != 2 {
+		t.Fatal("schema modules", schemaModules)
+	}
+	if a := schemaModules[0]; a.ModId != "a" || a.Version != nil {
+		t.Error("a should be unpinned", a.ModId, a.Version)
+	}
+	b := schemaModules[1]
+	if b.ModId != plan.Import.Modules[0].Id || b.Version == nil || b.Version.Compare(Version{Major: 1}) != 0 {
+		t.Error("b should be pinned to v1.0.0", b.ModId, b.Version)
+	}
+	module, getErr := dst.GetModule(b.ModId)
+	if getErr != nil {
+		t.Fatal(getErr)
+	}
+	p, readErr := os.ReadFile(filepath.Join(dst.moduleFS.Path(), module.Id, module.Version.String(), "heroes", "paladin", "paladin.info.darkest"))
+	if readErr != nil || string(p) != moduleFiles["b"]["heroes/paladin/paladin.info.darkest"] {
+		t.Error("files of b are not imported", readErr)
+	}
+}
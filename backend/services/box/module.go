@@ -22,6 +22,7 @@ const (
 	LocalizationMod   = "localization"
 	UIMod             = "ui"
 	GameplayTweaksMod = "gameplay_tweaks"
+	PatchMod          = "patch" // 生成的合并补丁
 	UnknownMod        = "unknown"
 )
 
@@ -57,12 +58,16 @@ type Module struct {
 	Id              string            `json:"id"`
 	PublishId       string            `json:"publishId"`
 	Kind            string            `json:"kind"`
+	Kinds           []KindCandidate   `json:"kinds"` // ranked by confidence, classified by files of the latest version
 	Title           string            `json:"title"`
 	Remark          string            `json:"remark"`
 	ModifyAT        time.Time         `json:"modifyAT"`
 	PreviewIconFile string            `json:"previewIconFile"`
 	Version         Version           `json:"version"`
 	Versions        []VersionedModule `json:"versions"`
+	Tags            []string          `json:"tags"`
+	// WorkshopUpdateAT is when steam updated the workshop item of last sync
+	WorkshopUpdateAT time.Time `json:"workshopUpdateAT"`
 }
 
 func (module *Module) Add(vm VersionedModule) {
@@ -78,6 +83,30 @@ func (module *Module) Add(vm VersionedModule) {
 	module.PreviewIconFile = filepath.ToSlash(filepath.Join(module.Id, module.Version.String(), module.Versions[len(module.Versions)-1].PreviewIconFile))
 }
 
+// Remove removes version, latest version and preview icon fall back to the previous version.
+func (module *Module) Remove(target Version) (ok bool) {
+	idx, existed := module.ExistVersion(target)
+	if !existed {
+		return
+	}
+	module.Versions = slices.Delete(module.Versions, idx, idx+1)
+	ok = true
+	if len(module.Versions) == 0 {
+		module.Version = Version{}
+		module.PreviewIconFile = ""
+		return
+	}
+	module.Version = module.Versions[len(module.Versions)-1].Version
+	module.PreviewIconFile = filepath.ToSlash(filepath.Join(module.Id, module.Version.String(), module.Versions[len(module.Versions)-1].PreviewIconFile))
+	return
+}
+
+func (module *Module) clone() *Module {
+	v := *module
+	v.Versions = slices.Clone(module.Versions)
+	return &v
+}
+
 func (module *Module) ExistVersion(target Version) (idx int, ok bool) {
 	for i, v := range module.Versions {
 		if v.Version.Compare(target) == 0 {
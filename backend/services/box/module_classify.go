@@ -0,0 +1,241 @@
+package box
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"slices"
+	"strings"
+
+	"DarkestDungeonModBoxLite/backend/pkg/failure"
+	"DarkestDungeonModBoxLite/backend/pkg/files"
+)
+
+const (
+	// kindLabelConfidence candidates at least this confident are labels of module
+	kindLabelConfidence = 0.25
+	// kindMinConfidence candidates less confident are dropped
+	kindMinConfidence = 0.05
+	// kindTagConfidence is the confidence of a kind told by tags of project.xml
+	kindTagConfidence = 0.6
+)
+
+var (
+	// supportDirs hold files which come with other kinds, such as sounds of new heroes
+	supportDirs = []string{"audio", "video", "video_ps4", "video_psv"}
+	// overhaulKinds are kinds of content, a module of many of them is an overhaul
+	overhaulKinds = []string{HeroNewMod, HeroTweaksMod, MonstersMod, TrinketsMod, GameplayTweaksMod}
+	tagKinds      = map[string]string{
+		"overhauls":      OverhaulsMod,
+		"monsters":       MonstersMod,
+		"localization":   LocalizationMod,
+		"ui":             UIMod,
+		"new hero":       HeroNewMod,
+		"new class":      HeroNewMod,
+		"classes":        HeroNewMod,
+		"skins":          HeroSkinsMod,
+		"trinkets":       TrinketsMod,
+		"gameplay tweak": GameplayTweaksMod,
+		"gameplay":       GameplayTweaksMod,
+	}
+	kindNames = map[string]string{
+		HeroNewMod:        "新英雄",
+		HeroTweaksMod:     "英雄调整",
+		HeroSkinsMod:      "英雄皮肤",
+		OverhaulsMod:      "大修",
+		TrinketsMod:       "饰品",
+		MonstersMod:       "怪物",
+		LocalizationMod:   "本地化",
+		UIMod:             "界面",
+		GameplayTweaksMod: "玩法调整",
+		PatchMod:          "合并补丁",
+		UnknownMod:        "未知",
+	}
+)
+
+// KindCandidate
+// a kind of module, Confidence is in [0, 1], module may be of many kinds whose Label is true.
+type KindCandidate struct {
+	Kind       string   `json:"kind"`
+	Confidence float64  `json:"confidence"`
+	Label      bool     `json:"label"`
+	Reasons    []string `json:"reasons"`
+}
+
+// ClassifyModuleFiles ranks kinds of module by its files and tags of project.xml,
+// filenames are slash-relative to module root, the first candidate is the most confident one.
+// the share of files of each kind is its confidence, tags raise confidence of kinds told by them,
+// and a module of many kinds of content is an overhaul.
+func ClassifyModuleFiles(filenames []string, tags []string) (candidates []KindCandidate) {
+	type kindFiles struct {
+		count int
+		dirs  map[string]int
+	}
+	counts := make(map[string]*kindFiles)
+	voted := 0
+	supports := make(map[string]int)
+	for _, filename := range filenames {
+		filename = path.Clean(strings.ReplaceAll(filename, "\\", "/"))
+		items := strings.Split(filename, "/")
+		if len(items) < 2 {
+			// project.xml, preview icon and others in root are not content
+			continue
+		}
+		top := strings.ToLower(items[0])
+		if slices.Contains(supportDirs, top) {
+			supports[top]++
+			continue
+		}
+		kind := GetKindOfFilename(filename)
+		if kind == UnknownMod {
+			continue
+		}
+		voted++
+		item, has := counts[kind]
+		if !has {
+			item = &kindFiles{dirs: make(map[string]int)}
+			counts[kind] = item
+		}
+		item.count++
+		dir := items[0]
+		if len(items) > 2 {
+			dir = path.Join(items[0], items[1])
+		}
+		item.dirs[dir]++
+	}
+
+	byKind := make(map[string]*KindCandidate)
+	candidate := func(kind string) *KindCandidate {
+		c, has := byKind[kind]
+		if !has {
+			c = &KindCandidate{Kind: kind}
+			byKind[kind] = c
+		}
+		return c
+	}
+	for kind, item := range counts {
+		c := candidate(kind)
+		c.Confidence = float64(item.count) / float64(voted)
+		dirs := make([]string, 0, len(item.dirs))
+		for dir := range item.dirs {
+			dirs = append(dirs, dir)
+		}
+		slices.SortFunc(dirs, func(a, b string) int {
+			if n := item.dirs[b] - item.dirs[a]; n != 0 {
+				return n
+			}
+			return strings.Compare(a, b)
+		})
+		for _, dir := range dirs[:min(3, len(dirs))] {
+			c.Reasons = append(c.Reasons, fmt.Sprintf("%d 个%s文件位于 %s", item.dirs[dir], kindNames[kind], dir))
+		}
+		if len(dirs) > 3 {
+			c.Reasons = append(c.Reasons, fmt.Sprintf("另有 %d 个目录", len(dirs)-3))
+		}
+	}
+
+	// overhauls
+	major := make([]string, 0, len(overhaulKinds))
+	for _, kind := range overhaulKinds {
+		if item, has := counts[kind]; has && float64(item.count)/float64(voted) >= 0.1 {
+			major = append(major, kindNames[kind])
+		}
+	}
+	if len(major) >= 3 {
+		c := candidate(OverhaulsMod)
+		c.Confidence = math.Min(0.9, 0.4+0.1*float64(len(major)-3)+math.Min(0.2, float64(voted)/1000))
+		c.Reasons = append(c.Reasons, fmt.Sprintf("同时包含%s", strings.Join(major, "、")))
+	}
+
+	// tags
+	for _, tag := range tags {
+		kind, has := tagKinds[strings.ToLower(strings.TrimSpace(tag))]
+		if !has {
+			continue
+		}
+		c := candidate(kind)
+		// noisy-or of files and tags
+		c.Confidence = 1 - (1-c.Confidence)*(1-kindTagConfidence)
+		c.Reasons = append(c.Reasons, fmt.Sprintf("project.xml 标签为 %s", strings.TrimSpace(tag)))
+	}
+
+	for _, c := range byKind {
+		if c.Confidence < kindMinConfidence {
+			continue
+		}
+		c.Confidence = math.Round(c.Confidence*100) / 100
+		c.Label = c.Confidence >= kindLabelConfidence
+		candidates = append(candidates, *c)
+	}
+	slices.SortFunc(candidates, func(a, b KindCandidate) int {
+		if a.Confidence != b.Confidence {
+			if a.Confidence > b.Confidence {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Kind, b.Kind)
+	})
+	if len(candidates) == 0 {
+		candidates = append(candidates, KindCandidate{
+			Kind:    UnknownMod,
+			Label:   true,
+			Reasons: []string{"没有可识别的游戏文件"},
+		})
+	}
+	// the first one is always a label
+	candidates[0].Label = true
+	for _, dir := range supportDirs {
+		if n := supports[dir]; n > 0 {
+			candidates[0].Reasons = append(candidates[0].Reasons, fmt.Sprintf("另有 %d 个文件位于 %s", n, dir))
+		}
+	}
+	return
+}
+
+// ClassifyModule classifies files of the latest version of module again, and saves the result,
+// kind of module is replaced when it is unknown.
+func (bx *Box) ClassifyModule(id string) (module *Module, err error) {
+	if module, err = bx.GetModule(id); err != nil {
+		return
+	}
+	filenames, filenamesErr := moduleVersionFilenames(bx.moduleFS, module, module.Version)
+	if filenamesErr != nil {
+		err = failure.Failed("模组", fmt.Sprintf("读取 %s 失败", module.Title)).Wrap(filenamesErr)
+		return
+	}
+	module.Kinds = ClassifyModuleFiles(filenames, module.Tags)
+	if module.Kind == "" || module.Kind == UnknownMod {
+		module.Kind = module.Kinds[0].Kind
+	}
+	err = bx.SaveModule(module)
+	return
+}
+
+// ClassifyModuleByFileStructure is ClassifyModuleFiles of files in st.
+func ClassifyModuleByFileStructure(st files.Structure, tags []string) []KindCandidate {
+	filenames := make([]string, 0, 1)
+	var walk func(parent string, children []files.Structure)
+	walk = func(parent string, children []files.Structure) {
+		for _, child := range children {
+			name := path.Join(parent, strings.ReplaceAll(child.Name, "\\", "/"))
+			if child.IsDir {
+				walk(name, child.Children)
+				continue
+			}
+			filenames = append(filenames, name)
+		}
+	}
+	walk("", st.Children)
+	return ClassifyModuleFiles(filenames, tags)
+}
+
+// ModuleLabels returns kinds of candidates which are labels.
+func ModuleLabels(candidates []KindCandidate) (labels []string) {
+	for _, c := range candidates {
+		if c.Label {
+			labels = append(labels, c.Kind)
+		}
+	}
+	return
+}
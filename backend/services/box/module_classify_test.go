@@ -0,0 +1,145 @@
+package box_test
+
+import (
+	"slices"
+	"testing"
+
+	"DarkestDungeonModBoxLite/backend/services/box"
+)
+
+func TestGetKindOfFilename(t *testing.T) {
+	cases := []struct {
+		filename string
+		expect   string
+	}{
+		{"project.xml", box.UnknownMod},
+		{"heroes/crusader", box.UnknownMod},
+		{"heroes/paladin/paladin.info.darkest", box.HeroNewMod},
+		{"heroes/crusader/crusader.info.darkest", box.HeroTweaksMod},
+		{"heroes\\crusader\\crusader.info.darkest", box.HeroTweaksMod},
+		{"heroes/crusader/crusader_A/anim/crusader_A.sprite.idle.png", box.HeroSkinsMod},
+		{"trinkets/base.entries.trinkets.json", box.TrinketsMod},
+		{"panels/icons_equip/trinket/inv_trinket+sun_ring.png", box.TrinketsMod},
+		{"panels/icons_equip/heroes/crusader.png", box.UIMod},
+		{"monsters/cultist_brawler/cultist_brawler.info.darkest", box.MonstersMod},
+		{"localization/mod.string_table.xml", box.LocalizationMod},
+		{"fonts/font.png", box.UIMod},
+		{"curios/curio_type_library.json", box.GameplayTweaksMod},
+		{"campaign/estate/estate.json", box.GameplayTweaksMod},
+		{"campaign/town/town.png", box.UIMod},
+		{"raid/ai/ai.json", box.GameplayTweaksMod},
+		{"raid/raid.json", box.GameplayTweaksMod},
+		{"raid/torch.png", box.UIMod},
+		{"raid_results/raid_results.layout.darkest", box.GameplayTweaksMod},
+		{"shared/buffs/base.buffs.json", box.GameplayTweaksMod},
+		{"shared/icons/icon.png", box.UIMod},
+		{"mymod/project.xml", box.UnknownMod},
+	}
+	for _, c := range cases {
+		if kind := box.GetKindOfFilename(c.filename); kind != c.expect {
+			t.Error(c.filename, "expect", c.expect, "got", kind)
+		}
+	}
+}
+
+func TestClassifyModuleFiles(t *testing.T) {
+	cases := []struct {
+		name      string
+		filenames []string
+		tags      []string
+		expect    []string // kinds of candidates in order
+		labels    []string
+	}{
+		{
+			name:   "empty",
+			expect: []string{box.UnknownMod},
+			labels: []string{box.UnknownMod},
+		},
+		{
+			name:      "root files only",
+			filenames: []string{"preview_icon.png", "project.xml"},
+			expect:    []string{box.UnknownMod},
+			labels:    []string{box.UnknownMod},
+		},
+		{
+			name:      "audio only",
+			filenames: []string{"audio/mod.bank", "audio/mod.guids.txt", "project.xml"},
+			expect:    []string{box.UnknownMod},
+			labels:    []string{box.UnknownMod},
+		},
+		{
+			name: "new hero with audio",
+			filenames: []string{
+				"audio/paladin.bank",
+				"heroes/paladin/anim/paladin.sprite.idle.png",
+				"heroes/paladin/paladin.info.darkest",
+				"project.xml",
+			},
+			expect: []string{box.HeroNewMod},
+			labels: []string{box.HeroNewMod},
+		},
+		{
+			name: "mixed",
+			filenames: []string{
+				"localization/mod.string_table.xml",
+				"trinkets/base.entries.trinkets.json",
+				"trinkets/mod.entries.trinkets.json",
+				"trinkets/mod.rarities.trinkets.json",
+			},
+			expect: []string{box.TrinketsMod, box.LocalizationMod},
+			labels: []string{box.TrinketsMod, box.LocalizationMod},
+		},
+		{
+			name: "minor kind is not a label",
+			filenames: []string{
+				"fonts/font.png",
+				"trinkets/1.json", "trinkets/2.json", "trinkets/3.json", "trinkets/4.json", "trinkets/5.json",
+				"trinkets/6.json", "trinkets/7.json", "trinkets/8.json", "trinkets/9.json",
+			},
+			expect: []string{box.TrinketsMod, box.UIMod},
+			labels: []string{box.TrinketsMod},
+		},
+		{
+			name: "tag raises minor kind",
+			filenames: []string{
+				"fonts/font.png",
+				"trinkets/1.json", "trinkets/2.json", "trinkets/3.json", "trinkets/4.json", "trinkets/5.json",
+				"trinkets/6.json", "trinkets/7.json", "trinkets/8.json", "trinkets/9.json",
+			},
+			tags:   []string{" UI "},
+			expect: []string{box.TrinketsMod, box.UIMod},
+			labels: []string{box.TrinketsMod, box.UIMod},
+		},
+		{
+			name:   "tag without files",
+			tags:   []string{"Trinkets", "unknown tag"},
+			expect: []string{box.TrinketsMod},
+			labels: []string{box.TrinketsMod},
+		},
+		{
+			name: "overhaul",
+			filenames: []string{
+				"curios/curio_type_library.json",
+				"heroes/paladin/paladin.info.darkest",
+				"monsters/brute/brute.info.darkest",
+				"trinkets/base.entries.trinkets.json",
+			},
+			expect: []string{box.OverhaulsMod, box.GameplayTweaksMod, box.HeroNewMod, box.MonstersMod, box.TrinketsMod},
+			labels: []string{box.OverhaulsMod, box.GameplayTweaksMod, box.HeroNewMod, box.MonstersMod, box.TrinketsMod},
+		},
+	}
+	for _, c := range cases {
+		candidates := box.ClassifyModuleFiles(c.filenames, c.tags)
+		t.Log(c.name, candidates)
+		kinds := make([]string, 0, len(candidates))
+		for _, candidate := range candidates {
+			kinds = append(kinds, candidate.Kind)
+		}
+		if !slices.Equal(kinds, c.expect) {
+			t.Error(c.name, "expect", c.expect, "got", kinds)
+		}
+		if labels := box.ModuleLabels(candidates); !slices.Equal(labels, c.labels) {
+			t.Error(c.name, "expect labels", c.labels, "got", labels)
+		}
+	}
+}
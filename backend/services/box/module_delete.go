@@ -0,0 +1,195 @@
+package box
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"DarkestDungeonModBoxLite/backend/pkg/databases"
+	"DarkestDungeonModBoxLite/backend/pkg/failure"
+)
+
+// DeleteModule removes module with all versions.
+// when schemas still use it, it is refused, unless cascade, then it is removed from these schemas.
+func (bx *Box) DeleteModule(id string, cascade bool) (err error) {
+	var (
+		db     *databases.Database
+		module *Module
+	)
+	if db, err = bx.database(); err != nil {
+		return
+	}
+	if module, err = bx.GetModule(id); err != nil {
+		return
+	}
+	references, referencesErr := listModuleReferences(db, module, nil)
+	if referencesErr != nil {
+		err = failure.Failed("模组", fmt.Sprintf("删除 %s 失败", module.Title)).Wrap(referencesErr)
+		return
+	}
+	if len(references.refs) > 0 && !cascade {
+		err = bx.moduleReferencedFailure(module, references.refs)
+		return
+	}
+	err = db.Transaction(func(tx *databases.Tx) (err error) {
+		if err = references.remove(tx); err != nil {
+			return
+		}
+		err = tx.Remove(moduleKey(id))
+		return
+	})
+	if err != nil {
+		err = failure.Failed("模组", fmt.Sprintf("删除 %s 失败", module.Title)).Wrap(err)
+		return
+	}
+	dir := filepath.Join(bx.moduleFS.Path(), id)
+	bx.purgeImages(db, dir)
+	if rmErr := os.RemoveAll(dir); rmErr != nil {
+		err = failure.Failed("模组", fmt.Sprintf("无法删除 %s", dir)).Wrap(rmErr)
+		return
+	}
+	return
+}
+
+// DeleteModuleVersion removes one version of module, the module is removed when it is the last version.
+// when schemas still pin the version, it is refused, unless cascade, then module is unpinned in these schemas.
+func (bx *Box) DeleteModuleVersion(id string, version Version, cascade bool) (err error) {
+	var (
+		db     *databases.Database
+		module *Module
+	)
+	if db, err = bx.database(); err != nil {
+		return
+	}
+	if module, err = bx.GetModule(id); err != nil {
+		return
+	}
+	if _, ok := module.ExistVersion(version); !ok {
+		err = failure.Failed("模组", fmt.Sprintf("%s 不存在版本 %s", module.Title, version.String()))
+		return
+	}
+	if len(module.Versions) == 1 {
+		err = bx.DeleteModule(id, cascade)
+		return
+	}
+	references, referencesErr := listModuleReferences(db, module, &version)
+	if referencesErr != nil {
+		err = failure.Failed("模组", fmt.Sprintf("删除 %s 失败", module.Title)).Wrap(referencesErr)
+		return
+	}
+	if len(references.refs) > 0 && !cascade {
+		err = bx.moduleReferencedFailure(module, references.refs)
+		return
+	}
+	module.Remove(version)
+	err = db.Transaction(func(tx *databases.Tx) (err error) {
+		if err = references.remove(tx); err != nil {
+			return
+		}
+		err = tx.Update(moduleKey(id), module)
+		return
+	})
+	if err != nil {
+		err = failure.Failed("模组", fmt.Sprintf("删除 %s 失败", module.Title)).Wrap(err)
+		return
+	}
+	dir := filepath.Join(bx.moduleFS.Path(), id, version.String())
+	bx.purgeImages(db, dir)
+	if rmErr := os.RemoveAll(dir); rmErr != nil {
+		err = failure.Failed("模组", fmt.Sprintf("无法删除 %s", dir)).Wrap(rmErr)
+		return
+	}
+	return
+}
+
+// moduleReferences
+// schema modules which pin version of module, or use any version of module when version is nil.
+// unpinned ones do not reference a version, since they fall back to the new latest version when it is removed.
+// refs and the remaining modules of their schemas, remains are computed before transaction, since reading blocks in it.
+// remains are only listed for the whole module, refs of a version keep their places.
+type moduleReferences struct {
+	version *Version
+	refs    []SchemaModule
+	remains [][]SchemaModule
+}
+
+func listModuleReferences(db *databases.Database, module *Module, version *Version) (references moduleReferences, err error) {
+	references.version = version
+	modules := make([]SchemaModule, 0, 1)
+	if err = db.AscendKeys(fmt.Sprintf("plan:*:mod:%s", module.Id), &modules, nil); err != nil {
+		return
+	}
+	for _, schemaModule := range modules {
+		if schemaModule.ModId != module.Id {
+			continue
+		}
+		if version != nil && (schemaModule.Version == nil || schemaModule.Version.Compare(*version) != 0) {
+			continue
+		}
+		references.refs = append(references.refs, schemaModule)
+		if version != nil {
+			continue
+		}
+		schemaModules, listErr := listSchemaModules(db, schemaModule.PlanId)
+		if listErr != nil {
+			err = listErr
+			return
+		}
+		remains := make([]SchemaModule, 0, len(schemaModules))
+		for _, item := range schemaModules {
+			if item.ModId != module.Id {
+				remains = append(remains, item)
+			}
+		}
+		references.remains = append(references.remains, remains)
+	}
+	return
+}
+
+// remove unpins refs of the version, or removes refs of the module from their schemas, and reorders the remains.
+func (references *moduleReferences) remove(tx *databases.Tx) (err error) {
+	for i, ref := range references.refs {
+		if references.version != nil {
+			ref.Version = nil
+			if err = tx.Update(ref.Key(), ref); err != nil {
+				return
+			}
+			continue
+		}
+		if err = tx.Remove(ref.Key()); err != nil {
+			return
+		}
+		if err = updateSchemaModuleIndexes(tx, references.remains[i]); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (bx *Box) moduleReferencedFailure(module *Module, refs []SchemaModule) error {
+	names := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		name := ref.PlanId
+		if schema, schemaErr := bx.GetSchema(ref.PlanId); schemaErr == nil {
+			name = schema.Name
+		}
+		names = append(names, name)
+	}
+	return failure.Failed("模组", fmt.Sprintf("%s 仍被方案使用", module.Title)).Append("方案", strings.Join(names, ", "))
+}
+
+// purgeImages removes cached images of files in dir.
+func (bx *Box) purgeImages(db *databases.Database, dir string) {
+	_ = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, walkErr error) error {
+		if walkErr != nil || entry.IsDir() {
+			return nil
+		}
+		_ = db.RemoveImage(path)
+		if rel, relErr := filepath.Rel(bx.moduleFS.Path(), path); relErr == nil {
+			_ = db.RemoveImage(rel)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,93 @@
+package box
+
+import (
+	"testing"
+)
+
+func TestBox_DeleteModuleVersion(t *testing.T) {
+	bx := newTestBox(t)
+	module := &Module{Id: "mod", Title: "mod"}
+	for _, v := range []Version{{Major: 1}, {Major: 2}, {Major: 3}} {
+		module.Add(VersionedModule{Version: v})
+	}
+	if err := bx.db.Update(moduleKey(module.Id), module); err != nil {
+		t.Fatal(err)
+	}
+	unpinned, unpinnedErr := bx.CreateSchema("unpinned")
+	if unpinnedErr != nil {
+		t.Fatal(unpinnedErr)
+	}
+	pinned, pinnedErr := bx.CreateSchema("pinned")
+	if pinnedErr != nil {
+		t.Fatal(pinnedErr)
+	}
+	for _, id := range []string{unpinned.Id, pinned.Id} {
+		if err := bx.AddSchemaModule(id, module.Id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := bx.PinSchemaModule(pinned.Id, module.Id, &Version{Major: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the latest version is not referenced by the unpinned one
+	if err := bx.DeleteModuleVersion(module.Id, Version{Major: 3}, false); err != nil {
+		t.Fatal("delete latest", err)
+	}
+	// the pinned version is refused
+	if err := bx.DeleteModuleVersion(module.Id, Version{Major: 2}, false); err == nil {
+		t.Error("pinned version is deleted")
+	}
+	// cascade unpins it in the pinned schema, which keeps the module
+	if err := bx.DeleteModuleVersion(module.Id, Version{Major: 2}, true); err != nil {
+		t.Fatal("cascade", err)
+	}
+	for _, id := range []string{unpinned.Id, pinned.Id} {
+		modules, listErr := bx.ListSchemaModules(id)
+		if listErr != nil {
+			t.Fatal(listErr)
+		}
+		if len(modules) != 1 || modules[0].ModId != module.Id || modules[0].Version != nil {
+			t.Error(id, "has modules", modules)
+		}
+	}
+	latest, getErr := bx.GetModule(module.Id)
+	if getErr != nil {
+		t.Fatal(getErr)
+	}
+	if latest.Version.Compare(Version{Major: 1}) != 0 || len(latest.Versions) != 1 {
+		t.Error("module is", latest.Version, latest.Versions)
+	}
+}
+
+func TestBox_DeleteModule_Cascade(t *testing.T) {
+	bx := newTestBox(t)
+	saveTestModule(t, bx, "a", map[string]string{"a.txt": "a"})
+	saveTestModule(t, bx, "b", map[string]string{"b.txt": "b"})
+	schema, schemaErr := bx.CreateSchema("cascade")
+	if schemaErr != nil {
+		t.Fatal(schemaErr)
+	}
+	for _, id := range []string{"a", "b"} {
+		if err := bx.AddSchemaModule(schema.Id, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := bx.DeleteModule("a", false); err == nil {
+		t.Fatal("referenced module is deleted")
+	}
+	// the whole module is removed from schema, and the remains are reordered
+	if err := bx.DeleteModule("a", true); err != nil {
+		t.Fatal("cascade", err)
+	}
+	modules, listErr := bx.ListSchemaModules(schema.Id)
+	if listErr != nil {
+		t.Fatal(listErr)
+	}
+	if len(modules) != 1 || modules[0].ModId != "b" || modules[0].Index != 0 {
+		t.Error("schema modules are", modules)
+	}
+	if exists, _ := bx.ExistsModule("a"); exists {
+		t.Error("a is not deleted")
+	}
+}
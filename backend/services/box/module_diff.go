@@ -0,0 +1,159 @@
+package box
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"DarkestDungeonModBoxLite/backend/pkg/diffs"
+	"DarkestDungeonModBoxLite/backend/pkg/failure"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+const (
+	FileAdded    = "added"
+	FileRemoved  = "removed"
+	FileModified = "modified"
+
+	// diffContextLines lines around changes in unified diff
+	diffContextLines = 3
+)
+
+var (
+	diffTextExts = []string{".darkest", ".json", ".xml"}
+)
+
+type ModuleFileDiff struct {
+	Filename string `json:"filename"`
+	Status   string `json:"status"`
+	Unified  string `json:"unified"`
+}
+
+type ModuleVersionsDiff struct {
+	ModId string           `json:"modId"`
+	From  Version          `json:"from"`
+	To    Version          `json:"to"`
+	Files []ModuleFileDiff `json:"files"`
+}
+
+// DiffModuleVersions compares files of version a and b by content hash,
+// text files (.darkest, .json, .xml) which are changed have unified diffs from a to b.
+func (bx *Box) DiffModuleVersions(id string, a Version, b Version) (diff *ModuleVersionsDiff, err error) {
+	var (
+		module *Module
+	)
+	if module, err = bx.GetModule(id); err != nil {
+		return
+	}
+	for _, version := range []Version{a, b} {
+		if _, ok := module.ExistVersion(version); !ok {
+			err = failure.Failed("模组", fmt.Sprintf("%s 不存在版本 %s", module.Title, version.String()))
+			return
+		}
+	}
+	aDir := filepath.Join(bx.moduleFS.Path(), id, a.String())
+	bDir := filepath.Join(bx.moduleFS.Path(), id, b.String())
+	aHashes, aErr := hashDirFiles(aDir)
+	if aErr != nil {
+		err = failure.Failed("模组", fmt.Sprintf("读取 %s 失败", aDir)).Wrap(aErr)
+		return
+	}
+	bHashes, bErr := hashDirFiles(bDir)
+	if bErr != nil {
+		err = failure.Failed("模组", fmt.Sprintf("读取 %s 失败", bDir)).Wrap(bErr)
+		return
+	}
+
+	diff = &ModuleVersionsDiff{
+		ModId: id,
+		From:  a,
+		To:    b,
+	}
+	for name, aHash := range aHashes {
+		bHash, has := bHashes[name]
+		switch {
+		case !has:
+			diff.Files = append(diff.Files, ModuleFileDiff{Filename: name, Status: FileRemoved})
+		case aHash != bHash:
+			diff.Files = append(diff.Files, ModuleFileDiff{Filename: name, Status: FileModified})
+		}
+	}
+	for name := range bHashes {
+		if _, has := aHashes[name]; !has {
+			diff.Files = append(diff.Files, ModuleFileDiff{Filename: name, Status: FileAdded})
+		}
+	}
+	slices.SortFunc(diff.Files, func(x, y ModuleFileDiff) int {
+		return strings.Compare(x.Filename, y.Filename)
+	})
+	for i, file := range diff.Files {
+		if !slices.Contains(diffTextExts, strings.ToLower(path.Ext(file.Filename))) {
+			continue
+		}
+		var aText, bText string
+		if file.Status != FileAdded {
+			if aText, err = readText(aDir, file.Filename); err != nil {
+				return
+			}
+		}
+		if file.Status != FileRemoved {
+			if bText, err = readText(bDir, file.Filename); err != nil {
+				return
+			}
+		}
+		diff.Files[i].Unified = diffs.Unified(
+			path.Join(a.String(), file.Filename), path.Join(b.String(), file.Filename),
+			aText, bText, diffContextLines,
+		)
+	}
+	return
+}
+
+// hashDirFiles returns xxhash of files in dir, keys are slash-relative names.
+func hashDirFiles(dir string) (hashes map[string]uint64, err error) {
+	hashes = make(map[string]uint64)
+	err = filepath.WalkDir(dir, func(name string, entry fs.DirEntry, walkErr error) (err error) {
+		if walkErr != nil {
+			err = walkErr
+			return
+		}
+		if entry.IsDir() {
+			return
+		}
+		rel, relErr := filepath.Rel(dir, name)
+		if relErr != nil {
+			err = relErr
+			return
+		}
+		file, openErr := os.Open(name)
+		if openErr != nil {
+			err = openErr
+			return
+		}
+		h := xxhash.New()
+		_, err = io.Copy(h, file)
+		_ = file.Close()
+		if err != nil {
+			return
+		}
+		hashes[filepath.ToSlash(rel)] = h.Sum64()
+		return
+	})
+	return
+}
+
+func readText(dir string, name string) (text string, err error) {
+	p, readErr := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+	if readErr != nil {
+		err = failure.Failed("模组", fmt.Sprintf("读取 %s 失败", name)).Wrap(readErr)
+		return
+	}
+	text = string(p)
+	return
+}
@@ -0,0 +1,118 @@
+package box
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+
+	"DarkestDungeonModBoxLite/backend/pkg/databases"
+	"DarkestDungeonModBoxLite/backend/pkg/failure"
+)
+
+const (
+	generatedPreviewIconFile = "preview_icon.png"
+)
+
+type generatedProject struct {
+	XMLName xml.Name `xml:"project"`
+	ModuleProject
+}
+
+// generateModule saves files as the next version of module, which is generated by box, such as patches.
+// module is created when it is not existed, files are slash-relative names to contents.
+func (bx *Box) generateModule(id string, kind string, title string, description string, contents map[string][]byte) (module *Module, err error) {
+	var (
+		db *databases.Database
+	)
+	if db, err = bx.database(); err != nil {
+		return
+	}
+	dst := &Module{
+		Id:    id,
+		Kind:  kind,
+		Title: title,
+	}
+	if exists, _ := bx.ExistsModule(id); exists {
+		if dst, err = bx.GetModule(id); err != nil {
+			return
+		}
+		dst.Title = title
+	}
+	version := Version{Major: 1}
+	if len(dst.Versions) > 0 {
+		version = dst.Version
+		version.Patch++
+	}
+	plan := &ModulePlan{
+		Id:      id,
+		Existed: true,
+		Kind:    kind,
+		Version: version,
+		Title:   title,
+		Dst:     dst,
+	}
+	project := ModuleProject{
+		PreviewIconFile:      generatedPreviewIconFile,
+		ItemDescriptionShort: description,
+		ItemDescription:      description,
+		Title:                title,
+		VersionMajor:         fmt.Sprintf("%d", version.Major),
+		VersionMinor:         fmt.Sprintf("%d", version.Minor),
+		TargetBuild:          fmt.Sprintf("%d", version.Patch),
+	}
+	projectBytes, encodeErr := xml.MarshalIndent(generatedProject{ModuleProject: project}, "", "\t")
+	if encodeErr != nil {
+		err = failure.Failed("模组", fmt.Sprintf("生成 %s 失败", title)).Wrap(encodeErr)
+		return
+	}
+
+	stage := newImportStage(bx.moduleFS)
+	entry, entryErr := stage.add(plan)
+	if entryErr != nil {
+		err = failure.Failed("模组", fmt.Sprintf("生成 %s 失败", title)).Wrap(entryErr)
+		return
+	}
+	entry.project = project
+	writes := map[string][]byte{
+		"project.xml":            append([]byte(xml.Header), projectBytes...),
+		generatedPreviewIconFile: generatedPreviewIcon(kind),
+	}
+	for name, content := range contents {
+		writes[name] = content
+	}
+	for name, content := range writes {
+		if cpErr := entry.tmp.CopyFile(name, bytes.NewReader(content)); cpErr != nil {
+			stage.Rollback()
+			err = failure.Failed("模组", fmt.Sprintf("生成 %s 失败", title)).Wrap(cpErr)
+			return
+		}
+	}
+	modules, commitErr := stage.Commit(db)
+	if commitErr != nil {
+		stage.Rollback()
+		err = commitErr
+		return
+	}
+	module = modules[0]
+	return
+}
+
+// generatedPreviewIcon draws a plain icon, generated modules have no icons of their own.
+func generatedPreviewIcon(kind string) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	c := color.RGBA{R: 0x5a, G: 0x4a, B: 0x3a, A: 0xff}
+	if kind == PatchMod {
+		c = color.RGBA{R: 0x8b, G: 0x1e, B: 0x1e, A: 0xff}
+	}
+	for x := 0; x < 64; x++ {
+		for y := 0; y < 64; y++ {
+			img.Set(x, y, c)
+		}
+	}
+	buf := bytes.NewBuffer(nil)
+	_ = png.Encode(buf, img)
+	return buf.Bytes()
+}
@@ -1,14 +1,21 @@
 package box
 
 import (
+	"bytes"
+	"context"
 	"encoding/xml"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
+	"DarkestDungeonModBoxLite/backend/pkg/archives"
 	"DarkestDungeonModBoxLite/backend/pkg/failure"
 	"DarkestDungeonModBoxLite/backend/pkg/files"
+	"DarkestDungeonModBoxLite/backend/pkg/tasks"
 )
 
 func (bx *Box) ImportModules(plan *ImportPlan) (modules []*Module, err error) {
@@ -16,31 +23,211 @@ func (bx *Box) ImportModules(plan *ImportPlan) (modules []*Module, err error) {
 		err = failure.Failed("导入模组失败", "无效导入计划")
 		return
 	}
-	if plan.IsDir {
-		modules, err = ImportModulesByDir(bx.moduleFS, plan)
-	} else {
-		modules, err = ImportModulesByArchiveFile(bx.moduleFS, plan)
-	}
-	if err != nil {
-		DropImportPlan(bx.moduleFS, plan)
+	db, dbErr := bx.database()
+	if dbErr != nil {
+		err = dbErr
+		return
 	}
+	_, err = bx.processes.Run(bx.ctx, importTaskName, tasks.TaskFunc(func(ctx context.Context, progress *tasks.Progress) (err error) {
+		var stage *ImportStage
+		if plan.IsDir {
+			stage, err = StageModulesByDir(ctx, bx.moduleFS, plan, progress)
+		} else {
+			stage, err = StageModulesByArchiveFile(ctx, bx.moduleFS, plan, progress)
+		}
+		if err != nil {
+			DropImportPlan(bx.moduleFS, plan)
+			return
+		}
+		if modules, err = stage.Commit(db); err != nil {
+			stage.Rollback()
+			return
+		}
+		if plan.IsDir {
+			err = bx.markWorkshopUpdateAT(db, plan.Source, modules)
+		}
+		return
+	}))
 	return
 }
 
-func ImportModulesByArchiveFile(root *files.DirFS, plan *ImportPlan) (modules []*Module, err error) {
-	// todo use source not module.Filename
+type archiveImportTarget struct {
+	entry     *importStageEntry
+	filenames map[string]struct{}
+	project   *bytes.Buffer
+}
+
+func (target *archiveImportTarget) relative(filename string) (name string, ok bool) {
+	if _, ok = target.filenames[filename]; !ok {
+		return
+	}
+	name, ok = strings.CutPrefix(filename, target.entry.plan.Filename+"/")
 	return
 }
 
-func ImportModulesByDir(root *files.DirFS, plan *ImportPlan) (modules []*Module, err error) {
+func (target *archiveImportTarget) contains(archived string) bool {
+	prefix := archived + "/"
+	for filename := range target.filenames {
+		if strings.HasPrefix(filename, prefix) {
+			return true
+		}
+	}
+	return false
+}
 
-	temps := make([]string, 0, 1)
-	for _, modulePlan := range plan.Modules {
+func StageModulesByArchiveFile(ctx context.Context, root *files.DirFS, plan *ImportPlan, progress *tasks.Progress) (stage *ImportStage, err error) {
+	src, srcErr := os.Open(plan.Source)
+	if srcErr != nil {
+		err = failure.Failed("导入压缩包失败", fmt.Sprintf("无法打开 %s", plan.Source))
+		return
+	}
+	defer src.Close()
+	file, fileErr := archives.New(plan.Source, src)
+	if fileErr != nil {
+		err = failure.Failed("导入压缩包失败", fmt.Sprintf("无法解压 %s", plan.Source))
+		return
+	}
+	if plan.Archived != nil {
+		if plan.Archived.Password.Password != "" {
+			file.SetPassword(plan.Archived.Password.Password)
+		}
+		for _, child := range plan.Archived.Password.Children {
+			if child.Path != "" && child.Password != "" {
+				file.SetEntryPassword(child.Path, child.Password)
+			}
+		}
+	}
+
+	stage = newImportStage(root)
+	defer func() {
+		if err != nil {
+			stage.Rollback()
+			stage = nil
+		}
+	}()
+
+	// targets
+	targets := make([]*archiveImportTarget, 0, len(plan.Modules))
+	for i := range plan.Modules {
+		modulePlan := &plan.Modules[i]
+		srcFilenames := modulePlan.FlatEntryFilenames()
+		if len(srcFilenames) == 0 {
+			continue
+		}
+		entry, entryErr := stage.add(modulePlan)
+		if entryErr != nil {
+			err = failure.Failed("导入压缩包失败", fmt.Sprintf("无法创建 %s 的临时目录", modulePlan.Filename)).Wrap(entryErr)
+			return
+		}
+		target := &archiveImportTarget{
+			entry:     entry,
+			filenames: make(map[string]struct{}, len(srcFilenames)),
+			project:   bytes.NewBuffer(nil),
+		}
+		for _, srcFilename := range srcFilenames {
+			target.filenames[srcFilename] = struct{}{}
+		}
+		targets = append(targets, target)
+		progress.Total(modulePlan.countChosenFiles(), 0)
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	// extract
+	progress.Step(fmt.Sprintf("解压 %s", filepath.Base(plan.Source)))
+	var (
+		handleErr error
+		copied    []string
+	)
+	// an encrypted archive is extracted again with its password, copied files of it are overridden and counted again
+	restart := func(path string) {
+		n := len(copied)
+		copied = slices.DeleteFunc(copied, func(filename string) bool {
+			return strings.HasPrefix(filename, path+"/")
+		})
+		progress.Total(n-len(copied), 0)
+	}
+	extractErr := file.Extract(ctx, restart, func(ctx context.Context, entry *archives.Entry) (err error) {
+		filename := filepath.ToSlash(entry.Name())
+		if entry.Info().IsDir() {
+			return
+		}
+		if archived, _, passwordInvalid, _ := entry.Archived(); archived {
+			for _, target := range targets {
+				if !target.contains(filename) {
+					continue
+				}
+				if passwordInvalid {
+					handleErr = failure.Failed("导入压缩包失败", fmt.Sprintf("%s 密码错误", filename))
+					err = handleErr
+					return
+				}
+				file.ExtractedEntry(filename)
+				return
+			}
+			err = archives.ErrSkip
+			return
+		}
+		for _, target := range targets {
+			name, ok := target.relative(filename)
+			if !ok {
+				continue
+			}
+			progress.File(filename)
+			var reader = progress.Reader(&contextReader{ctx: ctx, reader: entry})
+			if name == "project.xml" {
+				target.project.Reset()
+				reader = io.TeeReader(reader, target.project)
+			}
+			if cpErr := target.entry.tmp.CopyFile(name, reader); cpErr != nil {
+				handleErr = failure.Failed("导入压缩包失败", fmt.Sprintf("无法复制 %s", filename)).Wrap(cpErr)
+				err = handleErr
+				return
+			}
+			progress.FileDone()
+			copied = append(copied, filename)
+			return
+		}
+		return
+	})
+	if handleErr != nil {
+		err = handleErr
+		return
+	}
+	if extractErr != nil {
+		err = failure.Failed("导入压缩包失败", fmt.Sprintf("解压 %s 失败", plan.Source)).Wrap(extractErr)
+		return
+	}
+
+	// project
+	for _, target := range targets {
+		if target.project.Len() == 0 {
+			continue
+		}
+		if decodeErr := xml.Unmarshal(target.project.Bytes(), &target.entry.project); decodeErr != nil {
+			err = failure.Failed("导入压缩包失败", fmt.Sprintf("无法解析 %s", target.entry.plan.Filename+"/project.xml"))
+			return
+		}
+	}
+	return
+}
+
+func StageModulesByDir(ctx context.Context, root *files.DirFS, plan *ImportPlan, progress *tasks.Progress) (stage *ImportStage, err error) {
+	stage = newImportStage(root)
+	defer func() {
+		if err != nil {
+			stage.Rollback()
+			stage = nil
+		}
+	}()
+	for i := range plan.Modules {
+		modulePlan := &plan.Modules[i]
 		// src
 		src, srcErr := files.NewDirFS(modulePlan.Filename)
 		if srcErr != nil {
 			err = failure.Failed("导入文件夹失败", fmt.Sprintf("无法打开 %s", modulePlan.Filename))
-			break
+			return
 		}
 		srcFilenames := modulePlan.FlatEntryFilenames()
 		if len(srcFilenames) == 0 {
@@ -48,98 +235,73 @@ func ImportModulesByDir(root *files.DirFS, plan *ImportPlan) (modules []*Module,
 		}
 
 		// dst
-		dstDirPath, tmpDirPath, override := getImportDst(&modulePlan)
-		tmpDST := root.Dir(tmpDirPath)
-		temps = append(temps, tmpDST.Path())
+		entry, entryErr := stage.add(modulePlan)
+		if entryErr != nil {
+			err = failure.Failed("导入文件夹失败", fmt.Sprintf("无法创建 %s 的临时目录", modulePlan.Filename)).Wrap(entryErr)
+			return
+		}
 
-		project := ModuleProject{}
-		wroteFailed := false
+		// total
+		srcFiles := make([]string, 0, len(srcFilenames))
+		srcBytes := int64(0)
 		for _, srcFilename := range srcFilenames {
+			info, infoErr := os.Stat(filepath.Join(modulePlan.Filename, srcFilename))
+			if infoErr != nil || info.IsDir() {
+				continue
+			}
+			srcFiles = append(srcFiles, srcFilename)
+			srcBytes += info.Size()
+		}
+		progress.Step(fmt.Sprintf("导入 %s", modulePlan.Title))
+		progress.Total(len(srcFiles), srcBytes)
+
+		for _, srcFilename := range srcFiles {
 			if srcFilename == "project.xml" {
 				projectByte, readProjectErr := src.ReadFile("project.xml")
 				if readProjectErr != nil {
 					err = failure.Failed("导入文件夹失败", fmt.Sprintf("无法读取 %s", filepath.Join(modulePlan.Filename, srcFilename)))
-					wroteFailed = true
-					break
+					return
 				}
-				if decodeErr := xml.Unmarshal(projectByte, &project); decodeErr != nil {
+				if decodeErr := xml.Unmarshal(projectByte, &entry.project); decodeErr != nil {
 					err = failure.Failed("导入文件夹失败", fmt.Sprintf("无法解析 %s", filepath.Join(modulePlan.Filename, srcFilename)))
-					wroteFailed = true
-					break
+					return
 				}
-
 			}
 			file, fileErr := src.OpenFile(srcFilename)
 			if fileErr != nil {
-				wroteFailed = true
 				err = failure.Failed("导入文件夹失败", fmt.Sprintf("无法打开 %s", filepath.Join(modulePlan.Filename, srcFilename)))
-				break
+				return
 			}
-			cpErr := tmpDST.CopyFile(srcFilename, file)
+			progress.File(srcFilename)
+			cpErr := entry.tmp.CopyFile(srcFilename, progress.Reader(&contextReader{ctx: ctx, reader: file}))
 			_ = file.Close()
 			if cpErr != nil {
-				err = failure.Failed("导入文件夹失败", fmt.Sprintf("无法复制 %s", filepath.Join(modulePlan.Filename, srcFilename)))
-				wroteFailed = true
-				break
+				err = failure.Failed("导入文件夹失败", fmt.Sprintf("无法复制 %s", filepath.Join(modulePlan.Filename, srcFilename))).Wrap(cpErr)
+				return
 			}
+			progress.FileDone()
 		}
-		if wroteFailed {
-			break
-		}
-
-		// todo not rename and save here,
-		// return []tmp{fs, project, module plan}
-		// use tmp.Commit to rename files.
+	}
+	return
+}
 
-		// rename
+func makeImportedModule(plan *ModulePlan, dst *Module, project *ModuleProject, override bool) (module *Module) {
+	if dst != nil {
+		module = dst
 		if override {
-			_ = os.RemoveAll(dstDirPath)
-		}
-		if rnErr := os.Rename(tmpDST.Path(), dstDirPath); rnErr != nil {
-			err = failure.Failed("导入文件夹失败", fmt.Sprintf("无法重命名 %s", tmpDST.Path()))
-			break
-		}
-
-		// save
-		var module *Module
-		if modulePlan.Dst != nil {
-			module = modulePlan.Dst
-			if override {
-				if idx, ok := module.ExistVersion(*modulePlan.Override); ok {
-					vm := module.Versions[idx]
-					vm.PreviewIconFile = project.PreviewIconFile
-					vm.ItemDescription = project.ItemDescription
-					vm.ItemDescriptionShort = project.ItemDescriptionShort
-					vm.UpdateDetails = project.UpdateDetails
-					module.Versions[idx] = vm
-					if idx+1 == len(module.Versions) {
-						module.PreviewIconFile = filepath.ToSlash(filepath.Join(module.Id, module.Version.String(), module.Versions[len(module.Versions)-1].PreviewIconFile))
-					}
+			if idx, ok := module.ExistVersion(*plan.Override); ok {
+				vm := module.Versions[idx]
+				vm.PreviewIconFile = project.PreviewIconFile
+				vm.ItemDescription = project.ItemDescription
+				vm.ItemDescriptionShort = project.ItemDescriptionShort
+				vm.UpdateDetails = project.UpdateDetails
+				module.Versions[idx] = vm
+				if idx+1 == len(module.Versions) {
+					module.PreviewIconFile = filepath.ToSlash(filepath.Join(module.Id, module.Version.String(), module.Versions[len(module.Versions)-1].PreviewIconFile))
 				}
-			} else {
-				ver, _ := project.Version()
-				module.Add(VersionedModule{
-					Version:              ver,
-					PreviewIconFile:      project.PreviewIconFile,
-					UpdateDetails:        project.UpdateDetails,
-					ItemDescriptionShort: project.ItemDescriptionShort,
-					ItemDescription:      project.ItemDescription,
-				})
 			}
-			module.ModifyAT = time.Now()
 		} else {
-			module = &Module{
-				Id:              modulePlan.Id,
-				PublishId:       modulePlan.PublishFileId,
-				Kind:            modulePlan.Kind,
-				Title:           modulePlan.Title,
-				Remark:          "",
-				ModifyAT:        time.Now(),
-				PreviewIconFile: "",
-				Version:         Version{},
-				Versions:        nil,
-			}
-			ver, _ := project.Version()
+			ver := plan.Version
 			module.Add(VersionedModule{
 				Version:              ver,
 				PreviewIconFile:      project.PreviewIconFile,
@@ -148,17 +310,45 @@ func ImportModulesByDir(root *files.DirFS, plan *ImportPlan) (modules []*Module,
 				ItemDescription:      project.ItemDescription,
 			})
 		}
-	}
-	if err != nil {
-		for _, temp := range temps {
-			_ = os.RemoveAll(temp)
+		if tags := project.ListTags(); len(tags) > 0 {
+			module.Tags = tags
 		}
+		imported := plan.Version
+		if override {
+			imported = *plan.Override
+		}
+		if len(plan.Kinds) > 0 && module.Version == imported {
+			module.Kinds = plan.Kinds
+		}
+		module.ModifyAT = time.Now()
+		return
+	}
+	module = &Module{
+		Id:              plan.Id,
+		PublishId:       plan.PublishFileId,
+		Kind:            plan.Kind,
+		Kinds:           plan.Kinds,
+		Title:           plan.Title,
+		Remark:          "",
+		ModifyAT:        time.Now(),
+		PreviewIconFile: "",
+		Version:         Version{},
+		Versions:        nil,
+		Tags:            project.ListTags(),
 	}
+	ver := plan.Version
+	module.Add(VersionedModule{
+		Version:              ver,
+		PreviewIconFile:      project.PreviewIconFile,
+		UpdateDetails:        project.UpdateDetails,
+		ItemDescriptionShort: project.ItemDescriptionShort,
+		ItemDescription:      project.ItemDescription,
+	})
 	return
 }
 
 func getImportDst(plan *ModulePlan) (dst string, tmp string, override bool) {
-	if !plan.Existed {
+	if !plan.Existed || plan.Dst == nil {
 		if plan.PublishFileId == "" {
 			plan.Id = Id()
 		} else {
@@ -168,18 +358,30 @@ func getImportDst(plan *ModulePlan) (dst string, tmp string, override bool) {
 		tmp = filepath.Join(plan.Id, plan.Version.String()+"_tmp")
 		return
 	}
+	plan.Id = plan.Dst.Id
 	if plan.Override != nil {
 		dst = filepath.Join(plan.Dst.Id, plan.Override.String())
-		dst = filepath.Join(plan.Dst.Id, plan.Override.String()+"_tmp")
+		tmp = filepath.Join(plan.Dst.Id, plan.Override.String()+"_tmp")
 		override = true
 	} else {
 		dst = filepath.Join(plan.Dst.Id, plan.Version.String())
-		dst = filepath.Join(plan.Dst.Id, plan.Version.String()+"_tmp")
+		tmp = filepath.Join(plan.Dst.Id, plan.Version.String()+"_tmp")
 	}
 	return
 }
 
 func DropImportPlan(root *files.DirFS, plan *ImportPlan) {
-
+	for _, module := range plan.Modules {
+		if module.Id == "" {
+			continue
+		}
+		version := module.Version
+		if module.Override != nil {
+			version = *module.Override
+		}
+		tmp := filepath.Join(root.Path(), module.Id, version.String()+"_tmp")
+		_ = os.RemoveAll(tmp)
+		removeEmptyDir(filepath.Dir(tmp))
+	}
 	return
 }
@@ -0,0 +1,96 @@
+package box
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"DarkestDungeonModBoxLite/backend/pkg/archives"
+)
+
+// packTestArchive packs fsys in format, entries are encrypted when password is not empty.
+func packTestArchive(t *testing.T, format string, password string, fsys fstest.MapFS) []byte {
+	entries, entriesErr := archives.DirEntries(fsys, "")
+	if entriesErr != nil {
+		t.Fatal(entriesErr)
+	}
+	buf := bytes.NewBuffer(nil)
+	if err := archives.Pack(context.Background(), buf, entries, archives.PackOptions{Format: format, Password: password}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// newTestModuleArchive writes test.zip of a mod, whose hero files are in a nested 7z encrypted by 222,
+// passwords of entries are set by paths in it, such as test.zip/mod/heroes/crusader.7z.
+func newTestModuleArchive(t *testing.T) (filename string) {
+	inner := packTestArchive(t, ".7z", "222", fstest.MapFS{
+		"crusader/crusader.info.darkest": {Data: []byte("hero: .id \"crusader\"")},
+	})
+	data := packTestArchive(t, ".zip", "", fstest.MapFS{
+		"mod/project.xml":        {Data: []byte("<project><Title>nested</Title><PublishedFileId>3000</PublishedFileId><VersionMajor>1</VersionMajor><VersionMinor>2</VersionMinor></project>")},
+		"mod/preview_icon.png":   {Data: testPreviewIcon(t)},
+		"mod/heroes/crusader.7z": {Data: inner},
+	})
+	filename = filepath.Join(t.TempDir(), "test.zip")
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func TestBox_ImportModules_NestedArchive(t *testing.T) {
+	bx := newTestBox(t)
+	filename := newTestModuleArchive(t)
+	entryPath := "test.zip/mod/heroes/crusader.7z"
+
+	plan, planErr := bx.MakeModuleImportPlan(MakeModuleImportPlanParam{
+		Filename:             filename,
+		ArchiveFilePasswords: ImportArchiveFilePassword{Children: []ImportArchiveFilePassword{{Path: entryPath, Password: "222"}}},
+	})
+	if planErr != nil {
+		t.Fatal(planErr)
+	}
+	t.Log(plan.String())
+	if plan.Invalid || len(plan.Modules) != 1 {
+		t.Fatal("plan is invalid")
+	}
+	modules, importErr := bx.ImportModules(plan)
+	if importErr != nil {
+		t.Fatal(importErr)
+	}
+	if len(modules) != 1 || modules[0].Id != "3000" || modules[0].Version.String() != "v1.2.0" {
+		t.Fatal("imported modules are", modules)
+	}
+	dir := filepath.Join(bx.moduleFS.Path(), "3000", "v1.2.0")
+	for _, name := range []string{"project.xml", "preview_icon.png", "heroes/crusader.7z/crusader/crusader.info.darkest"} {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name))); err != nil {
+			t.Error(name, "is not imported", err)
+		}
+	}
+}
+
+func TestBox_MakeModuleImportPlan_EntryPasswordInvalid(t *testing.T) {
+	bx := newTestBox(t)
+	filename := newTestModuleArchive(t)
+	entryPath := "test.zip/mod/heroes/crusader.7z"
+
+	plan, planErr := bx.MakeModuleImportPlan(MakeModuleImportPlanParam{
+		Filename:             filename,
+		ArchiveFilePasswords: ImportArchiveFilePassword{Children: []ImportArchiveFilePassword{{Path: entryPath, Password: "000"}}},
+	})
+	if planErr != nil {
+		t.Fatal(planErr)
+	}
+	t.Log(plan.String())
+	if !plan.Invalid || plan.Archived == nil || plan.Archived.Password.Invalid {
+		t.Fatal("plan is", plan.Invalid, plan.Archived)
+	}
+	children := plan.Archived.Password.Children
+	if len(children) != 1 || children[0].Path != entryPath || !children[0].Invalid {
+		t.Error("entry password is not invalid", children)
+	}
+}
@@ -1,151 +1,92 @@
 package box
 
 import (
-	"fmt"
+	"path"
+	"slices"
 	"strings"
 
 	"DarkestDungeonModBoxLite/backend/pkg/files"
 )
 
-func GetKindOfModuleByFileStructure(st files.Structure) (kind string) {
-	// heroes
-	heroDirNum := 0
-	if heroes, hasHeroes := st.Get("heroes"); hasHeroes {
-		heroDirNum = len(heroes.Children)
-		if heroDirNum == 1 {
-			name := heroes.Children[0].Name
-			skinOnly := true
-			for _, child := range heroes.Children[0].Children {
-				if !strings.HasPrefix(child.Name, fmt.Sprintf("%s_", name)) {
-					skinOnly = false
-					break
-				}
-			}
-			if skinOnly {
-				kind = HeroSkinsMod
-				return
-			}
-			if _, builtin := builtinHeroes[name]; builtin {
-				kind = HeroTweaksMod
-				return
-			}
-			kind = HeroNewMod
-			return
-		}
+var (
+	uiDirs = []string{
+		"activity_log", "colours", "cursors", "dungeons", "fe_flow", "fonts",
+		"fx", "game_over", "loading_screen", "overlays", "panels", "scrolls",
+	}
+	tweakDirs = []string{
+		"curios", "effects", "inventory", "loot", "maps", "modes",
+		"props", "scripts", "upgrades",
+	}
+	campaignTweakDirs = []string{
+		"estate", "heirloom_exchange", "progression", "provision", "quest", "roster", "town_events",
 	}
+)
 
-	// trinkets
-	if _, hasTrinkets := st.Get("trinkets"); hasTrinkets {
-		if _, hasPanels := st.Get("panels/icons_equip/trinket"); hasPanels {
-			kind = TrinketsMod
-			return
+// GetKindOfFilename classifies a file of module by its path, filename is relative to module root.
+func GetKindOfFilename(filename string) (kind string) {
+	items := strings.Split(path.Clean(strings.ReplaceAll(filename, "\\", "/")), "/")
+	top := items[0]
+	switch {
+	case top == "heroes":
+		if len(items) < 3 {
+			return UnknownMod
 		}
-	}
-	// others
-	tweaks := 0
-	ui := 0
-	monsters := 0
-	localization := 0
-	for _, child := range st.Children {
-		switch child.Name {
-		case "activity_log", "colours", "cursors", "dungeons", "fe_flow", "fonts",
-			"fx", "game_over", "loading_screen", "overlays", "panels", "scrolls":
-			ui++
-			break
-		case "curios", "effects", "inventory", "loot", "maps", "modes",
-			"props", "scripts", "upgrades":
-			tweaks++
-			break
-		case "campaign":
-			for _, entry := range child.Children {
-				switch entry.Name {
-				case "estate", "heirloom_exchange", "progression", "provision", "quest", "roster", "town_events":
-					if len(child.Children) > 0 {
-						tweaks++
-					}
-					break
-				case "town":
-					ui++
-					break
-				default:
-					break
-				}
-			}
-			break
-		case "dlc":
-			if child.OnlyFilesByExt(".png") {
-				ui++
-			} else {
-				tweaks++
-			}
-			break
-		case "raid":
-			for _, entry := range child.Children {
-				switch entry.Name {
-				case "ai":
-					tweaks++
-					break
-				case "camping":
-					for _, camping := range entry.Children {
-						switch camping.Name {
-						case "default.camping_skills.json":
-							tweaks++
-							break
-						case "skill_icons":
-							if len(camping.Children) > 0 {
-								ui++
-							}
-							break
-						}
-					}
-					break
-				case "skill_attributes":
-					ui++
-					break
-				default:
-					break
-				}
-			}
-			break
-		case "raid_results":
-			for _, entry := range child.Children {
-				switch entry.Name {
-				case "raid_results.layout.darkest":
-					tweaks++
-					break
-				default:
-					ui++
-					break
-				}
-			}
-			break
-		case "monsters":
-			monsters++
-			break
-		case "localization":
-			localization++
-			break
-		default:
-			// shared ???
-			break
+		name := items[1]
+		if _, builtin := builtinHeroes[name]; !builtin {
+			return HeroNewMod
 		}
-	}
-	if tweaks == 0 {
-		if monsters > 0 {
-			kind = MonstersMod
-			return
+		if len(items) > 3 && strings.HasPrefix(items[2], name+"_") {
+			return HeroSkinsMod
 		}
-		if localization > 0 {
-			kind = LocalizationMod
-			return
+		return HeroTweaksMod
+	case top == "trinkets":
+		return TrinketsMod
+	case top == "panels" && len(items) > 2 && items[1] == "icons_equip" && items[2] == "trinket":
+		return TrinketsMod
+	case top == "monsters":
+		return MonstersMod
+	case top == "localization":
+		return LocalizationMod
+	case slices.Contains(uiDirs, top):
+		return UIMod
+	case slices.Contains(tweakDirs, top):
+		return GameplayTweaksMod
+	case top == "campaign":
+		if len(items) > 1 && slices.Contains(campaignTweakDirs, items[1]) {
+			return GameplayTweaksMod
 		}
-		if ui > 0 {
-			kind = UIMod
-			return
+		return UIMod
+	case top == "raid":
+		if len(items) > 1 && items[1] == "ai" {
+			return GameplayTweaksMod
 		}
-		kind = UnknownMod
-		return
+		if path.Ext(filename) == ".json" {
+			return GameplayTweaksMod
+		}
+		return UIMod
+	case top == "raid_results":
+		if path.Base(filename) == "raid_results.layout.darkest" {
+			return GameplayTweaksMod
+		}
+		return UIMod
+	case top == "shared":
+		// buffs and other data shared by heroes and monsters
+		if ext := path.Ext(filename); ext == ".json" || ext == ".darkest" {
+			return GameplayTweaksMod
+		}
+		return UIMod
+	case top == "dlc":
+		if path.Ext(filename) == ".png" {
+			return UIMod
+		}
+		return GameplayTweaksMod
+	default:
+		return UnknownMod
 	}
-	kind = GameplayTweaksMod
+}
+
+// GetKindOfModuleByFileStructure returns the most confident kind of ClassifyModuleByFileStructure.
+func GetKindOfModuleByFileStructure(st files.Structure) (kind string) {
+	kind = ClassifyModuleByFileStructure(st, nil)[0].Kind
 	return
 }
@@ -0,0 +1,113 @@
+package box
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"DarkestDungeonModBoxLite/backend/pkg/archives"
+	"DarkestDungeonModBoxLite/backend/pkg/failure"
+	"DarkestDungeonModBoxLite/backend/pkg/files"
+	"DarkestDungeonModBoxLite/backend/pkg/tasks"
+)
+
+// PackModule packs files of module version into filename, which is .zip or .7z,
+// files are in a dir named by module id, and encrypted when password is not empty.
+func (bx *Box) PackModule(id string, version Version, filename string, password string) (err error) {
+	var (
+		module *Module
+	)
+	if module, err = bx.GetModule(id); err != nil {
+		return
+	}
+	if _, ok := module.ExistVersion(version); !ok {
+		err = failure.Failed("打包", fmt.Sprintf("%s 不存在版本 %s", module.Title, version.String()))
+		return
+	}
+	if err = checkPackFilename(filename); err != nil {
+		return
+	}
+	dir := filepath.Join(bx.moduleFS.Path(), id, version.String())
+	entries, entriesErr := archives.DirEntries(os.DirFS(dir), id)
+	if entriesErr != nil {
+		err = failure.Failed("打包", fmt.Sprintf("读取 %s 失败", module.Title)).Wrap(entriesErr)
+		return
+	}
+	err = bx.pack(filename, entries, password)
+	return
+}
+
+func checkPackFilename(filename string) (err error) {
+	ext := strings.ToLower(path.Ext(strings.ReplaceAll(filename, "\\", "/")))
+	if ext != ".zip" && ext != ".7z" {
+		err = failure.Failed("打包", "仅支持打包为 zip 或 7z").Append("文件", filename)
+		return
+	}
+	if exist, _ := files.Exist(filename); exist {
+		err = failure.Failed("打包", fmt.Sprintf("%s 已存在", filename))
+		return
+	}
+	if exist, _ := files.Exist(filepath.Dir(filename)); !exist {
+		err = failure.Failed("打包", "目标目录不存在").Append("位置", filepath.Dir(filename))
+		return
+	}
+	return
+}
+
+// pack runs packing entries into filename as a task.
+func (bx *Box) pack(filename string, entries []archives.PackEntry, password string) (err error) {
+	_, err = bx.processes.Run(bx.ctx, packTaskName, tasks.TaskFunc(func(ctx context.Context, progress *tasks.Progress) (err error) {
+		progress.Step(fmt.Sprintf("打包 %s", filepath.Base(filename)))
+		for i, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			entries[i].Open = progressOpen(progress, entry)
+			progress.Total(1, 0)
+		}
+		if packErr := archives.PackFile(ctx, filename, entries, password); packErr != nil {
+			err = failure.Failed("打包", fmt.Sprintf("打包 %s 失败", filepath.Base(filename))).Wrap(packErr)
+			return
+		}
+		return
+	}))
+	return
+}
+
+// progressOpen counts files and bytes of entry into progress when it is read.
+func progressOpen(progress *tasks.Progress, entry archives.PackEntry) func() (io.ReadCloser, error) {
+	return func() (rc io.ReadCloser, err error) {
+		progress.File(entry.Name)
+		if rc, err = entry.Open(); err != nil {
+			return
+		}
+		rc = &progressReadCloser{Reader: progress.Reader(rc), closer: rc, progress: progress}
+		return
+	}
+}
+
+type progressReadCloser struct {
+	io.Reader
+	closer   io.Closer
+	progress *tasks.Progress
+}
+
+func (rc *progressReadCloser) Close() error {
+	rc.progress.FileDone()
+	return rc.closer.Close()
+}
+
+// contentEntry is an entry of content in memory.
+func contentEntry(name string, content []byte) archives.PackEntry {
+	return archives.PackEntry{
+		Name: name,
+		Open: func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(content)), nil
+		},
+	}
+}
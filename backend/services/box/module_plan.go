@@ -45,6 +45,42 @@ func (stats *ImportArchiveFileStats) String() string {
 	return stats.Password.String()
 }
 
+// setPassword sets the password of archive file or entry at path, it is valid since it opened the archive.
+func (stats *ImportArchiveFileStats) setPassword(path string, password string) {
+	if path == "" {
+		stats.Password.Password = password
+		stats.Password.Invalid = false
+		return
+	}
+	for i, child := range stats.Password.Children {
+		if child.Path == path {
+			stats.Password.Children[i].Password = password
+			stats.Password.Children[i].Invalid = false
+			return
+		}
+	}
+	stats.Password.Children = append(stats.Password.Children, ImportArchiveFilePassword{
+		Path:     path,
+		Password: password,
+		Invalid:  false,
+		Children: nil,
+	})
+}
+
+// setOpenedPasswords sets passwords of encrypted archives which are opened in info,
+// including ones found by keyring, so they are used when importing.
+func (stats *ImportArchiveFileStats) setOpenedPasswords(info *archives.FileInfo) {
+	if info.Encrypted && info.Password != "" {
+		stats.setPassword("", info.Password)
+	}
+	for _, entry := range info.ArchiveEntries() {
+		if entry == info || !entry.Encrypted || entry.PasswordInvalid || entry.Password == "" {
+			continue
+		}
+		stats.setPassword(entry.Path(), entry.Password)
+	}
+}
+
 type ImportEntry struct {
 	Chosen   bool          `json:"chosen"`
 	Key      string        `json:"key"`
@@ -63,6 +99,21 @@ func (entry *ImportEntry) FlatFilenames() []string {
 	return result
 }
 
+func (entry *ImportEntry) countChosenFiles() (n int) {
+	if !entry.Chosen {
+		return
+	}
+	if len(entry.Children) == 0 {
+		n = 1
+		return
+	}
+	for _, child := range entry.Children {
+		n += child.countChosenFiles()
+	}
+	return
+}
+
+// mountArchiveFileInfo mounts children of info, archived entries are mounted as dirs of their entries.
 func (entry *ImportEntry) mountArchiveFileInfo(info *archives.FileInfo, chosen bool) {
 	for _, child := range info.Children {
 		c := ImportEntry{
@@ -71,7 +122,7 @@ func (entry *ImportEntry) mountArchiveFileInfo(info *archives.FileInfo, chosen b
 			Filename: child.Path(),
 			Children: nil,
 		}
-		if child.IsDir {
+		if child.IsDir || child.Archived {
 			c.mountArchiveFileInfo(child, chosen)
 		}
 		entry.Children = append(entry.Children, c)
@@ -136,19 +187,20 @@ func (entry *ImportEntry) String() string {
 }
 
 type ModulePlan struct {
-	Id            string        `json:"id"`
-	Existed       bool          `json:"existed"`
-	Kind          string        `json:"kind"`
-	PublishFileId string        `json:"publishFileId"`
-	Version       Version       `json:"version"`
-	Title         string        `json:"title"`
-	IconBase64    string        `json:"iconBase64"`
-	Filename      string        `json:"filename"`
-	IsDir         bool          `json:"isDir"`
-	Entries       []ImportEntry `json:"entries"`
-	Similar       []*Module     `json:"similar"`  // 当相似存在，则手动选择是否添加（无相同版本）或覆盖（有相同版本）
-	Dst           *Module       `json:"dst"`      // 参数用：当 dst 存在，则往 dst 中添加或覆盖。
-	Override      *Version      `json:"override"` // 参数用：当 override 存在，则覆盖 dst 中的版本。
+	Id            string          `json:"id"`
+	Existed       bool            `json:"existed"`
+	Kind          string          `json:"kind"`
+	Kinds         []KindCandidate `json:"kinds"`
+	PublishFileId string          `json:"publishFileId"`
+	Version       Version         `json:"version"`
+	Title         string          `json:"title"`
+	IconBase64    string          `json:"iconBase64"`
+	Filename      string          `json:"filename"`
+	IsDir         bool            `json:"isDir"`
+	Entries       []ImportEntry   `json:"entries"`
+	Similar       []*Module       `json:"similar"`  // 当相似存在，则手动选择是否添加（无相同版本）或覆盖（有相同版本）
+	Dst           *Module         `json:"dst"`      // 参数用：当 dst 存在，则往 dst 中添加或覆盖。
+	Override      *Version        `json:"override"` // 参数用：当 override 存在，则覆盖 dst 中的版本。
 }
 
 func (module *ModulePlan) FlatEntryFilenames() []string {
@@ -160,6 +212,13 @@ func (module *ModulePlan) FlatEntryFilenames() []string {
 	return result
 }
 
+func (module *ModulePlan) countChosenFiles() (n int) {
+	for _, entry := range module.Entries {
+		n += entry.countChosenFiles()
+	}
+	return
+}
+
 func (module *ModulePlan) FileStructure() (st files.Structure, err error) {
 	st.Name, _ = filepath.Split(module.Filename)
 	st.IsDir = true
@@ -232,48 +291,61 @@ func (bx *Box) MakeModuleImportPlan(param MakeModuleImportPlanParam) (plan *Impo
 	if isDir {
 		plan, err = MakeModuleImportPlanByDir(bx.ctx, param)
 	} else {
-		plan, err = MakeModuleImportPlanByArchiveFile(bx.ctx, param)
+		var keyring *archiveKeyring
+		if keyring, err = bx.archiveKeyring(); err != nil {
+			return
+		}
+		plan, err = MakeModuleImportPlanByArchiveFile(bx.ctx, param, keyring)
 	}
 	if plan != nil {
 		plan.IsDir = isDir
 		// check existed
-		for i, module := range plan.Modules {
-			if module.PublishFileId != "" {
-				existed, existsErr := bx.ExistsModule(module.PublishFileId)
-				if existsErr != nil {
-					err = failure.Failed("创建模组导入计划失败", "判断模组是否存在错误").Wrap(existsErr)
-					return
-				}
-				if existed {
-					module.Dst, _ = bx.GetModule(module.PublishFileId)
-					for _, vm := range module.Dst.Versions {
-						if vm.Version.Compare(module.Version) == 0 {
-							module.Override = &vm.Version
-							module.Existed = existed
-							break
-						}
-					}
-				}
-				plan.Modules[i] = module
-			} else { // find similar
-				// use title
-				modules, listErr := bx.ListModuleByTitle(module.Title)
-				if listErr != nil {
-					err = failure.Failed("创建模组导入计划失败", "判断模组是否存在错误").Wrap(listErr)
-					return
-				}
-				if len(modules) > 0 {
-					module.Existed = true
-					module.Similar = modules
-					plan.Modules[i] = module
+		for i := range plan.Modules {
+			if err = bx.resolveModulePlanDst(&plan.Modules[i]); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// resolveModulePlanDst finds the existed module of plan by publish id, and the version to be overridden,
+// modules of the same title are similar ones when plan has no publish id.
+func (bx *Box) resolveModulePlanDst(module *ModulePlan) (err error) {
+	if module.PublishFileId != "" {
+		existed, existsErr := bx.ExistsModule(module.PublishFileId)
+		if existsErr != nil {
+			err = failure.Failed("创建模组导入计划失败", "判断模组是否存在错误").Wrap(existsErr)
+			return
+		}
+		if existed {
+			module.Dst, _ = bx.GetModule(module.PublishFileId)
+			for _, vm := range module.Dst.Versions {
+				if vm.Version.Compare(module.Version) == 0 {
+					module.Override = &vm.Version
+					module.Existed = existed
+					break
 				}
 			}
 		}
+		return
+	}
+	// find similar, use title
+	modules, listErr := bx.ListModuleByTitle(module.Title)
+	if listErr != nil {
+		err = failure.Failed("创建模组导入计划失败", "判断模组是否存在错误").Wrap(listErr)
+		return
+	}
+	if len(modules) > 0 {
+		module.Existed = true
+		module.Similar = modules
 	}
 	return
 }
 
-func MakeModuleImportPlanByArchiveFile(ctx context.Context, param MakeModuleImportPlanParam) (plan *ImportPlan, err error) {
+// MakeModuleImportPlanByArchiveFile plans modules in archive file, candidates of keyring are tried
+// when the file or archived entries need passwords, and entries which still need them are marked as invalid.
+func MakeModuleImportPlanByArchiveFile(ctx context.Context, param MakeModuleImportPlanParam, keyring archives.Keyring) (plan *ImportPlan, err error) {
 	// info
 	src, srcErr := os.Open(param.Filename)
 	if srcErr != nil {
@@ -301,25 +373,23 @@ func MakeModuleImportPlanByArchiveFile(ctx context.Context, param MakeModuleImpo
 			file.SetEntryPassword(child.Path, child.Password)
 		}
 	}
-	// validate
-	if validateErr := file.Validate(ctx); validateErr != nil {
-		if errors.Is(err, archives.ErrPasswordRequired) {
-			plan.Archived.Password.Invalid = true
-		} else if errors.Is(err, archives.ErrPasswordInvalid) {
-			plan.Archived.Password.Invalid = true
-		} else {
-			err = failure.Failed("导入压缩包失败", "校验 "+param.Filename+" 失败")
-		}
-		return
+	if keyring != nil {
+		file.SetKeyring(keyring)
 	}
-	// info
+	// info, which validates the file and nested archives in one pass per archive level
 	info, infoErr := file.Info(ctx, "*project.xml", "*preview_icon.png")
 	if infoErr != nil {
 		passwordErrs, isPasswordErr := archives.IsPasswordFailed(infoErr)
 		if !isPasswordErr {
+			if errors.Is(infoErr, archives.ErrPasswordRequired) || errors.Is(infoErr, archives.ErrPasswordInvalid) {
+				plan.Archived.Password.Invalid = true
+				plan.Invalid = true
+				return
+			}
 			err = failure.Failed("导入压缩包失败", "扫描 "+param.Filename+" 失败")
 			return
 		}
+		plan.Archived.setOpenedPasswords(info)
 		for _, passwordErr := range passwordErrs {
 			matched := false
 			for i, child := range plan.Archived.Password.Children {
@@ -347,6 +417,7 @@ func MakeModuleImportPlanByArchiveFile(ctx context.Context, param MakeModuleImpo
 		plan.Invalid = true
 		return
 	}
+	plan.Archived.setOpenedPasswords(info)
 
 	projectInfos := info.Match("*project.xml")
 	if len(projectInfos) == 0 {
@@ -432,7 +503,7 @@ func MakeModuleImportPlanByArchiveFile(ctx context.Context, param MakeModuleImpo
 				Filename: child.Path(),
 				Children: nil,
 			}
-			if child.IsDir {
+			if child.IsDir || child.Archived {
 				entry.mountArchiveFileInfo(child, chosen)
 			}
 			module.Entries = append(module.Entries, entry)
@@ -465,14 +536,15 @@ func MakeModuleImportPlanByArchiveFile(ctx context.Context, param MakeModuleImpo
 				break
 			}
 		}
-		if module.Kind == "" {
-			if st, stErr := module.FileStructure(); stErr == nil {
-				module.Kind = GetKindOfModuleByFileStructure(st)
-				if module.Kind == "" {
-					module.Kind = UnknownMod
-				}
+		if st, stErr := module.FileStructure(); stErr == nil {
+			module.Kinds = ClassifyModuleByFileStructure(st, project.ListTags())
+			if module.Kind == "" {
+				module.Kind = module.Kinds[0].Kind
 			}
 		}
+		if module.Kind == "" {
+			module.Kind = UnknownMod
+		}
 		plan.Modules = append(plan.Modules, module)
 	}
 	if len(plan.Modules) == 0 {
@@ -586,14 +658,15 @@ func MakeModuleImportPlanByDir(_ context.Context, param MakeModuleImportPlanPara
 			break
 		}
 	}
-	if module.Kind == "" {
-		if st, stErr := module.FileStructure(); stErr == nil {
-			module.Kind = GetKindOfModuleByFileStructure(st)
-			if module.Kind == "" {
-				module.Kind = UnknownMod
-			}
+	if st, stErr := module.FileStructure(); stErr == nil {
+		module.Kinds = ClassifyModuleByFileStructure(st, project.ListTags())
+		if module.Kind == "" {
+			module.Kind = module.Kinds[0].Kind
 		}
 	}
+	if module.Kind == "" {
+		module.Kind = UnknownMod
+	}
 
 	plan = &ImportPlan{
 		Source:   filepath.ToSlash(param.Filename),
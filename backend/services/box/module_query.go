@@ -0,0 +1,129 @@
+package box
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"DarkestDungeonModBoxLite/backend/pkg/databases"
+	"DarkestDungeonModBoxLite/backend/pkg/failure"
+)
+
+const (
+	ModuleSortByModifyAT = "modifyAT"
+	ModuleSortByTitle    = "title"
+	ModuleSortByKind     = "kind"
+)
+
+// ModuleQuery
+// empty fields are not filtered, title matches substring first, then characters in order (fuzzy).
+// modules must have one of kinds as its kind or a label of its classified kinds,
+// and must have all tags, and be modified in [ModifyFrom, ModifyTo].
+type ModuleQuery struct {
+	Title      string     `json:"title"`
+	Kinds      []string   `json:"kinds"`
+	Tags       []string   `json:"tags"`
+	ModifyFrom *time.Time `json:"modifyFrom,omitempty"`
+	ModifyTo   *time.Time `json:"modifyTo,omitempty"`
+	SortBy     string     `json:"sortBy"`
+	Desc       bool       `json:"desc"`
+	Offset     int        `json:"offset"`
+	Limit      int        `json:"limit"`
+}
+
+func (query *ModuleQuery) index() string {
+	switch query.SortBy {
+	case ModuleSortByTitle:
+		return moduleTitleIndex
+	case ModuleSortByKind:
+		return moduleKindIndex
+	default:
+		return moduleModifyIndex
+	}
+}
+
+func (query *ModuleQuery) match(module *Module) bool {
+	if len(query.Kinds) > 0 && !slices.ContainsFunc(query.Kinds, func(kind string) bool {
+		return kind == module.Kind || slices.Contains(ModuleLabels(module.Kinds), kind)
+	}) {
+		return false
+	}
+	for _, tag := range query.Tags {
+		if !slices.ContainsFunc(module.Tags, func(s string) bool {
+			return strings.EqualFold(s, tag)
+		}) {
+			return false
+		}
+	}
+	if query.ModifyFrom != nil && module.ModifyAT.Before(*query.ModifyFrom) {
+		return false
+	}
+	if query.ModifyTo != nil && module.ModifyAT.After(*query.ModifyTo) {
+		return false
+	}
+	if title := strings.TrimSpace(query.Title); title != "" {
+		return matchTitle(module.Title, title)
+	}
+	return true
+}
+
+// matchTitle matches case-insensitive substring, or all characters of keyword in order, such as "bhr" in "Bounty Hunter".
+func matchTitle(title string, keyword string) bool {
+	title = strings.ToLower(title)
+	keyword = strings.ToLower(keyword)
+	if strings.Contains(title, keyword) {
+		return true
+	}
+	keyword = strings.Join(strings.Fields(keyword), "")
+	for len(keyword) > 0 {
+		r, size := utf8.DecodeRuneInString(keyword)
+		idx := strings.IndexRune(title, r)
+		if idx < 0 {
+			return false
+		}
+		title = title[idx+utf8.RuneLen(r):]
+		keyword = keyword[size:]
+	}
+	return true
+}
+
+type ModulePage struct {
+	Total   int       `json:"total"`
+	Modules []*Module `json:"modules"`
+}
+
+func (bx *Box) ListModules(query ModuleQuery) (page ModulePage, err error) {
+	var (
+		db *databases.Database
+	)
+	if db, err = bx.database(); err != nil {
+		return
+	}
+	filter := func(_, value string) bool {
+		module := Module{}
+		if decodeErr := json.Unmarshal([]byte(value), &module); decodeErr != nil {
+			return false
+		}
+		return query.match(&module)
+	}
+	modules := make([]*Module, 0, 1)
+	if query.Desc {
+		err = db.Descend(query.index(), &modules, filter)
+	} else {
+		err = db.Ascend(query.index(), &modules, filter)
+	}
+	if err != nil {
+		err = failure.Failed("模组", "获取模组列表失败").Wrap(err)
+		return
+	}
+	page.Total = len(modules)
+	offset := min(max(query.Offset, 0), len(modules))
+	modules = modules[offset:]
+	if query.Limit > 0 && query.Limit < len(modules) {
+		modules = modules[:query.Limit]
+	}
+	page.Modules = modules
+	return
+}
@@ -0,0 +1,40 @@
+package box
+
+import (
+	"testing"
+)
+
+func TestModuleQuery_match(t *testing.T) {
+	module := &Module{
+		Id:    "mod",
+		Title: "Bounty Hunter",
+		Kind:  HeroNewMod,
+		Kinds: []KindCandidate{
+			{Kind: HeroNewMod, Confidence: 0.7, Label: true},
+			{Kind: TrinketsMod, Confidence: 0.3, Label: true},
+			{Kind: UIMod, Confidence: 0.1},
+		},
+		Tags: []string{"New Class"},
+	}
+	unclassified := &Module{Id: "old", Title: "Old", Kind: MonstersMod}
+	cases := []struct {
+		name   string
+		query  ModuleQuery
+		module *Module
+		expect bool
+	}{
+		{"kind", ModuleQuery{Kinds: []string{HeroNewMod}}, module, true},
+		{"label", ModuleQuery{Kinds: []string{TrinketsMod}}, module, true},
+		{"not label", ModuleQuery{Kinds: []string{UIMod}}, module, false},
+		{"any of kinds", ModuleQuery{Kinds: []string{UIMod, TrinketsMod}}, module, true},
+		{"unclassified kind", ModuleQuery{Kinds: []string{MonstersMod}}, unclassified, true},
+		{"unclassified other", ModuleQuery{Kinds: []string{TrinketsMod}}, unclassified, false},
+		{"tag", ModuleQuery{Tags: []string{"new class"}}, module, true},
+		{"fuzzy title", ModuleQuery{Title: "bhr", Kinds: []string{TrinketsMod}}, module, true},
+	}
+	for _, c := range cases {
+		if got := c.query.match(c.module); got != c.expect {
+			t.Error(c.name, "expect", c.expect, "got", got)
+		}
+	}
+}
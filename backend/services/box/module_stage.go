@@ -0,0 +1,193 @@
+package box
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"DarkestDungeonModBoxLite/backend/pkg/databases"
+	"DarkestDungeonModBoxLite/backend/pkg/failure"
+	"DarkestDungeonModBoxLite/backend/pkg/files"
+)
+
+// ImportStage
+/* fs
+mods/
+ {id}/
+  {version}_tmp/  staged, waiting for commit
+  {version}_bak/  replaced version, removed after commit
+  {version}/
+*/
+type ImportStage struct {
+	root    *files.DirFS
+	entries []*importStageEntry
+}
+
+type importStageEntry struct {
+	plan     *ModulePlan
+	project  ModuleProject
+	dst      string
+	tmp      *files.DirFS
+	override bool
+	renamed  bool
+	backup   string
+	// workshopUpdateAT is saved with the module when it is not zero
+	workshopUpdateAT time.Time
+}
+
+func newImportStage(root *files.DirFS) *ImportStage {
+	return &ImportStage{
+		root:    root,
+		entries: nil,
+	}
+}
+
+// add stages plan into its tmp dir, a version can be staged once, since entries of it would share the tmp dir.
+func (stage *ImportStage) add(plan *ModulePlan) (entry *importStageEntry, err error) {
+	dstDirPath, tmpDirPath, override := getImportDst(plan)
+	tmp := filepath.Join(stage.root.Path(), tmpDirPath)
+	for _, staged := range stage.entries {
+		if staged.tmp.Path() == tmp {
+			err = failure.Failed("导入模组失败", fmt.Sprintf("%s 重复导入", filepath.ToSlash(dstDirPath))).Append("模组", plan.Title)
+			return
+		}
+	}
+	if err = os.RemoveAll(tmp); err != nil {
+		return
+	}
+	if err = files.Mkdir(tmp); err != nil {
+		return
+	}
+	tmpDST, tmpErr := files.NewDirFS(tmp)
+	if tmpErr != nil {
+		_ = os.RemoveAll(tmp)
+		err = tmpErr
+		return
+	}
+	entry = &importStageEntry{
+		plan:     plan,
+		dst:      filepath.Join(stage.root.Path(), dstDirPath),
+		tmp:      tmpDST,
+		override: override,
+	}
+	stage.entries = append(stage.entries, entry)
+	return
+}
+
+func (stage *ImportStage) Empty() bool {
+	return len(stage.entries) == 0
+}
+
+// Commit renames all staged modules into place and saves them in one transaction.
+// When anything fails, renamed modules are restored and err is returned, the staged files are kept for Rollback.
+func (stage *ImportStage) Commit(db *databases.Database) (modules []*Module, err error) {
+	// make
+	dict := make(map[string]*Module, len(stage.entries))
+	for _, entry := range stage.entries {
+		dst, has := dict[entry.plan.Id]
+		if !has && entry.plan.Dst != nil {
+			dst = entry.plan.Dst.clone()
+		}
+		module := makeImportedModule(entry.plan, dst, &entry.project, entry.override)
+		if !entry.workshopUpdateAT.IsZero() {
+			module.WorkshopUpdateAT = entry.workshopUpdateAT
+		}
+		if !has {
+			modules = append(modules, module)
+			dict[module.Id] = module
+		}
+	}
+	// rename
+	for _, entry := range stage.entries {
+		if exist, _ := files.Exist(entry.dst); exist {
+			entry.backup = entry.dst + "_bak"
+			_ = os.RemoveAll(entry.backup)
+			if rnErr := os.Rename(entry.dst, entry.backup); rnErr != nil {
+				entry.backup = ""
+				err = failure.Failed("导入模组失败", fmt.Sprintf("无法重命名 %s", entry.dst)).Wrap(rnErr)
+				break
+			}
+		}
+		if rnErr := os.Rename(entry.tmp.Path(), entry.dst); rnErr != nil {
+			err = failure.Failed("导入模组失败", fmt.Sprintf("无法重命名 %s", entry.tmp.Path())).Wrap(rnErr)
+			break
+		}
+		entry.renamed = true
+	}
+	// save
+	if err == nil {
+		txErr := db.Transaction(func(tx *databases.Tx) error {
+			for _, module := range modules {
+				if saveErr := tx.Update(moduleKey(module.Id), module); saveErr != nil {
+					return failure.Failed("模组", fmt.Sprintf("保存 %s 失败", module.Id)).Wrap(saveErr)
+				}
+			}
+			return nil
+		})
+		if txErr != nil {
+			err = failure.Failed("导入模组失败", "保存模组失败").Wrap(txErr)
+		}
+	}
+	if err != nil {
+		stage.restore()
+		modules = nil
+		return
+	}
+	// clean
+	for _, entry := range stage.entries {
+		if entry.backup != "" {
+			_ = os.RemoveAll(entry.backup)
+			entry.backup = ""
+		}
+	}
+	stage.entries = stage.entries[:0]
+	return
+}
+
+func (stage *ImportStage) restore() {
+	for _, entry := range slices.Backward(stage.entries) {
+		if entry.renamed {
+			_ = os.Rename(entry.dst, entry.tmp.Path())
+			entry.renamed = false
+		}
+		if entry.backup != "" {
+			_ = os.Rename(entry.backup, entry.dst)
+			entry.backup = ""
+		}
+	}
+}
+
+// Rollback removes all staged files which are not committed.
+func (stage *ImportStage) Rollback() {
+	stage.restore()
+	for _, entry := range stage.entries {
+		_ = os.RemoveAll(entry.tmp.Path())
+		removeEmptyDir(filepath.Dir(entry.tmp.Path()))
+	}
+	stage.entries = stage.entries[:0]
+}
+
+func removeEmptyDir(path string) {
+	entries, err := os.ReadDir(path)
+	if err != nil || len(entries) > 0 {
+		return
+	}
+	_ = os.Remove(path)
+}
+
+// contextReader stops reading once ctx is done, so a canceled import stops in the middle of a file.
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (n int, err error) {
+	if err = r.ctx.Err(); err != nil {
+		return
+	}
+	return r.reader.Read(p)
+}
@@ -0,0 +1,145 @@
+package box
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// stageTestImport stages a new version of a, which replaces the saved one, and a new module b.
+// kinds of b are not encodable when broken, so saving modules fails after renames.
+func stageTestImport(t *testing.T, bx *Box, broken bool) (stage *ImportStage, entries []*importStageEntry) {
+	a := saveTestModule(t, bx, "a", map[string]string{"old.txt": "old"})
+	plans := []*ModulePlan{
+		{Existed: true, Dst: a, Version: Version{Major: 1}, Title: "a"},
+		{PublishFileId: "b", Version: Version{Major: 1}, Title: "b"},
+	}
+	if broken {
+		plans[1].Kinds = []KindCandidate{{Kind: GameplayTweaksMod, Confidence: math.NaN()}}
+	}
+	stage = newImportStage(bx.moduleFS)
+	for _, plan := range plans {
+		entry, err := stage.add(plan)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = os.WriteFile(filepath.Join(entry.tmp.Path(), "new.txt"), []byte(plan.Title), 0644); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, entry)
+	}
+	return
+}
+
+// checkTestImportRestored checks staged files are kept in tmp dirs, and nothing of them is committed.
+func checkTestImportRestored(t *testing.T, bx *Box) {
+	exist := func(name string) bool {
+		_, err := os.Stat(filepath.Join(bx.moduleFS.Path(), filepath.FromSlash(name)))
+		return err == nil
+	}
+	if !exist("a/v1.0.0/old.txt") || exist("a/v1.0.0/new.txt") {
+		t.Error("replaced version is not restored")
+	}
+	if exist("a/v1.0.0_bak") || exist("b/v1.0.0") {
+		t.Error("backup or dst is left")
+	}
+	if !exist("a/v1.0.0_tmp/new.txt") || !exist("b/v1.0.0_tmp/new.txt") {
+		t.Error("staged files are not kept for rollback")
+	}
+	modules := make([]Module, 0, 1)
+	if err := bx.db.AscendKeys(moduleKey("*"), &modules, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(modules) != 1 || modules[0].Id != "a" || len(modules[0].Versions) != 1 || modules[0].Title != "a" {
+		t.Error("modules are saved", modules)
+	}
+}
+
+func TestImportStage_Commit(t *testing.T) {
+	bx := newTestBox(t)
+	stage, _ := stageTestImport(t, bx, false)
+	modules, err := stage.Commit(bx.db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(modules) != 2 {
+		t.Fatal("committed modules are", modules)
+	}
+	for _, name := range []string{"a/v1.0.0/new.txt", "b/v1.0.0/new.txt"} {
+		if _, statErr := os.Stat(filepath.Join(bx.moduleFS.Path(), filepath.FromSlash(name))); statErr != nil {
+			t.Error(name, "is not committed")
+		}
+	}
+	for _, name := range []string{"a/v1.0.0/old.txt", "a/v1.0.0_bak", "a/v1.0.0_tmp", "b/v1.0.0_tmp"} {
+		if _, statErr := os.Stat(filepath.Join(bx.moduleFS.Path(), filepath.FromSlash(name))); statErr == nil {
+			t.Error(name, "is left")
+		}
+	}
+	if exists, _ := bx.ExistsModule("b"); !exists {
+		t.Error("b is not saved")
+	}
+}
+
+func TestImportStage_Commit_SaveFailed(t *testing.T) {
+	bx := newTestBox(t)
+	stage, _ := stageTestImport(t, bx, true)
+	modules, err := stage.Commit(bx.db)
+	t.Log(err)
+	if err == nil || modules != nil {
+		t.Fatal("commit succeeded", modules)
+	}
+	checkTestImportRestored(t, bx)
+
+	stage.Rollback()
+	for _, name := range []string{"a/v1.0.0_tmp", "b"} {
+		if _, statErr := os.Stat(filepath.Join(bx.moduleFS.Path(), filepath.FromSlash(name))); statErr == nil {
+			t.Error(name, "is left by rollback")
+		}
+	}
+	if _, statErr := os.Stat(filepath.Join(bx.moduleFS.Path(), "a", "v1.0.0", "old.txt")); statErr != nil {
+		t.Error("saved version is removed by rollback")
+	}
+}
+
+func TestImportStage_Commit_RenameFailed(t *testing.T) {
+	bx := newTestBox(t)
+	stage, entries := stageTestImport(t, bx, false)
+	// a is renamed, then renaming b fails
+	if err := os.Rename(entries[1].tmp.Path(), entries[1].tmp.Path()+"_moved"); err != nil {
+		t.Fatal(err)
+	}
+	modules, err := stage.Commit(bx.db)
+	t.Log(err)
+	if err == nil || modules != nil {
+		t.Fatal("commit succeeded", modules)
+	}
+	if err = os.Rename(entries[1].tmp.Path()+"_moved", entries[1].tmp.Path()); err != nil {
+		t.Fatal(err)
+	}
+	checkTestImportRestored(t, bx)
+}
+
+func TestImportStage_add_Duplicated(t *testing.T) {
+	bx := newTestBox(t)
+	stage := newImportStage(bx.moduleFS)
+	first, err := stage.add(&ModulePlan{PublishFileId: "b", Version: Version{Major: 1}, Title: "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(first.tmp.Path(), "new.txt"), []byte("b"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = stage.add(&ModulePlan{PublishFileId: "b", Version: Version{Major: 1}, Title: "b again"})
+	t.Log(err)
+	if err == nil || !strings.Contains(err.Error(), "b/v1.0.0") {
+		t.Error("duplicated version is staged", err)
+	}
+	if _, statErr := os.Stat(filepath.Join(first.tmp.Path(), "new.txt")); statErr != nil {
+		t.Error("files of the first are removed")
+	}
+	if len(stage.entries) != 1 {
+		t.Error("entries are", len(stage.entries))
+	}
+}
@@ -29,7 +29,7 @@ func TestMakeModuleImportPlanByArchiveFile(t *testing.T) {
 			//},
 		},
 	}
-	plan, makeErr := box.MakeModuleImportPlanByArchiveFile(ctx, param)
+	plan, makeErr := box.MakeModuleImportPlanByArchiveFile(ctx, param, nil)
 	if makeErr != nil {
 		t.Error(makeErr.Error())
 		return
@@ -0,0 +1,380 @@
+package box
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"DarkestDungeonModBoxLite/backend/pkg/failure"
+	"DarkestDungeonModBoxLite/backend/pkg/files"
+)
+
+const (
+	SeverityError   = "error"   // the game can not load module as expected
+	SeverityWarning = "warning" // the game may not read some files
+	SeverityInfo    = "info"    // files are useless, such as readme
+
+	FindingMissingProject = "missing_project"
+	FindingInvalidProject = "invalid_project"
+	FindingMissingIcon    = "missing_icon"
+	FindingEmpty          = "empty"
+	FindingWrapperDir     = "wrapper_dir"
+	FindingUnknownTopDir  = "unknown_top_dir"
+	FindingNestedDir      = "nested_dir"
+	FindingMisspelledDir  = "misspelled_dir"
+	FindingCaseMismatch   = "case_mismatch"
+	FindingUnusedFile     = "unused_file"
+)
+
+type ModuleFinding struct {
+	Severity   string `json:"severity"`
+	Code       string `json:"code"`
+	Filename   string `json:"filename"`
+	Message    string `json:"message"`
+	Suggestion string `json:"suggestion"`
+}
+
+type ModuleValidation struct {
+	ModId    string          `json:"modId"`
+	Version  Version         `json:"version"`
+	Valid    bool            `json:"valid"`
+	Findings []ModuleFinding `json:"findings"`
+}
+
+// gameFileIndex indexes paths of game file structure, keys are slash-relative paths.
+type gameFileIndex struct {
+	exact map[string]bool
+	lower map[string]string
+	dirs  map[string][]string // children dir names by lower path of parent
+	exts  map[string]bool
+	tops  map[string]bool
+}
+
+func newGameFileIndex(st *files.Structure) *gameFileIndex {
+	index := &gameFileIndex{
+		exact: make(map[string]bool),
+		lower: make(map[string]string),
+		dirs:  make(map[string][]string),
+		exts:  make(map[string]bool),
+		tops:  make(map[string]bool),
+	}
+	var walk func(parent string, children []files.Structure)
+	walk = func(parent string, children []files.Structure) {
+		for _, child := range children {
+			name := path.Join(parent, child.Name)
+			index.exact[name] = true
+			index.lower[strings.ToLower(name)] = name
+			if !child.IsDir {
+				index.exts[strings.ToLower(path.Ext(child.Name))] = true
+				continue
+			}
+			if parent == "" {
+				index.tops[child.Name] = true
+			}
+			index.dirs[strings.ToLower(parent)] = append(index.dirs[strings.ToLower(parent)], child.Name)
+			walk(name, child.Children)
+		}
+	}
+	walk("", st.Children)
+	return index
+}
+
+// filename returns the path of name in game, mod files are matched case-insensitively by the game,
+// so the path in game is used when files of mods are merged. ok is false when it is not a game file.
+func (index *gameFileIndex) filename(name string) (filename string, ok bool) {
+	filename, ok = index.lower[strings.ToLower(path.Clean(name))]
+	return
+}
+
+// ValidateModule walks files of module version against the game file structure,
+// findings are sorted by severity, module is valid when there is no error.
+func (bx *Box) ValidateModule(id string, version Version) (validation *ModuleValidation, err error) {
+	var (
+		module *Module
+	)
+	if module, err = bx.GetModule(id); err != nil {
+		return
+	}
+	idx, ok := module.ExistVersion(version)
+	if !ok {
+		err = failure.Failed("模组", fmt.Sprintf("%s 不存在版本 %s", module.Title, version.String()))
+		return
+	}
+	dir := filepath.Join(bx.moduleFS.Path(), id, version.String())
+	var filenames []string
+	walkErr := filepath.WalkDir(dir, func(filename string, entry fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		rel, relErr := filepath.Rel(dir, filename)
+		if relErr != nil {
+			return relErr
+		}
+		filenames = append(filenames, filepath.ToSlash(rel))
+		return nil
+	})
+	if walkErr != nil {
+		err = failure.Failed("模组", fmt.Sprintf("读取 %s 失败", dir)).Wrap(walkErr)
+		return
+	}
+	slices.Sort(filenames)
+	icon := "preview_icon.png"
+	if file := module.Versions[idx].PreviewIconFile; file != "" {
+		icon = path.Clean(strings.ReplaceAll(file, "\\", "/"))
+	}
+	validation = &ModuleValidation{
+		ModId:    id,
+		Version:  version,
+		Findings: validateModuleFiles(newGameFileIndex(GetModuleFileStruct()), filenames, icon),
+	}
+	if p, readErr := os.ReadFile(filepath.Join(dir, "project.xml")); readErr == nil {
+		if decodeErr := xml.Unmarshal(p, &ModuleProject{}); decodeErr != nil {
+			validation.Findings = append(validation.Findings, ModuleFinding{
+				Severity: SeverityError,
+				Code:     FindingInvalidProject,
+				Filename: "project.xml",
+				Message:  "project.xml 无法解析",
+			})
+		}
+	}
+	slices.SortStableFunc(validation.Findings, func(a, b ModuleFinding) int {
+		return severityOrder(a.Severity) - severityOrder(b.Severity)
+	})
+	validation.Valid = !slices.ContainsFunc(validation.Findings, func(finding ModuleFinding) bool {
+		return finding.Severity == SeverityError
+	})
+	return
+}
+
+func severityOrder(severity string) int {
+	switch severity {
+	case SeverityError:
+		return 0
+	case SeverityWarning:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// validateModuleFiles checks slash-relative filenames of module, icon is the preview icon file.
+// dirs which are not in the game are allowed, since mods add new content such as heroes,
+// but dirs nested wrongly or misspelled are reported once for each dir.
+func validateModuleFiles(index *gameFileIndex, filenames []string, icon string) (findings []ModuleFinding) {
+	if len(filenames) == 0 {
+		findings = append(findings, ModuleFinding{
+			Severity: SeverityError,
+			Code:     FindingEmpty,
+			Message:  "模组没有任何文件",
+		})
+		return
+	}
+	if !slices.Contains(filenames, "project.xml") {
+		findings = append(findings, ModuleFinding{
+			Severity: SeverityError,
+			Code:     FindingMissingProject,
+			Filename: "project.xml",
+			Message:  "缺少 project.xml，游戏无法识别模组",
+		})
+	}
+	if !slices.Contains(filenames, icon) {
+		findings = append(findings, ModuleFinding{
+			Severity: SeverityWarning,
+			Code:     FindingMissingIcon,
+			Filename: icon,
+			Message:  "缺少预览图标",
+		})
+	}
+	reported := make(map[string]bool)
+	report := func(dir string, finding ModuleFinding) {
+		if reported[dir] {
+			return
+		}
+		reported[dir] = true
+		finding.Filename = dir
+		findings = append(findings, finding)
+	}
+	for _, filename := range filenames {
+		parts := strings.Split(filename, "/")
+		if len(parts) == 1 {
+			if filename != "project.xml" && filename != icon && !index.exact[filename] {
+				findings = append(findings, ModuleFinding{
+					Severity: SeverityInfo,
+					Code:     FindingUnusedFile,
+					Filename: filename,
+					Message:  "根目录下的文件不会被游戏读取",
+				})
+			}
+			continue
+		}
+		top := parts[0]
+		if !index.tops[top] {
+			if reported[top] {
+				continue
+			}
+			if wrapped := wrappedModuleRoot(index, filenames, top); wrapped {
+				report(top, ModuleFinding{
+					Severity:   SeverityError,
+					Code:       FindingWrapperDir,
+					Message:    fmt.Sprintf("模组文件被多包了一层 %s 目录，游戏不会读取", top),
+					Suggestion: "将目录中的文件移动到模组根目录",
+				})
+				continue
+			}
+			finding := ModuleFinding{
+				Severity: SeverityWarning,
+				Code:     FindingUnknownTopDir,
+				Message:  fmt.Sprintf("游戏不会读取 %s 目录", top),
+			}
+			if lower, has := index.lower[strings.ToLower(top)]; has {
+				finding.Code = FindingCaseMismatch
+				finding.Message = fmt.Sprintf("目录 %s 的大小写与游戏不一致", top)
+				finding.Suggestion = lower
+			} else if similar := similarName(top, topNames(index)); similar != "" {
+				finding.Code = FindingMisspelledDir
+				finding.Message = fmt.Sprintf("目录 %s 可能拼写错误", top)
+				finding.Suggestion = similar
+			}
+			report(top, finding)
+			continue
+		}
+		if finding, dir, found := validateNestedDirs(index, parts[:len(parts)-1]); found {
+			report(dir, finding)
+			continue
+		}
+		if ext := strings.ToLower(path.Ext(filename)); !index.exts[ext] {
+			findings = append(findings, ModuleFinding{
+				Severity: SeverityInfo,
+				Code:     FindingUnusedFile,
+				Filename: filename,
+				Message:  fmt.Sprintf("游戏不使用 %s 类型的文件", ext),
+			})
+		}
+	}
+	return
+}
+
+// validateNestedDirs checks dirs of a file under a top dir of game, dir is the first one which is wrong.
+func validateNestedDirs(index *gameFileIndex, dirs []string) (finding ModuleFinding, dir string, found bool) {
+	for i := 1; i < len(dirs); i++ {
+		dir = strings.Join(dirs[:i+1], "/")
+		if index.exact[dir] {
+			continue
+		}
+		parent := strings.Join(dirs[:i], "/")
+		name := dirs[i]
+		if lower, has := index.lower[strings.ToLower(dir)]; has {
+			finding = ModuleFinding{
+				Severity:   SeverityWarning,
+				Code:       FindingCaseMismatch,
+				Message:    fmt.Sprintf("目录 %s 的大小写与游戏不一致", dir),
+				Suggestion: lower,
+			}
+			found = true
+			return
+		}
+		if !index.exact[parent] {
+			// new content of mod, such as heroes/paladin/...
+			return
+		}
+		if name == dirs[i-1] || index.tops[name] {
+			rest := slices.Concat(dirs[:i], dirs[i+1:])
+			if name != dirs[i-1] {
+				rest = dirs[i:]
+			}
+			finding = ModuleFinding{
+				Severity:   SeverityError,
+				Code:       FindingNestedDir,
+				Message:    fmt.Sprintf("目录 %s 嵌套错误，游戏不会读取", dir),
+				Suggestion: strings.Join(rest, "/"),
+			}
+			found = true
+			return
+		}
+		if similar := similarName(name, index.dirs[strings.ToLower(parent)]); similar != "" {
+			finding = ModuleFinding{
+				Severity:   SeverityWarning,
+				Code:       FindingMisspelledDir,
+				Message:    fmt.Sprintf("目录 %s 可能拼写错误", dir),
+				Suggestion: path.Join(parent, similar),
+			}
+			found = true
+			return
+		}
+		return
+	}
+	return
+}
+
+// wrappedModuleRoot returns whether top is an extra dir which wraps the module, such as mymod/heroes/...
+func wrappedModuleRoot(index *gameFileIndex, filenames []string, top string) bool {
+	for _, filename := range filenames {
+		rest, ok := strings.CutPrefix(filename, top+"/")
+		if !ok {
+			continue
+		}
+		parts := strings.Split(rest, "/")
+		if rest == "project.xml" || (len(parts) > 1 && index.tops[parts[0]]) {
+			return true
+		}
+	}
+	return false
+}
+
+func topNames(index *gameFileIndex) []string {
+	names := make([]string, 0, len(index.tops))
+	for name := range index.tops {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// similarName returns the name in names which is closest to name within edit distance 2, the first one wins a tie,
+// names shorter than 5 are not compared, and nothing is similar when name is in names.
+func similarName(name string, names []string) (similar string) {
+	if len(name) < 5 {
+		return
+	}
+	lowered := strings.ToLower(name)
+	closest := 3
+	for _, candidate := range names {
+		d := editDistance(lowered, strings.ToLower(candidate))
+		if d == 0 {
+			return ""
+		}
+		if d < closest {
+			similar, closest = candidate, d
+		}
+	}
+	return
+}
+
+func editDistance(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,197 @@
+package box
+
+import (
+	"slices"
+	"testing"
+
+	"DarkestDungeonModBoxLite/backend/pkg/files"
+)
+
+// newTestGameFileIndex indexes a small game with heroes, trinkets and shared dirs.
+func newTestGameFileIndex() *gameFileIndex {
+	file := func(name string) files.Structure {
+		return files.Structure{Name: name}
+	}
+	dir := func(name string, children ...files.Structure) files.Structure {
+		return files.Structure{Name: name, IsDir: true, Children: children}
+	}
+	return newGameFileIndex(&files.Structure{
+		Name:  "DarkestDungeon",
+		IsDir: true,
+		Children: []files.Structure{
+			dir("heroes",
+				dir("crusader", file("crusader.info.darkest"), dir("anim", file("crusader.sprite.idle.png"))),
+				dir("vestal", file("vestal.info.darkest")),
+			),
+			dir("trinkets", file("base.entries.trinkets.json")),
+			dir("shared", dir("buffs", file("base.buffs.json"))),
+			file("svn_revision.txt"),
+		},
+	})
+}
+
+func TestValidateModuleFiles(t *testing.T) {
+	index := newTestGameFileIndex()
+	cases := []struct {
+		name      string
+		filenames []string
+		icon      string
+		expect    []string // code:filename:suggestion
+	}{
+		{"empty", nil, "preview_icon.png", []string{"empty::"}},
+		{
+			"valid",
+			[]string{"heroes/crusader/crusader.info.darkest", "heroes/paladin/paladin.info.darkest", "preview_icon.png", "project.xml"},
+			"preview_icon.png",
+			nil,
+		},
+		{
+			"custom icon",
+			[]string{"icon.png", "project.xml", "trinkets/base.entries.trinkets.json"},
+			"icon.png",
+			nil,
+		},
+		{
+			"missing project and icon",
+			[]string{"heroes/crusader/crusader.info.darkest"},
+			"preview_icon.png",
+			[]string{"missing_project:project.xml:", "missing_icon:preview_icon.png:"},
+		},
+		{
+			"wrapper dir",
+			[]string{"mymod/heroes/crusader/crusader.info.darkest", "mymod/heroes/vestal/vestal.info.darkest", "preview_icon.png", "project.xml"},
+			"preview_icon.png",
+			[]string{"wrapper_dir:mymod:将目录中的文件移动到模组根目录"},
+		},
+		{
+			"wrapper dir with project",
+			[]string{"mymod/preview_icon.png", "mymod/project.xml", "preview_icon.png", "project.xml"},
+			"preview_icon.png",
+			[]string{"wrapper_dir:mymod:将目录中的文件移动到模组根目录"},
+		},
+		{
+			"nested same dir",
+			[]string{"heroes/heroes/crusader/crusader.info.darkest", "heroes/heroes/vestal/vestal.info.darkest", "preview_icon.png", "project.xml"},
+			"preview_icon.png",
+			[]string{"nested_dir:heroes/heroes:heroes/crusader"},
+		},
+		{
+			"nested top dir",
+			[]string{"heroes/trinkets/base.entries.trinkets.json", "preview_icon.png", "project.xml"},
+			"preview_icon.png",
+			[]string{"nested_dir:heroes/trinkets:trinkets"},
+		},
+		{
+			"misspelled top dir",
+			[]string{"preview_icon.png", "project.xml", "trinkits/base.entries.trinkets.json"},
+			"preview_icon.png",
+			[]string{"misspelled_dir:trinkits:trinkets"},
+		},
+		{
+			"misspelled nested dir",
+			[]string{"heroes/crusadr/crusader.info.darkest", "preview_icon.png", "project.xml"},
+			"preview_icon.png",
+			[]string{"misspelled_dir:heroes/crusadr:heroes/crusader"},
+		},
+		{
+			"case mismatch top dir",
+			[]string{"Heroes/crusader/crusader.info.darkest", "preview_icon.png", "project.xml"},
+			"preview_icon.png",
+			[]string{"case_mismatch:Heroes:heroes"},
+		},
+		{
+			"case mismatch nested dir",
+			[]string{"heroes/Crusader/anim/crusader.sprite.idle.png", "heroes/Crusader/crusader.info.darkest", "preview_icon.png", "project.xml"},
+			"preview_icon.png",
+			[]string{"case_mismatch:heroes/Crusader:heroes/crusader"},
+		},
+		{
+			"unknown top dir",
+			[]string{"docs/readme.md", "preview_icon.png", "project.xml"},
+			"preview_icon.png",
+			[]string{"unknown_top_dir:docs:"},
+		},
+		{
+			"unused files",
+			[]string{"heroes/crusader/crusader.psd", "preview_icon.png", "project.xml", "readme.txt", "svn_revision.txt"},
+			"preview_icon.png",
+			[]string{"unused_file:heroes/crusader/crusader.psd:", "unused_file:readme.txt:"},
+		},
+	}
+	for _, c := range cases {
+		var got []string
+		for _, finding := range validateModuleFiles(index, c.filenames, c.icon) {
+			got = append(got, finding.Code+":"+finding.Filename+":"+finding.Suggestion)
+		}
+		t.Log(c.name, got)
+		if !slices.Equal(got, c.expect) {
+			t.Error(c.name, "expect", c.expect, "got", got)
+		}
+	}
+}
+
+func TestValidateNestedDirs(t *testing.T) {
+	index := newTestGameFileIndex()
+	cases := []struct {
+		dirs   []string
+		found  bool
+		code   string
+		dir    string
+		expect string
+	}{
+		{[]string{"heroes", "crusader", "anim"}, false, "", "", ""},
+		{[]string{"heroes", "paladin", "anim"}, false, "", "", ""},
+		{[]string{"heroes", "heroes", "crusader"}, true, FindingNestedDir, "heroes/heroes", "heroes/crusader"},
+		{[]string{"heroes", "shared", "buffs"}, true, FindingNestedDir, "heroes/shared", "shared/buffs"},
+		{[]string{"heroes", "crusader", "Anim"}, true, FindingCaseMismatch, "heroes/crusader/Anim", "heroes/crusader/anim"},
+		{[]string{"shared", "bufffs"}, true, FindingMisspelledDir, "shared/bufffs", "shared/buffs"},
+	}
+	for _, c := range cases {
+		finding, dir, found := validateNestedDirs(index, c.dirs)
+		if found != c.found || (found && (finding.Code != c.code || dir != c.dir || finding.Suggestion != c.expect)) {
+			t.Error(c.dirs, "got", found, finding.Code, dir, finding.Suggestion)
+		}
+	}
+}
+
+func TestWrappedModuleRoot(t *testing.T) {
+	index := newTestGameFileIndex()
+	cases := []struct {
+		filenames []string
+		top       string
+		expect    bool
+	}{
+		{[]string{"mymod/project.xml"}, "mymod", true},
+		{[]string{"mymod/heroes/crusader/crusader.info.darkest"}, "mymod", true},
+		{[]string{"mymod/heroes"}, "mymod", false},
+		{[]string{"docs/readme.md", "mymod/heroes/crusader/crusader.info.darkest"}, "docs", false},
+		{[]string{"mymodx/project.xml"}, "mymod", false},
+	}
+	for _, c := range cases {
+		if got := wrappedModuleRoot(index, c.filenames, c.top); got != c.expect {
+			t.Error(c.filenames, c.top, "expect", c.expect, "got", got)
+		}
+	}
+}
+
+func TestSimilarName(t *testing.T) {
+	names := []string{"buffs", "curio", "curios", "heroes", "trinkets"}
+	cases := []struct {
+		name   string
+		expect string
+	}{
+		{"trinket", "trinkets"},
+		{"Trinkts", "trinkets"},
+		{"hereos", "heroes"},
+		{"heroes", ""},
+		{"curioss", "curios"}, // closer than the first one
+		{"Curios", ""},        // curio is close, but curios is the same
+		{"bufs", ""},          // too short
+		{"monsters", ""},
+	}
+	for _, c := range cases {
+		if got := similarName(c.name, names); got != c.expect {
+			t.Error(c.name, "expect", c.expect, "got", got)
+		}
+	}
+}
@@ -18,7 +18,7 @@ func schemaKey(id string) string {
 }
 
 func schemaModulesKey(id string) string {
-	return fmt.Sprintf("schema:%s:mod:*", id)
+	return fmt.Sprintf("plan:%s:mod:*", id)
 }
 
 type Schema struct {
@@ -28,10 +28,16 @@ type Schema struct {
 	CreateAT time.Time `json:"createAT"`
 }
 
+// SchemaModule
+// version is pinned when Version is set, otherwise the latest version is used.
+/* db
+plan:{schema id}:mod:{mod id}
+*/
 type SchemaModule struct {
-	PlanId string `json:"planId"`
-	ModId  string `json:"modId"`
-	Index  uint   `json:"index"`
+	PlanId  string   `json:"planId"`
+	ModId   string   `json:"modId"`
+	Index   uint     `json:"index"`
+	Version *Version `json:"version,omitempty"`
 }
 
 func (module *SchemaModule) Key() string {
@@ -0,0 +1,108 @@
+package box
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"DarkestDungeonModBoxLite/backend/pkg/failure"
+	"DarkestDungeonModBoxLite/backend/pkg/files"
+)
+
+type ConflictModule struct {
+	ModId   string  `json:"modId"`
+	Title   string  `json:"title"`
+	Version Version `json:"version"`
+	Index   uint    `json:"index"`
+}
+
+type FileConflict struct {
+	Filename string           `json:"filename"`
+	Kind     string           `json:"kind"`
+	Winner   string           `json:"winner"`
+	Modules  []ConflictModule `json:"modules"`
+}
+
+type FileConflictGroup struct {
+	Kind      string         `json:"kind"`
+	Conflicts []FileConflict `json:"conflicts"`
+}
+
+// AnalyzeSchemaConflicts reports files provided by more than one module of schema,
+// the winner is the last module in load order.
+func (bx *Box) AnalyzeSchemaConflicts(id string) (groups []FileConflictGroup, err error) {
+	sources, sourcesErr := bx.listSchemaModuleSources(id)
+	if sourcesErr != nil {
+		err = sourcesErr
+		return
+	}
+	conflicts := make(map[string]*FileConflict)
+	keys := make([]string, 0, 1)
+	for _, source := range sources {
+		filenames, filenamesErr := moduleVersionFilenames(bx.moduleFS, source.module, source.version)
+		if filenamesErr != nil {
+			err = failure.Failed("冲突检测", fmt.Sprintf("读取 %s 失败", source.module.Title)).Wrap(filenamesErr)
+			return
+		}
+		for _, filename := range filenames {
+			// game files are case-insensitive on windows
+			key := strings.ToLower(filename)
+			conflict, has := conflicts[key]
+			if !has {
+				conflict = &FileConflict{
+					Filename: filename,
+					Kind:     GetKindOfFilename(filename),
+				}
+				conflicts[key] = conflict
+				keys = append(keys, key)
+			}
+			conflict.Modules = append(conflict.Modules, ConflictModule{
+				ModId:   source.module.Id,
+				Title:   source.module.Title,
+				Version: source.version,
+				Index:   source.Index,
+			})
+			conflict.Winner = source.module.Id
+		}
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		conflict := conflicts[key]
+		if len(conflict.Modules) < 2 {
+			continue
+		}
+		idx := slices.IndexFunc(groups, func(group FileConflictGroup) bool {
+			return group.Kind == conflict.Kind
+		})
+		if idx < 0 {
+			groups = append(groups, FileConflictGroup{Kind: conflict.Kind})
+			idx = len(groups) - 1
+		}
+		groups[idx].Conflicts = append(groups[idx].Conflicts, *conflict)
+	}
+	slices.SortFunc(groups, func(a, b FileConflictGroup) int {
+		return strings.Compare(a.Kind, b.Kind)
+	})
+	return
+}
+
+// moduleVersionFilenames lists game files of module version, project.xml and preview icon are excluded.
+func moduleVersionFilenames(root *files.DirFS, module *Module, version Version) (filenames []string, err error) {
+	info, infoErr := root.DirInfo(path.Join(module.Id, version.String()))
+	if infoErr != nil {
+		err = infoErr
+		return
+	}
+	icon := "preview_icon.png"
+	if idx, ok := module.ExistVersion(version); ok && module.Versions[idx].PreviewIconFile != "" {
+		icon = path.Clean(strings.ReplaceAll(module.Versions[idx].PreviewIconFile, "\\", "/"))
+	}
+	for _, filename := range info.Files() {
+		if filename == "project.xml" || filename == icon {
+			continue
+		}
+		filenames = append(filenames, filename)
+	}
+	return
+}
@@ -0,0 +1,44 @@
+package box
+
+import (
+	"testing"
+)
+
+func TestBox_AnalyzeSchemaConflicts(t *testing.T) {
+	bx := newTestBox(t)
+	schema, schemaErr := bx.CreateSchema("conflicts")
+	if schemaErr != nil {
+		t.Fatal(schemaErr)
+	}
+	// b is later than a, both ship project.xml and preview icon
+	for _, item := range []struct{ id, hero, own string }{
+		{"a", "heroes/crusader/crusader.info.darkest", "trinkets/a.trinkets.json"},
+		{"b", "heroes/crusader/Crusader.Info.darkest", "trinkets/b.trinkets.json"},
+	} {
+		saveTestModule(t, bx, item.id, map[string]string{
+			"project.xml":      "<project><Title>" + item.id + "</Title></project>",
+			"preview_icon.png": item.id,
+			item.hero:          "hero: .id \"crusader\"",
+			item.own:           "{}",
+		})
+		if err := bx.AddSchemaModule(schema.Id, item.id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	groups, err := bx.AnalyzeSchemaConflicts(schema.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(groups)
+	if len(groups) != 1 || groups[0].Kind != HeroTweaksMod || len(groups[0].Conflicts) != 1 {
+		t.Fatal("groups are", groups)
+	}
+	conflict := groups[0].Conflicts[0]
+	if conflict.Filename != "heroes/crusader/crusader.info.darkest" || conflict.Kind != HeroTweaksMod || conflict.Winner != "b" {
+		t.Error("conflict is", conflict)
+	}
+	if len(conflict.Modules) != 2 || conflict.Modules[0].ModId != "a" || conflict.Modules[1].ModId != "b" {
+		t.Error("conflict modules are", conflict.Modules)
+	}
+}
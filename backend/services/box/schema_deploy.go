@@ -0,0 +1,390 @@
+package box
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"DarkestDungeonModBoxLite/backend/pkg/databases"
+	"DarkestDungeonModBoxLite/backend/pkg/failure"
+	"DarkestDungeonModBoxLite/backend/pkg/files"
+	"DarkestDungeonModBoxLite/backend/pkg/tasks"
+)
+
+const (
+	deploymentKey    = "deployment"
+	deployedMergedId = "merged"
+	// deployStagingDir holds dirs of a deployment until all of them are written, it is not a mod of the game.
+	deployStagingDir = ".ddmb_staging"
+)
+
+type DeployedModule struct {
+	ModId   string   `json:"modId"`
+	Version Version  `json:"version"`
+	Dir     string   `json:"dir"`
+	Files   []string `json:"files"`
+}
+
+// Deployment
+// modules are placed in load order, files of later modules override files of earlier ones.
+// files merged from modules, such as string tables, are placed after all modules.
+/* fs
+{game}/mods/
+ ddmb_000_{mod id}/
+  project.xml
+  ...
+ ddmb_001_{mod id}/
+  ...
+ ddmb_00n_merged/
+  project.xml
+  localization/ddmb_merged.string_table.xml
+  campaign/.../default.camping_skills.json  merged json data
+*/
+type Deployment struct {
+	SchemaId string           `json:"schemaId"`
+	Target   string           `json:"target"`
+	Modules  []DeployedModule `json:"modules"`
+	DeployAT time.Time        `json:"deployAT"`
+}
+
+func deployedModuleDir(index int, modId string) string {
+	return fmt.Sprintf("ddmb_%03d_%s", index, modId)
+}
+
+func (bx *Box) GetDeployment() (deployment *Deployment, err error) {
+	var (
+		has bool
+		db  *databases.Database
+	)
+	if db, err = bx.database(); err != nil {
+		return
+	}
+	deployment = &Deployment{}
+	if has, err = db.Get(deploymentKey, deployment); err != nil {
+		deployment = nil
+		err = failure.Failed("部署", "获取部署信息失败").Wrap(err)
+		return
+	}
+	if !has {
+		deployment = nil
+	}
+	return
+}
+
+// DeploySchema writes modules of schema into the game mods dir in load order,
+// the previous deployment is replaced only after all of them are written.
+func (bx *Box) DeploySchema(id string) (deployment *Deployment, err error) {
+	var (
+		db       *databases.Database
+		schema   *Schema
+		settings Settings
+		sources  []schemaModuleSource
+	)
+	if db, err = bx.database(); err != nil {
+		return
+	}
+	if schema, err = bx.GetSchema(id); err != nil {
+		return
+	}
+	if settings, err = bx.Settings(); err != nil {
+		return
+	}
+	target := settings.GameModDir()
+	if exist, _ := files.Exist(settings.Game); !exist {
+		err = failure.Failed("部署", "游戏目录不存在").Append("位置", settings.Game)
+		return
+	}
+	if sources, err = bx.listSchemaModuleSources(id); err != nil {
+		return
+	}
+	merged, mergedErr := bx.mergeDeployFiles(sources, settings.Game)
+	if mergedErr != nil {
+		err = failure.Failed("部署", "合并文件失败").Wrap(mergedErr)
+		return
+	}
+	if err = files.Mkdir(target); err != nil {
+		err = failure.Failed("部署", "无法创建游戏模组目录").Append("位置", target).Wrap(err)
+		return
+	}
+	// dirs are written into staging, so the previous deployment is kept when writing fails
+	staging := filepath.Join(target, deployStagingDir)
+	if err = os.RemoveAll(staging); err != nil {
+		err = failure.Failed("部署", "无法清理暂存目录").Append("位置", staging).Wrap(err)
+		return
+	}
+	defer func() {
+		_ = os.RemoveAll(staging)
+	}()
+
+	deployment = &Deployment{
+		SchemaId: id,
+		Target:   target,
+		Modules:  make([]DeployedModule, 0, len(sources)),
+		DeployAT: time.Now(),
+	}
+	_, err = bx.processes.Run(bx.ctx, deployTaskName, tasks.TaskFunc(func(ctx context.Context, progress *tasks.Progress) (err error) {
+		for _, source := range sources {
+			fileCount, byteCount := dirTotal(source.dir(bx.moduleFS))
+			progress.Total(fileCount, byteCount)
+		}
+		for i, source := range sources {
+			progress.Step(fmt.Sprintf("部署 %s", source.module.Title))
+			deployed := DeployedModule{
+				ModId:   source.module.Id,
+				Version: source.version,
+				Dir:     deployedModuleDir(i, source.module.Id),
+				Files:   nil,
+			}
+			if deployed.Files, err = deployModuleFiles(ctx, source.dir(bx.moduleFS), filepath.Join(staging, deployed.Dir), progress); err != nil {
+				err = failure.Failed("部署", fmt.Sprintf("部署 %s 失败", source.module.Title)).Wrap(err)
+				return
+			}
+			deployment.Modules = append(deployment.Modules, deployed)
+		}
+		if len(merged) > 0 {
+			progress.Step("部署合并文件")
+			deployed := DeployedModule{
+				ModId: deployedMergedId,
+				Dir:   deployedModuleDir(len(sources), deployedMergedId),
+			}
+			if deployed.Files, err = deployMergedFiles(filepath.Join(staging, deployed.Dir), merged); err != nil {
+				err = failure.Failed("部署", "部署合并文件失败").Wrap(err)
+				return
+			}
+			deployment.Modules = append(deployment.Modules, deployed)
+		}
+		return
+	}))
+	if err != nil {
+		deployment = nil
+		return
+	}
+
+	// undeploy previous, then staged dirs are moved in
+	previous, previousErr := bx.GetDeployment()
+	if previousErr != nil {
+		err = previousErr
+		deployment = nil
+		return
+	}
+	if previous != nil {
+		if err = bx.UndeploySchema(previous.SchemaId); err != nil {
+			deployment = nil
+			return
+		}
+	}
+	for _, module := range deployment.Modules {
+		if err = os.Rename(filepath.Join(staging, module.Dir), filepath.Join(target, module.Dir)); err != nil {
+			err = failure.Failed("部署", fmt.Sprintf("无法移动 %s", module.Dir)).Append("位置", target).Wrap(err)
+			break
+		}
+	}
+	if err == nil {
+		schema.Deployed = true
+		err = db.Transaction(func(tx *databases.Tx) (err error) {
+			if err = tx.Update(deploymentKey, deployment); err != nil {
+				return
+			}
+			err = tx.Update(schemaKey(schema.Id), schema)
+			return
+		})
+		if err != nil {
+			err = failure.Failed("部署", "保存部署信息失败").Wrap(err)
+		}
+	}
+	if err != nil {
+		removeDeployedFiles(deployment)
+		deployment = nil
+		return
+	}
+	return
+}
+
+func (bx *Box) UndeploySchema(id string) (err error) {
+	var (
+		db         *databases.Database
+		deployment *Deployment
+	)
+	if db, err = bx.database(); err != nil {
+		return
+	}
+	if deployment, err = bx.GetDeployment(); err != nil {
+		return
+	}
+	if deployment == nil || deployment.SchemaId != id {
+		err = failure.Failed("部署", fmt.Sprintf("%s 未部署", id))
+		return
+	}
+	if failed := removeDeployedFiles(deployment); len(failed) > 0 {
+		err = failure.Failed("部署", "无法删除已部署的文件").Append("文件", strings.Join(failed, ", "))
+		return
+	}
+	err = db.Transaction(func(tx *databases.Tx) (err error) {
+		if err = tx.Remove(deploymentKey); err != nil {
+			return
+		}
+		schema := Schema{}
+		has, getErr := tx.Get(schemaKey(id), &schema)
+		if getErr != nil {
+			err = getErr
+			return
+		}
+		if has {
+			schema.Deployed = false
+			err = tx.Update(schemaKey(id), schema)
+		}
+		return
+	})
+	if err != nil {
+		err = failure.Failed("部署", "保存部署信息失败").Wrap(err)
+		return
+	}
+	return
+}
+
+func deployModuleFiles(ctx context.Context, src string, dst string, progress *tasks.Progress) (deployed []string, err error) {
+	if exist, _ := files.Exist(dst); exist {
+		err = failure.Failed("部署", fmt.Sprintf("%s 已存在", dst))
+		return
+	}
+	err = filepath.WalkDir(src, func(path string, entry fs.DirEntry, walkErr error) (err error) {
+		if walkErr != nil {
+			err = walkErr
+			return
+		}
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		if entry.IsDir() {
+			return
+		}
+		rel, relErr := filepath.Rel(src, path)
+		if relErr != nil {
+			err = relErr
+			return
+		}
+		progress.File(filepath.ToSlash(rel))
+		if _, err = files.LinkOrCopy(path, filepath.Join(dst, rel)); err != nil {
+			return
+		}
+		deployed = append(deployed, filepath.ToSlash(rel))
+		if info, infoErr := entry.Info(); infoErr == nil {
+			progress.Bytes(info.Size())
+		}
+		progress.FileDone()
+		return
+	})
+	return
+}
+
+// mergeDeployFiles merges files of sources which would clobber each other when deployed as they are,
+// contents are slash-relative names to merged files, which override files of the same names in modules.
+func (bx *Box) mergeDeployFiles(sources []schemaModuleSource, game string) (contents map[string][]byte, err error) {
+	_, table, tableErr := bx.mergeStringTables(sources)
+	if tableErr != nil {
+		err = tableErr
+		return
+	}
+	if _, contents, err = bx.mergeJsonFiles(sources, game); err != nil {
+		return
+	}
+	if table != nil {
+		contents[mergedStringTableFile] = table.Bytes()
+	}
+	return
+}
+
+// deployMergedFiles writes contents into dst as a mod, which has a project.xml of its own.
+func deployMergedFiles(dst string, contents map[string][]byte) (deployed []string, err error) {
+	if exist, _ := files.Exist(dst); exist {
+		err = failure.Failed("部署", fmt.Sprintf("%s 已存在", dst))
+		return
+	}
+	writes, writesErr := mergedModuleFiles(contents)
+	if writesErr != nil {
+		err = writesErr
+		return
+	}
+	names := slices.Sorted(maps.Keys(writes))
+	for _, name := range names {
+		filename := filepath.Join(dst, filepath.FromSlash(name))
+		if err = files.Mkdir(filepath.Dir(filename)); err != nil {
+			return
+		}
+		if err = os.WriteFile(filename, writes[name], 0644); err != nil {
+			return
+		}
+		deployed = append(deployed, name)
+	}
+	return
+}
+
+// mergedModuleFiles are merged contents with project.xml of the merged module.
+func mergedModuleFiles(contents map[string][]byte) (writes map[string][]byte, err error) {
+	project := ModuleProject{
+		Title:                "模组盒子合并文件",
+		ItemDescriptionShort: "由模组盒子在部署时合并生成",
+	}
+	projectBytes, encodeErr := xml.MarshalIndent(generatedProject{ModuleProject: project}, "", "\t")
+	if encodeErr != nil {
+		err = encodeErr
+		return
+	}
+	writes = map[string][]byte{
+		"project.xml": append([]byte(xml.Header), projectBytes...),
+	}
+	for name, content := range contents {
+		writes[name] = content
+	}
+	return
+}
+
+func dirTotal(dir string) (fileCount int, byteCount int64) {
+	_ = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, walkErr error) (err error) {
+		if walkErr != nil || entry.IsDir() {
+			return
+		}
+		if info, infoErr := entry.Info(); infoErr == nil {
+			fileCount++
+			byteCount += info.Size()
+		}
+		return
+	})
+	return
+}
+
+// removeDeployedFiles only removes files recorded in deployment, and dirs left empty by them.
+func removeDeployedFiles(deployment *Deployment) (failed []string) {
+	for _, module := range deployment.Modules {
+		root := filepath.Join(deployment.Target, module.Dir)
+		dirs := make([]string, 0, 1)
+		for _, file := range module.Files {
+			path := filepath.Join(root, filepath.FromSlash(file))
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				failed = append(failed, path)
+				continue
+			}
+			for dir := filepath.Dir(path); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+				if !slices.Contains(dirs, dir) {
+					dirs = append(dirs, dir)
+				}
+			}
+		}
+		// deepest first
+		slices.SortFunc(dirs, func(a, b string) int {
+			return len(b) - len(a)
+		})
+		for _, dir := range dirs {
+			removeEmptyDir(dir)
+		}
+		removeEmptyDir(root)
+	}
+	return
+}
@@ -0,0 +1,117 @@
+package box
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBox_DeploySchema(t *testing.T) {
+	bx := newTestBox(t)
+	game := t.TempDir()
+	if err := bx.UpdateSettings(Settings{Game: game}); err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(game, "mods")
+	exist := func(name string) bool {
+		_, err := os.Stat(filepath.Join(target, filepath.FromSlash(name)))
+		return err == nil
+	}
+	write := func(name string) {
+		filename := filepath.Join(target, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("other/readme.txt")
+
+	// both ship the same json, which is merged into a mod after them
+	schema, schemaErr := bx.CreateSchema("deploy")
+	if schemaErr != nil {
+		t.Fatal(schemaErr)
+	}
+	for _, item := range []struct{ id, gold string }{{"a", "200"}, {"b", "300"}} {
+		saveTestModule(t, bx, item.id, map[string]string{
+			"project.xml":                 "<project><Title>" + item.id + "</Title></project>",
+			"campaign/estate/estate.json": `{"gold": ` + item.gold + `}`,
+		})
+		if err := bx.AddSchemaModule(schema.Id, item.id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deployment, deployErr := bx.DeploySchema(schema.Id)
+	if deployErr != nil {
+		t.Fatal(deployErr)
+	}
+	t.Log(deployment.Modules)
+	for _, name := range []string{
+		"ddmb_000_a/project.xml", "ddmb_000_a/campaign/estate/estate.json",
+		"ddmb_001_b/project.xml", "ddmb_001_b/campaign/estate/estate.json",
+		"ddmb_002_merged/project.xml", "ddmb_002_merged/campaign/estate/estate.json",
+	} {
+		if !exist(name) {
+			t.Error(name, "is not deployed")
+		}
+	}
+	if exist(deployStagingDir) {
+		t.Error("staging is left")
+	}
+	saved, getErr := bx.GetDeployment()
+	if getErr != nil || saved == nil || saved.SchemaId != schema.Id || saved.Target != target || len(saved.Modules) != 3 {
+		t.Fatal("deployment is", saved, getErr)
+	}
+	for i, dir := range []string{"ddmb_000_a", "ddmb_001_b", "ddmb_002_merged"} {
+		if module := saved.Modules[i]; module.Dir != dir || len(module.Files) != 2 {
+			t.Error("deployed module is", module)
+		}
+	}
+	if deployed, _ := bx.GetSchema(schema.Id); deployed == nil || !deployed.Deployed {
+		t.Error("schema is not deployed")
+	}
+
+	// a canceled deployment keeps the previous one
+	other, otherErr := bx.CreateSchema("canceled")
+	if otherErr != nil {
+		t.Fatal(otherErr)
+	}
+	if err := bx.AddSchemaModule(other.Id, "b"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	bx.ctx = ctx
+	if _, err := bx.DeploySchema(other.Id); err == nil {
+		t.Fatal("canceled deployment succeeded")
+	}
+	bx.ctx = context.Background()
+	if !exist("ddmb_000_a/project.xml") || !exist("ddmb_002_merged/project.xml") || exist(deployStagingDir) {
+		t.Error("previous deployment is not kept")
+	}
+	if saved, getErr = bx.GetDeployment(); getErr != nil || saved == nil || saved.SchemaId != schema.Id {
+		t.Error("deployment record is", saved, getErr)
+	}
+
+	// files not recorded are kept
+	write("ddmb_000_a/user.txt")
+	if err := bx.UndeploySchema(schema.Id); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"ddmb_000_a/project.xml", "ddmb_001_b", "ddmb_002_merged"} {
+		if exist(name) {
+			t.Error(name, "is not removed")
+		}
+	}
+	if !exist("ddmb_000_a/user.txt") || !exist("other/readme.txt") {
+		t.Error("unrelated files are removed")
+	}
+	if saved, getErr = bx.GetDeployment(); getErr != nil || saved != nil {
+		t.Error("deployment is still recorded", saved, getErr)
+	}
+	if undeployed, _ := bx.GetSchema(schema.Id); undeployed == nil || undeployed.Deployed {
+		t.Error("schema is still deployed")
+	}
+}
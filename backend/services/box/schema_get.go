@@ -0,0 +1,125 @@
+package box
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+
+	"DarkestDungeonModBoxLite/backend/pkg/databases"
+	"DarkestDungeonModBoxLite/backend/pkg/failure"
+	"DarkestDungeonModBoxLite/backend/pkg/files"
+)
+
+func (bx *Box) GetSchema(id string) (schema *Schema, err error) {
+	var (
+		has bool
+		db  *databases.Database
+	)
+	if db, err = bx.database(); err != nil {
+		return
+	}
+	schema = &Schema{}
+	has, err = db.Get(schemaKey(id), schema)
+	if err != nil {
+		schema = nil
+		err = failure.Failed("方案", fmt.Sprintf("获取 %s 失败", id)).Wrap(err)
+		return
+	}
+	if !has {
+		schema = nil
+		err = failure.Failed("方案", fmt.Sprintf("%s 不存在", id))
+		return
+	}
+	return
+}
+
+func (bx *Box) ListSchemas() (schemas []Schema, err error) {
+	var (
+		db *databases.Database
+	)
+	if db, err = bx.database(); err != nil {
+		return
+	}
+	schemas = make([]Schema, 0, 1)
+	if err = db.Ascend(schemaIdIndex, &schemas, nil); err != nil {
+		err = failure.Failed("方案", "获取方案列表失败").Wrap(err)
+		return
+	}
+	ReverseSortSchemaByCreateAT(schemas)
+	return
+}
+
+func (bx *Box) ListSchemaModules(id string) (modules []SchemaModule, err error) {
+	var (
+		db *databases.Database
+	)
+	if db, err = bx.database(); err != nil {
+		return
+	}
+	modules, err = listSchemaModules(db, id)
+	if err != nil {
+		err = failure.Failed("方案", fmt.Sprintf("获取 %s 的模组列表失败", id)).Wrap(err)
+		return
+	}
+	return
+}
+
+func listSchemaModules(db *databases.Database, id string) (modules []SchemaModule, err error) {
+	prefix := strings.TrimSuffix(schemaModulesKey(id), "*")
+	modules = make([]SchemaModule, 0, 1)
+	err = db.Range(schemaModuleIndex, 0, math.MaxUint32, &modules, func(key, _ string) bool {
+		return strings.HasPrefix(key, prefix)
+	})
+	return
+}
+
+// schemaModuleVersion returns the version of module used by schema module.
+// the pinned version falls back to the latest when it is not existed.
+func schemaModuleVersion(schemaModule SchemaModule, module *Module) Version {
+	if schemaModule.Version != nil {
+		if _, ok := module.ExistVersion(*schemaModule.Version); ok {
+			return *schemaModule.Version
+		}
+	}
+	return module.Version
+}
+
+type schemaModuleSource struct {
+	SchemaModule
+	module  *Module
+	version Version
+}
+
+func (source *schemaModuleSource) dir(root *files.DirFS) string {
+	return filepath.Join(root.Path(), source.module.Id, source.version.String())
+}
+
+// listSchemaModuleSources resolves modules of schema in load order, and the version of each module to be used.
+func (bx *Box) listSchemaModuleSources(id string) (sources []schemaModuleSource, err error) {
+	var (
+		db      *databases.Database
+		modules []SchemaModule
+	)
+	if db, err = bx.database(); err != nil {
+		return
+	}
+	if modules, err = listSchemaModules(db, id); err != nil {
+		err = failure.Failed("方案", fmt.Sprintf("获取 %s 的模组列表失败", id)).Wrap(err)
+		return
+	}
+	sources = make([]schemaModuleSource, 0, len(modules))
+	for _, schemaModule := range modules {
+		module, moduleErr := bx.GetModule(schemaModule.ModId)
+		if moduleErr != nil {
+			err = moduleErr
+			return
+		}
+		sources = append(sources, schemaModuleSource{
+			SchemaModule: schemaModule,
+			module:       module,
+			version:      schemaModuleVersion(schemaModule, module),
+		})
+	}
+	return
+}
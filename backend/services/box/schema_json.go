@@ -0,0 +1,88 @@
+package box
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"DarkestDungeonModBoxLite/backend/pkg/failure"
+	"DarkestDungeonModBoxLite/backend/pkg/jsonmerge"
+)
+
+type JsonMerge struct {
+	SchemaId string      `json:"schemaId"`
+	Files    []MergeFile `json:"files"`
+}
+
+// isTweakJsonFile such as campaign/town/buildings/camping_trainer/default.camping_skills.json.
+func isTweakJsonFile(filename string) bool {
+	return strings.ToLower(path.Ext(filename)) == ".json" && GetKindOfFilename(filename) == GameplayTweaksMod
+}
+
+// AnalyzeSchemaJsonMerge merges json data files of gameplay tweaks, which are shipped by more than one module of schema.
+// values are merged against the game files by keys and element ids, collisions are won by the last module.
+func (bx *Box) AnalyzeSchemaJsonMerge(id string) (merge *JsonMerge, err error) {
+	var (
+		sources  []schemaModuleSource
+		settings Settings
+	)
+	if sources, err = bx.listSchemaModuleSources(id); err != nil {
+		return
+	}
+	if settings, err = bx.Settings(); err != nil {
+		return
+	}
+	merge, _, err = bx.mergeJsonFiles(sources, settings.Game)
+	if merge != nil {
+		merge.SchemaId = id
+	}
+	return
+}
+
+// mergeJsonFiles merges json files shipped by more than one module of sources, base files are read from game.
+// contents are slash-relative names to merged files.
+func (bx *Box) mergeJsonFiles(sources []schemaModuleSource, game string) (merge *JsonMerge, contents map[string][]byte, err error) {
+	var groups []mergeGroup
+	if groups, err = bx.groupMergeFiles(sources, isTweakJsonFile); err != nil {
+		return
+	}
+
+	merge = &JsonMerge{}
+	contents = make(map[string][]byte)
+	for _, group := range groups {
+		filename := group.filename
+		file := group.mergeFile()
+		mergeSources := make([]jsonmerge.Source, 0, len(group.files))
+		for _, item := range group.files {
+			value, parseErr := jsonmerge.Parse(item.data)
+			if parseErr != nil {
+				err = failure.Failed("合并", fmt.Sprintf("无法解析 %s", item.filename)).Append("模组", item.source.module.Title).Wrap(parseErr)
+				return
+			}
+			mergeSources = append(mergeSources, jsonmerge.Source{Name: item.source.module.Id, Value: value})
+		}
+		var base any
+		if game != "" {
+			if p, readErr := os.ReadFile(filepath.Join(game, filepath.FromSlash(filename))); readErr == nil {
+				base, _ = jsonmerge.Parse(p)
+			}
+		}
+		merged, collisions := jsonmerge.Merge(base, mergeSources)
+		for _, collision := range collisions {
+			texts := make([]string, 0, len(collision.Values))
+			for _, value := range collision.Values {
+				texts = append(texts, jsonmerge.Text(value))
+			}
+			var baseText string
+			if collision.Base != nil {
+				baseText = jsonmerge.Text(collision.Base)
+			}
+			file.Conflicts = append(file.Conflicts, group.conflict(collision.Path, baseText, collision.Sources, texts))
+		}
+		merge.Files = append(merge.Files, file)
+		contents[filename] = jsonmerge.Marshal(merged)
+	}
+	return
+}
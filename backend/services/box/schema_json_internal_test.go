@@ -0,0 +1,61 @@
+package box
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBox_mergeJsonFiles(t *testing.T) {
+	bx := newTestBox(t)
+	game := t.TempDir()
+	filename := "campaign/estate/estate.json"
+	if err := os.MkdirAll(filepath.Join(game, "campaign", "estate"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(game, filepath.FromSlash(filename)), []byte(`{"gold": 100, "busts": 10}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// a changes busts alone, gold is changed by both, mods name the file in other cases
+	sources := make([]schemaModuleSource, 0, 2)
+	for _, item := range []struct{ id, name, text string }{
+		{"a", "campaign/estate/Estate.json", `{"gold": 200, "busts": 20}`},
+		{"b", "campaign/estate/ESTATE.json", `{"gold": 300, "busts": 10}`},
+	} {
+		module := saveTestModule(t, bx, item.id, map[string]string{item.name: item.text})
+		sources = append(sources, schemaModuleSource{module: module, version: module.Version})
+	}
+	merge, contents, err := bx.mergeJsonFiles(sources, game)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(merge.Files) != 1 || merge.Files[0].Filename != filename {
+		t.Fatal("merged files are", merge.Files)
+	}
+
+	merged := struct {
+		Gold  int `json:"gold"`
+		Busts int `json:"busts"`
+	}{}
+	if err = json.Unmarshal(contents[filename], &merged); err != nil {
+		t.Fatal(string(contents[filename]), err)
+	}
+	t.Log(string(contents[filename]))
+	if merged.Gold != 300 {
+		t.Error("gold is not won by b", merged.Gold)
+	}
+	if merged.Busts != 20 {
+		t.Error("busts changed by a is lost", merged.Busts)
+	}
+
+	conflicts := merge.Files[0].Conflicts
+	t.Log(conflicts)
+	if len(conflicts) != 1 {
+		t.Fatal("conflicts are", conflicts)
+	}
+	if conflict := conflicts[0]; conflict.Key != "$.gold" || conflict.Id != filename+"#$.gold" || conflict.Base != "100" ||
+		len(conflict.Candidates) != 2 || conflict.Winner != "b" {
+		t.Error("conflict of gold is", conflict)
+	}
+}
@@ -0,0 +1,120 @@
+package box
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"DarkestDungeonModBoxLite/backend/pkg/failure"
+	"DarkestDungeonModBoxLite/backend/pkg/localization"
+)
+
+const (
+	mergedStringTableFile = "localization/ddmb_merged.string_table.xml"
+)
+
+// StringTableDuplicate
+// modules have the same entry with different texts, Winner is the last module.
+type StringTableDuplicate struct {
+	Language   string           `json:"language"`
+	Id         string           `json:"id"`
+	Candidates []MergeCandidate `json:"candidates"`
+	Winner     string           `json:"winner"`
+}
+
+type StringTableMerge struct {
+	SchemaId   string                 `json:"schemaId"`
+	Modules    []string               `json:"modules"`
+	Duplicates []StringTableDuplicate `json:"duplicates"`
+}
+
+// isStringTableFile such as localization/dialogue.string_table.xml.
+func isStringTableFile(filename string) bool {
+	filename = strings.ToLower(filename)
+	return path.Dir(filename) == "localization" && strings.HasSuffix(filename, ".string_table.xml")
+}
+
+// AnalyzeSchemaStringTables merges string tables of modules in schema by language and id,
+// entries of the same id with different texts are duplicates.
+func (bx *Box) AnalyzeSchemaStringTables(id string) (merge *StringTableMerge, err error) {
+	var (
+		sources []schemaModuleSource
+	)
+	if sources, err = bx.listSchemaModuleSources(id); err != nil {
+		return
+	}
+	merge, _, err = bx.mergeStringTables(sources)
+	if merge != nil {
+		merge.SchemaId = id
+	}
+	return
+}
+
+// mergeStringTables merges all string tables of sources into one table,
+// table is nil when less than two modules have string tables, since nothing is clobbered.
+func (bx *Box) mergeStringTables(sources []schemaModuleSource) (merge *StringTableMerge, table *localization.StringTable, err error) {
+	merge = &StringTableMerge{}
+	tableSources := make([]localization.Source, 0, 1)
+	modules := make([]*Module, 0, 1)
+	for _, source := range sources {
+		names, namesErr := moduleVersionFilenames(bx.moduleFS, source.module, source.version)
+		if namesErr != nil {
+			err = failure.Failed("合并", fmt.Sprintf("读取 %s 失败", source.module.Title)).Wrap(namesErr)
+			return
+		}
+		names = slices.DeleteFunc(names, func(name string) bool {
+			return !isStringTableFile(name)
+		})
+		if len(names) == 0 {
+			continue
+		}
+		slices.Sort(names)
+		// tables of one module are merged in name order
+		combined := &localization.StringTable{}
+		for _, name := range names {
+			file, openErr := os.Open(filepath.Join(source.dir(bx.moduleFS), filepath.FromSlash(name)))
+			if openErr != nil {
+				err = failure.Failed("合并", fmt.Sprintf("读取 %s 失败", name)).Wrap(openErr)
+				return
+			}
+			parsed, parseErr := localization.Parse(file)
+			_ = file.Close()
+			if parseErr != nil {
+				err = failure.Failed("合并", fmt.Sprintf("无法解析 %s", name)).Append("模组", source.module.Title).Wrap(parseErr)
+				return
+			}
+			for _, language := range parsed.Languages {
+				for _, entry := range language.Entries {
+					combined.Set(language.Id, entry.Id, entry.Text)
+				}
+			}
+		}
+		tableSources = append(tableSources, localization.Source{Name: source.module.Id, Table: combined})
+		modules = append(modules, source.module)
+		merge.Modules = append(merge.Modules, source.module.Id)
+	}
+	if len(tableSources) < 2 {
+		return
+	}
+	var duplicates []localization.Duplicate
+	table, duplicates = localization.Merge(tableSources)
+	for _, duplicate := range duplicates {
+		item := StringTableDuplicate{
+			Language: duplicate.Language,
+			Id:       duplicate.Id,
+		}
+		for i, idx := range duplicate.Sources {
+			item.Candidates = append(item.Candidates, MergeCandidate{
+				ModId: modules[idx].Id,
+				Title: modules[idx].Title,
+				Text:  duplicate.Texts[i],
+			})
+		}
+		item.Winner = item.Candidates[len(item.Candidates)-1].ModId
+		merge.Duplicates = append(merge.Duplicates, item)
+	}
+	return
+}
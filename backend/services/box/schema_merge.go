@@ -0,0 +1,293 @@
+package box
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"DarkestDungeonModBoxLite/backend/pkg/darkest"
+	"DarkestDungeonModBoxLite/backend/pkg/databases"
+	"DarkestDungeonModBoxLite/backend/pkg/failure"
+)
+
+type MergeCandidate struct {
+	ModId string `json:"modId"`
+	Title string `json:"title"`
+	Text  string `json:"text"`
+}
+
+// MergeConflict
+// modules changed the same entry differently, Id is {filename}#{key}, which is the key of winners.
+type MergeConflict struct {
+	Id         string           `json:"id"`
+	Filename   string           `json:"filename"`
+	Key        string           `json:"key"`
+	Base       string           `json:"base"`
+	Candidates []MergeCandidate `json:"candidates"`
+	Winner     string           `json:"winner"`
+}
+
+type MergeFile struct {
+	Filename  string          `json:"filename"`
+	Modules   []string        `json:"modules"`
+	Conflicts []MergeConflict `json:"conflicts"`
+}
+
+type HeroMerge struct {
+	SchemaId string      `json:"schemaId"`
+	Files    []MergeFile `json:"files"`
+}
+
+func mergeConflictId(filename string, key string) string {
+	return filename + "#" + key
+}
+
+func heroPatchModuleId(schemaId string) string {
+	return fmt.Sprintf("patch_heroes_%s", schemaId)
+}
+
+// isHeroInfoFile such as heroes/crusader/crusader.info.darkest of builtin heroes.
+func isHeroInfoFile(filename string) (hero string, ok bool) {
+	items := strings.Split(strings.ToLower(filename), "/")
+	if len(items) != 3 || items[0] != "heroes" {
+		return
+	}
+	hero = items[1]
+	if _, builtin := builtinHeroes[hero]; !builtin {
+		return
+	}
+	ok = items[2] == hero+".info.darkest"
+	return
+}
+
+// AnalyzeSchemaHeroMerge merges info files of builtin heroes, which are shipped by more than one module of schema.
+// records are merged against the game files, overlaps are conflicts whose winner is the last module by default.
+func (bx *Box) AnalyzeSchemaHeroMerge(id string) (merge *HeroMerge, err error) {
+	merge, _, err = bx.mergeSchemaHeroes(id, nil)
+	return
+}
+
+// CreateSchemaHeroPatch generates merged hero files as a patch module, and puts it at the last of schema.
+// winners are mod ids by MergeConflict.Id, conflicts not in winners are won by the last module.
+func (bx *Box) CreateSchemaHeroPatch(id string, winners map[string]string) (module *Module, err error) {
+	var (
+		schema   *Schema
+		contents map[string][]byte
+	)
+	if schema, err = bx.GetSchema(id); err != nil {
+		return
+	}
+	if _, contents, err = bx.mergeSchemaHeroes(id, winners); err != nil {
+		return
+	}
+	if len(contents) == 0 {
+		err = failure.Failed("合并", "方案中没有需要合并的英雄文件")
+		return
+	}
+	title := fmt.Sprintf("%s 英雄合并补丁", schema.Name)
+	description := fmt.Sprintf("由模组盒子根据方案 %s 生成", schema.Name)
+	if module, err = bx.generateModule(heroPatchModuleId(id), PatchMod, title, description, contents); err != nil {
+		return
+	}
+	err = bx.putSchemaModuleLast(id, module.Id)
+	return
+}
+
+func (bx *Box) mergeSchemaHeroes(id string, winners map[string]string) (merge *HeroMerge, contents map[string][]byte, err error) {
+	var (
+		sources  []schemaModuleSource
+		settings Settings
+	)
+	if sources, err = bx.listSchemaModuleSources(id); err != nil {
+		return
+	}
+	if settings, err = bx.Settings(); err != nil {
+		return
+	}
+	heroSources := make([]schemaModuleSource, 0, len(sources))
+	for _, source := range sources {
+		if source.module.Kind != PatchMod {
+			heroSources = append(heroSources, source)
+		}
+	}
+	var groups []mergeGroup
+	if groups, err = bx.groupMergeFiles(heroSources, func(name string) bool {
+		_, ok := isHeroInfoFile(name)
+		return ok
+	}); err != nil {
+		return
+	}
+
+	merge = &HeroMerge{SchemaId: id}
+	contents = make(map[string][]byte)
+	for _, group := range groups {
+		filename := group.filename
+		file := group.mergeFile()
+		mergeSources := make([]darkest.Source, 0, len(group.files))
+		for _, item := range group.files {
+			mergeSources = append(mergeSources, darkest.Source{Name: item.source.module.Id, File: darkest.ParseString(string(item.data))})
+		}
+		var base *darkest.File
+		if settings.Game != "" {
+			if p, readErr := os.ReadFile(filepath.Join(settings.Game, filepath.FromSlash(filename))); readErr == nil {
+				base = darkest.ParseString(string(p))
+			}
+		}
+		merged, conflicts := darkest.Merge(base, mergeSources, func(conflict darkest.Conflict) int {
+			winner := conflict.Sources[len(conflict.Sources)-1]
+			if modId, has := winners[mergeConflictId(filename, conflict.Key)]; has {
+				for _, i := range conflict.Sources {
+					if mergeSources[i].Name == modId {
+						winner = i
+					}
+				}
+			}
+			return winner
+		})
+		for _, conflict := range conflicts {
+			texts := make([]string, 0, len(conflict.Records))
+			for _, record := range conflict.Records {
+				texts = append(texts, record.String())
+			}
+			var baseText string
+			if conflict.Base != nil {
+				baseText = conflict.Base.String()
+			}
+			mc := group.conflict(conflict.Key, baseText, conflict.Sources, texts)
+			if modId, has := winners[mc.Id]; has {
+				for _, candidate := range mc.Candidates {
+					if candidate.ModId == modId {
+						mc.Winner = modId
+					}
+				}
+			}
+			file.Conflicts = append(file.Conflicts, mc)
+		}
+		merge.Files = append(merge.Files, file)
+		contents[filename] = []byte(merged.String())
+	}
+	return
+}
+
+// mergeGroup
+// files of sources which are the same game file, filename is the name of the game file.
+type mergeGroup struct {
+	filename string
+	files    []mergeGroupFile
+}
+
+type mergeGroupFile struct {
+	source   schemaModuleSource
+	filename string
+	data     []byte
+}
+
+// groupMergeFiles groups files of sources matched by match, which are shipped by more than one module,
+// in the order they first appear, and files of groups are read.
+func (bx *Box) groupMergeFiles(sources []schemaModuleSource, match func(name string) bool) (groups []mergeGroup, err error) {
+	files := make(map[string][]mergeGroupFile)
+	keys := make([]string, 0, 1)
+	for _, source := range sources {
+		names, namesErr := moduleVersionFilenames(bx.moduleFS, source.module, source.version)
+		if namesErr != nil {
+			err = failure.Failed("合并", fmt.Sprintf("读取 %s 失败", source.module.Title)).Wrap(namesErr)
+			return
+		}
+		for _, name := range names {
+			if !match(name) {
+				continue
+			}
+			key := strings.ToLower(name)
+			if _, has := files[key]; !has {
+				keys = append(keys, key)
+			}
+			files[key] = append(files[key], mergeGroupFile{source: source, filename: name})
+		}
+	}
+	index := newGameFileIndex(GetModuleFileStruct())
+	for _, key := range keys {
+		group := mergeGroup{files: files[key]}
+		if len(group.files) < 2 {
+			continue
+		}
+		// keys only group files in different cases, the game one is read and written
+		var ok bool
+		if group.filename, ok = index.filename(key); !ok {
+			group.filename = path.Clean(group.files[len(group.files)-1].filename)
+		}
+		for i, item := range group.files {
+			p, readErr := os.ReadFile(filepath.Join(item.source.dir(bx.moduleFS), filepath.FromSlash(item.filename)))
+			if readErr != nil {
+				err = failure.Failed("合并", fmt.Sprintf("读取 %s 失败", item.filename)).Wrap(readErr)
+				return
+			}
+			group.files[i].data = p
+		}
+		groups = append(groups, group)
+	}
+	return
+}
+
+func (group mergeGroup) mergeFile() (file MergeFile) {
+	file.Filename = group.filename
+	for _, item := range group.files {
+		file.Modules = append(file.Modules, item.source.module.Id)
+	}
+	return
+}
+
+// conflict of key between files of sources, texts are values of sources, the last of them is the winner.
+func (group mergeGroup) conflict(key string, base string, sources []int, texts []string) (mc MergeConflict) {
+	mc = MergeConflict{
+		Id:       mergeConflictId(group.filename, key),
+		Filename: group.filename,
+		Key:      key,
+		Base:     base,
+	}
+	for i, idx := range sources {
+		mc.Candidates = append(mc.Candidates, MergeCandidate{
+			ModId: group.files[idx].source.module.Id,
+			Title: group.files[idx].source.module.Title,
+			Text:  texts[i],
+		})
+	}
+	mc.Winner = mc.Candidates[len(mc.Candidates)-1].ModId
+	return
+}
+
+// putSchemaModuleLast adds module at the last of schema, or moves it to the last and unpins it.
+func (bx *Box) putSchemaModuleLast(id string, modId string) (err error) {
+	var (
+		db      *databases.Database
+		modules []SchemaModule
+	)
+	if db, err = bx.database(); err != nil {
+		return
+	}
+	if modules, err = listSchemaModules(db, id); err != nil {
+		err = failure.Failed("方案", fmt.Sprintf("获取 %s 的模组列表失败", id)).Wrap(err)
+		return
+	}
+	ordered := make([]SchemaModule, 0, len(modules)+1)
+	last := SchemaModule{PlanId: id, ModId: modId}
+	for _, module := range modules {
+		if module.ModId == modId {
+			// the latest version is generated just now
+			last = module
+			last.Version = nil
+			continue
+		}
+		ordered = append(ordered, module)
+	}
+	ordered = append(ordered, last)
+	err = db.Transaction(func(tx *databases.Tx) error {
+		return updateSchemaModuleIndexes(tx, ordered)
+	})
+	if err != nil {
+		err = failure.Failed("方案", fmt.Sprintf("添加 %s 失败", modId)).Wrap(err)
+		return
+	}
+	return
+}
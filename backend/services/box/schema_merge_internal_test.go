@@ -0,0 +1,82 @@
+package box
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBox_mergeSchemaHeroes(t *testing.T) {
+	bx := newTestBox(t)
+	game := t.TempDir()
+	filename := "heroes/crusader/crusader.info.darkest"
+	if err := os.MkdirAll(filepath.Join(game, "heroes", "crusader"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(game, filepath.FromSlash(filename)), []byte("combat_skill: .id \"smite\" .level 0 .atk 85%\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := bx.UpdateSettings(Settings{Game: game}); err != nil {
+		t.Fatal(err)
+	}
+	schema, schemaErr := bx.CreateSchema("heroes")
+	if schemaErr != nil {
+		t.Fatal(schemaErr)
+	}
+	// b is later than a, mods name the file in other cases
+	for _, item := range []struct{ id, name, atk string }{
+		{"a", "heroes/crusader/Crusader.info.darkest", "90%"},
+		{"b", "heroes/crusader/CRUSADER.INFO.darkest", "95%"},
+	} {
+		saveTestModule(t, bx, item.id, map[string]string{item.name: "combat_skill: .id \"smite\" .level 0 .atk " + item.atk + "\n"})
+		if err := bx.AddSchemaModule(schema.Id, item.id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	merge, contents, err := bx.mergeSchemaHeroes(schema.Id, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(merge.Files) != 1 || merge.Files[0].Filename != filename || len(contents) != 1 {
+		t.Fatal("merged files are", merge.Files)
+	}
+	text := string(contents[filename])
+	t.Log(text)
+	if !strings.Contains(text, ".atk 95%") || strings.Contains(text, ".atk 90%") {
+		t.Error("atk is not won by b", text)
+	}
+	conflicts := merge.Files[0].Conflicts
+	if len(conflicts) != 1 || conflicts[0].Winner != "b" || !strings.Contains(conflicts[0].Base, ".atk 85%") {
+		t.Fatal("conflicts are", conflicts)
+	}
+
+	// a wins by the override, and the patch is the last of schema
+	patch, patchErr := bx.CreateSchemaHeroPatch(schema.Id, map[string]string{conflicts[0].Id: "a"})
+	if patchErr != nil {
+		t.Fatal(patchErr)
+	}
+	if patch.Id != heroPatchModuleId(schema.Id) || patch.Kind != PatchMod {
+		t.Error("patch is", patch.Id, patch.Kind)
+	}
+	saved, getErr := bx.GetModule(patch.Id)
+	if getErr != nil {
+		t.Fatal(getErr)
+	}
+	p, readErr := os.ReadFile(filepath.Join(bx.moduleFS.Path(), saved.Id, saved.Version.String(), filepath.FromSlash(filename)))
+	if readErr != nil {
+		t.Fatal(readErr)
+	}
+	t.Log(string(p))
+	if !strings.Contains(string(p), ".atk 90%") || strings.Contains(string(p), ".atk 95%") {
+		t.Error("atk of patch is not won by a", string(p))
+	}
+	modules, listErr := bx.ListSchemaModules(schema.Id)
+	if listErr != nil {
+		t.Fatal(listErr)
+	}
+	if len(modules) != 3 || modules[2].ModId != patch.Id {
+		t.Error("patch is not the last of schema", modules)
+	}
+}
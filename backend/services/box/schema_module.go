@@ -0,0 +1,135 @@
+package box
+
+import (
+	"fmt"
+
+	"DarkestDungeonModBoxLite/backend/pkg/databases"
+	"DarkestDungeonModBoxLite/backend/pkg/failure"
+)
+
+func (bx *Box) AddSchemaModule(id string, modId string) (err error) {
+	var (
+		db      *databases.Database
+		modules []SchemaModule
+	)
+	if db, err = bx.database(); err != nil {
+		return
+	}
+	if _, err = bx.GetSchema(id); err != nil {
+		return
+	}
+	if _, err = bx.GetModule(modId); err != nil {
+		return
+	}
+	if modules, err = listSchemaModules(db, id); err != nil {
+		err = failure.Failed("方案", fmt.Sprintf("获取 %s 的模组列表失败", id)).Wrap(err)
+		return
+	}
+	for _, module := range modules {
+		if module.ModId == modId {
+			err = failure.Failed("方案", fmt.Sprintf("%s 已在方案中", modId))
+			return
+		}
+	}
+	module := SchemaModule{
+		PlanId: id,
+		ModId:  modId,
+		Index:  uint(len(modules)),
+	}
+	if err = db.Update(module.Key(), module); err != nil {
+		err = failure.Failed("方案", fmt.Sprintf("添加 %s 失败", modId)).Wrap(err)
+		return
+	}
+	return
+}
+
+func (bx *Box) RemoveSchemaModule(id string, modId string) (err error) {
+	var (
+		db      *databases.Database
+		modules []SchemaModule
+	)
+	if db, err = bx.database(); err != nil {
+		return
+	}
+	if modules, err = listSchemaModules(db, id); err != nil {
+		err = failure.Failed("方案", fmt.Sprintf("获取 %s 的模组列表失败", id)).Wrap(err)
+		return
+	}
+	removed := false
+	remains := make([]SchemaModule, 0, len(modules))
+	for _, module := range modules {
+		if module.ModId == modId {
+			removed = true
+			continue
+		}
+		remains = append(remains, module)
+	}
+	if !removed {
+		err = failure.Failed("方案", fmt.Sprintf("%s 不在方案中", modId))
+		return
+	}
+	err = db.Transaction(func(tx *databases.Tx) (err error) {
+		if err = tx.Remove((&SchemaModule{PlanId: id, ModId: modId}).Key()); err != nil {
+			return
+		}
+		err = updateSchemaModuleIndexes(tx, remains)
+		return
+	})
+	if err != nil {
+		err = failure.Failed("方案", fmt.Sprintf("移除 %s 失败", modId)).Wrap(err)
+		return
+	}
+	return
+}
+
+// ReorderSchemaModules
+// modIds must contain every module of the schema, the load order follows modIds.
+func (bx *Box) ReorderSchemaModules(id string, modIds []string) (err error) {
+	var (
+		db      *databases.Database
+		modules []SchemaModule
+	)
+	if db, err = bx.database(); err != nil {
+		return
+	}
+	if modules, err = listSchemaModules(db, id); err != nil {
+		err = failure.Failed("方案", fmt.Sprintf("获取 %s 的模组列表失败", id)).Wrap(err)
+		return
+	}
+	if len(modIds) != len(modules) {
+		err = failure.Failed("方案", "排序的模组与方案中的模组不一致")
+		return
+	}
+	dict := make(map[string]SchemaModule, len(modules))
+	for _, module := range modules {
+		dict[module.ModId] = module
+	}
+	ordered := make([]SchemaModule, 0, len(modules))
+	for _, modId := range modIds {
+		module, has := dict[modId]
+		if !has {
+			err = failure.Failed("方案", fmt.Sprintf("%s 不在方案中", modId))
+			return
+		}
+		delete(dict, modId)
+		ordered = append(ordered, module)
+	}
+	err = db.Transaction(func(tx *databases.Tx) error {
+		return updateSchemaModuleIndexes(tx, ordered)
+	})
+	if err != nil {
+		err = failure.Failed("方案", "保存排序失败").Wrap(err)
+		return
+	}
+	return
+}
+
+func updateSchemaModuleIndexes(tx *databases.Tx, modules []SchemaModule) (err error) {
+	for i, module := range modules {
+		module.Index = uint(i)
+		if err = tx.Update(module.Key(), module); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,64 @@
+package box
+
+import (
+	"fmt"
+	"os"
+	"path"
+
+	"DarkestDungeonModBoxLite/backend/pkg/archives"
+	"DarkestDungeonModBoxLite/backend/pkg/failure"
+)
+
+// PackSchema packs modules of schema into filename, which is .zip or .7z,
+// dirs of modules are the same as deployed ones, and merged files are packed too,
+// so the archive can be extracted into mods dir of the game directly.
+func (bx *Box) PackSchema(id string, filename string, password string) (err error) {
+	var (
+		schema   *Schema
+		sources  []schemaModuleSource
+		settings Settings
+	)
+	if schema, err = bx.GetSchema(id); err != nil {
+		return
+	}
+	if err = checkPackFilename(filename); err != nil {
+		return
+	}
+	if settings, err = bx.Settings(); err != nil {
+		return
+	}
+	if sources, err = bx.listSchemaModuleSources(id); err != nil {
+		return
+	}
+	if len(sources) == 0 {
+		err = failure.Failed("打包", fmt.Sprintf("%s 没有模组", schema.Name))
+		return
+	}
+	entries := make([]archives.PackEntry, 0, 1)
+	for i, source := range sources {
+		moduleEntries, entriesErr := archives.DirEntries(os.DirFS(source.dir(bx.moduleFS)), deployedModuleDir(i, source.module.Id))
+		if entriesErr != nil {
+			err = failure.Failed("打包", fmt.Sprintf("读取 %s 失败", source.module.Title)).Wrap(entriesErr)
+			return
+		}
+		entries = append(entries, moduleEntries...)
+	}
+	merged, mergedErr := bx.mergeDeployFiles(sources, settings.Game)
+	if mergedErr != nil {
+		err = failure.Failed("打包", "合并文件失败").Wrap(mergedErr)
+		return
+	}
+	if len(merged) > 0 {
+		writes, writesErr := mergedModuleFiles(merged)
+		if writesErr != nil {
+			err = failure.Failed("打包", "合并文件失败").Wrap(writesErr)
+			return
+		}
+		dir := deployedModuleDir(len(sources), deployedMergedId)
+		for name, content := range writes {
+			entries = append(entries, contentEntry(path.Join(dir, name), content))
+		}
+	}
+	err = bx.pack(filename, entries, password)
+	return
+}
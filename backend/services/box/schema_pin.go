@@ -0,0 +1,134 @@
+package box
+
+import (
+	"fmt"
+	"slices"
+
+	"DarkestDungeonModBoxLite/backend/pkg/databases"
+	"DarkestDungeonModBoxLite/backend/pkg/failure"
+)
+
+// PinSchemaModule pins module of schema to version, it is unpinned when version is nil.
+func (bx *Box) PinSchemaModule(id string, modId string, version *Version) (err error) {
+	var (
+		db     *databases.Database
+		module *Module
+	)
+	if db, err = bx.database(); err != nil {
+		return
+	}
+	schemaModule := SchemaModule{PlanId: id, ModId: modId}
+	has, getErr := db.Get(schemaModule.Key(), &schemaModule)
+	if getErr != nil {
+		err = failure.Failed("方案", fmt.Sprintf("获取 %s 失败", modId)).Wrap(getErr)
+		return
+	}
+	if !has {
+		err = failure.Failed("方案", fmt.Sprintf("%s 不在方案中", modId))
+		return
+	}
+	if version != nil {
+		if module, err = bx.GetModule(modId); err != nil {
+			return
+		}
+		if _, ok := module.ExistVersion(*version); !ok {
+			err = failure.Failed("方案", fmt.Sprintf("%s 不存在版本 %s", module.Title, version.String()))
+			return
+		}
+	}
+	schemaModule.Version = version
+	if err = db.Update(schemaModule.Key(), schemaModule); err != nil {
+		err = failure.Failed("方案", fmt.Sprintf("保存 %s 失败", modId)).Wrap(err)
+		return
+	}
+	return
+}
+
+type ModuleChangelog struct {
+	Version       Version `json:"version"`
+	UpdateDetails string  `json:"updateDetails"`
+}
+
+// SchemaModuleUpgrade
+// a pinned module which has newer versions, changelogs are newer versions in ascending order.
+type SchemaModuleUpgrade struct {
+	ModId      string            `json:"modId"`
+	Title      string            `json:"title"`
+	Pinned     Version           `json:"pinned"`
+	Latest     Version           `json:"latest"`
+	Changelogs []ModuleChangelog `json:"changelogs"`
+}
+
+// ListSchemaModuleUpgrades returns pinned modules of schema which have newer versions, as warnings before deploying.
+func (bx *Box) ListSchemaModuleUpgrades(id string) (upgrades []SchemaModuleUpgrade, err error) {
+	var (
+		sources []schemaModuleSource
+	)
+	if sources, err = bx.listSchemaModuleSources(id); err != nil {
+		return
+	}
+	for _, source := range sources {
+		if source.Version == nil || source.version.Compare(source.module.Version) >= 0 {
+			continue
+		}
+		upgrade := SchemaModuleUpgrade{
+			ModId:  source.module.Id,
+			Title:  source.module.Title,
+			Pinned: source.version,
+			Latest: source.module.Version,
+		}
+		for _, vm := range source.module.Versions {
+			if vm.Version.Compare(source.version) > 0 {
+				upgrade.Changelogs = append(upgrade.Changelogs, ModuleChangelog{
+					Version:       vm.Version,
+					UpdateDetails: vm.UpdateDetails,
+				})
+			}
+		}
+		upgrades = append(upgrades, upgrade)
+	}
+	return
+}
+
+// UpgradeSchemaModulePins pins modules of schema to their latest versions, all outdated pins when modIds is empty.
+func (bx *Box) UpgradeSchemaModulePins(id string, modIds []string) (err error) {
+	var (
+		db       *databases.Database
+		sources  []schemaModuleSource
+		upgrades []SchemaModule
+	)
+	if db, err = bx.database(); err != nil {
+		return
+	}
+	if sources, err = bx.listSchemaModuleSources(id); err != nil {
+		return
+	}
+	for _, source := range sources {
+		if source.Version == nil || source.version.Compare(source.module.Version) >= 0 {
+			continue
+		}
+		if len(modIds) > 0 && !slices.Contains(modIds, source.ModId) {
+			continue
+		}
+		latest := source.module.Version
+		schemaModule := source.SchemaModule
+		schemaModule.Version = &latest
+		upgrades = append(upgrades, schemaModule)
+	}
+	if len(upgrades) == 0 {
+		return
+	}
+	err = db.Transaction(func(tx *databases.Tx) (err error) {
+		for _, schemaModule := range upgrades {
+			if err = tx.Update(schemaModule.Key(), schemaModule); err != nil {
+				return
+			}
+		}
+		return
+	})
+	if err != nil {
+		err = failure.Failed("方案", "升级模组版本失败").Wrap(err)
+		return
+	}
+	return
+}
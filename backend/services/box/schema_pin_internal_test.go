@@ -0,0 +1,122 @@
+package box
+
+import (
+	"testing"
+)
+
+// newTestPinnedSchema saves a of v1-v4 pinned to v2, b of v1-v2 pinned to v1, and c of v1-v2 unpinned.
+func newTestPinnedSchema(t *testing.T, bx *Box) (schema *Schema) {
+	var err error
+	if schema, err = bx.CreateSchema("pins"); err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range []struct {
+		id       string
+		versions []uint
+		pin      uint
+	}{
+		{"a", []uint{4, 1, 3, 2}, 2},
+		{"b", []uint{2, 1}, 1},
+		{"c", []uint{1, 2}, 0},
+	} {
+		module := &Module{Id: item.id, Title: item.id}
+		for _, major := range item.versions {
+			module.Add(VersionedModule{Version: Version{Major: major}, UpdateDetails: item.id + Version{Major: major}.String()})
+		}
+		if err = bx.db.Update(moduleKey(item.id), module); err != nil {
+			t.Fatal(err)
+		}
+		if err = bx.AddSchemaModule(schema.Id, item.id); err != nil {
+			t.Fatal(err)
+		}
+		if item.pin > 0 {
+			if err = bx.PinSchemaModule(schema.Id, item.id, &Version{Major: item.pin}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return
+}
+
+func TestBox_PinSchemaModule(t *testing.T) {
+	bx := newTestBox(t)
+	schema := newTestPinnedSchema(t, bx)
+	err := bx.PinSchemaModule(schema.Id, "a", &Version{Major: 5})
+	t.Log(err)
+	if err == nil {
+		t.Error("missing version is pinned")
+	}
+	if err = bx.PinSchemaModule(schema.Id, "missing", nil); err == nil {
+		t.Error("module not in schema is pinned")
+	}
+	if err = bx.PinSchemaModule(schema.Id, "a", nil); err != nil {
+		t.Fatal(err)
+	}
+	modules, listErr := bx.ListSchemaModules(schema.Id)
+	if listErr != nil {
+		t.Fatal(listErr)
+	}
+	if len(modules) != 3 || modules[0].ModId != "a" || modules[0].Version != nil {
+		t.Error("a is not unpinned", modules)
+	}
+}
+
+func TestBox_ListSchemaModuleUpgrades(t *testing.T) {
+	bx := newTestBox(t)
+	schema := newTestPinnedSchema(t, bx)
+	upgrades, err := bx.ListSchemaModuleUpgrades(schema.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(upgrades)
+	if len(upgrades) != 2 || upgrades[0].ModId != "a" || upgrades[1].ModId != "b" {
+		t.Fatal("upgrades are", upgrades)
+	}
+	a := upgrades[0]
+	if a.Pinned.Major != 2 || a.Latest.Major != 4 || len(a.Changelogs) != 2 {
+		t.Fatal("upgrade of a is", a)
+	}
+	for i, major := range []uint{3, 4} {
+		if changelog := a.Changelogs[i]; changelog.Version.Major != major || changelog.UpdateDetails != "a"+changelog.Version.String() {
+			t.Error("changelog", i, "is", changelog)
+		}
+	}
+	if b := upgrades[1]; len(b.Changelogs) != 1 || b.Changelogs[0].Version.Major != 2 {
+		t.Error("upgrade of b is", b)
+	}
+}
+
+func TestBox_UpgradeSchemaModulePins(t *testing.T) {
+	bx := newTestBox(t)
+	schema := newTestPinnedSchema(t, bx)
+	pins := func() map[string]uint {
+		modules, err := bx.ListSchemaModules(schema.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pinned := make(map[string]uint)
+		for _, module := range modules {
+			if module.Version != nil {
+				pinned[module.ModId] = module.Version.Major
+			}
+		}
+		return pinned
+	}
+	// only listed modules are upgraded
+	if err := bx.UpgradeSchemaModulePins(schema.Id, []string{"b"}); err != nil {
+		t.Fatal(err)
+	}
+	if pinned := pins(); len(pinned) != 2 || pinned["a"] != 2 || pinned["b"] != 2 {
+		t.Error("pins are", pinned)
+	}
+	// all outdated pins are upgraded, unpinned ones are kept unpinned
+	if err := bx.UpgradeSchemaModulePins(schema.Id, nil); err != nil {
+		t.Fatal(err)
+	}
+	if pinned := pins(); len(pinned) != 2 || pinned["a"] != 4 || pinned["b"] != 2 {
+		t.Error("pins are", pinned)
+	}
+	if upgrades, err := bx.ListSchemaModuleUpgrades(schema.Id); err != nil || len(upgrades) != 0 {
+		t.Error("upgrades are", upgrades, err)
+	}
+}
@@ -0,0 +1,120 @@
+package box
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"DarkestDungeonModBoxLite/backend/pkg/databases"
+	"DarkestDungeonModBoxLite/backend/pkg/failure"
+)
+
+func (bx *Box) CreateSchema(name string) (schema *Schema, err error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		err = failure.Failed("方案", "名称不能为空")
+		return
+	}
+	var (
+		db *databases.Database
+	)
+	if db, err = bx.database(); err != nil {
+		return
+	}
+	if err = bx.checkSchemaName("", name); err != nil {
+		return
+	}
+	schema = &Schema{
+		Id:       Id(),
+		Name:     name,
+		Deployed: false,
+		CreateAT: time.Now(),
+	}
+	if err = db.Update(schemaKey(schema.Id), schema); err != nil {
+		schema = nil
+		err = failure.Failed("方案", fmt.Sprintf("保存 %s 失败", name)).Wrap(err)
+		return
+	}
+	return
+}
+
+func (bx *Box) RenameSchema(id string, name string) (err error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		err = failure.Failed("方案", "名称不能为空")
+		return
+	}
+	var (
+		db     *databases.Database
+		schema *Schema
+	)
+	if db, err = bx.database(); err != nil {
+		return
+	}
+	if schema, err = bx.GetSchema(id); err != nil {
+		return
+	}
+	if schema.Name == name {
+		return
+	}
+	if err = bx.checkSchemaName(id, name); err != nil {
+		return
+	}
+	schema.Name = name
+	if err = db.Update(schemaKey(schema.Id), schema); err != nil {
+		err = failure.Failed("方案", fmt.Sprintf("保存 %s 失败", name)).Wrap(err)
+		return
+	}
+	return
+}
+
+func (bx *Box) DeleteSchema(id string) (err error) {
+	var (
+		db      *databases.Database
+		schema  *Schema
+		modules []SchemaModule
+	)
+	if db, err = bx.database(); err != nil {
+		return
+	}
+	if schema, err = bx.GetSchema(id); err != nil {
+		return
+	}
+	if schema.Deployed {
+		err = failure.Failed("方案", fmt.Sprintf("%s 已部署，请先取消部署", schema.Name))
+		return
+	}
+	if modules, err = listSchemaModules(db, id); err != nil {
+		err = failure.Failed("方案", fmt.Sprintf("删除 %s 失败", id)).Wrap(err)
+		return
+	}
+	err = db.Transaction(func(tx *databases.Tx) (err error) {
+		for _, module := range modules {
+			if err = tx.Remove(module.Key()); err != nil {
+				return
+			}
+		}
+		err = tx.Remove(schemaKey(id))
+		return
+	})
+	if err != nil {
+		err = failure.Failed("方案", fmt.Sprintf("删除 %s 失败", id)).Wrap(err)
+		return
+	}
+	return
+}
+
+func (bx *Box) checkSchemaName(id string, name string) (err error) {
+	schemas, listErr := bx.ListSchemas()
+	if listErr != nil {
+		err = listErr
+		return
+	}
+	for _, schema := range schemas {
+		if schema.Id != id && schema.Name == name {
+			err = failure.Failed("方案", fmt.Sprintf("%s 已存在", name))
+			return
+		}
+	}
+	return
+}
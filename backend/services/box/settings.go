@@ -37,10 +37,10 @@ func (bx *Box) Settings() (v Settings, err error) {
 		return
 	}
 	if v.Game == "" && v.Workshop == "" {
-		game, steam, ok := getGameFromSteam()
+		game, ok := getGameFromSteam()
 		if ok {
-			v.Game = game
-			v.Workshop = filepath.Join(steam, "steamapps", "workshop", "content", "262060")
+			v.Game = game.Dir
+			v.Workshop = game.Workshop
 			_ = bx.UpdateSettings(v)
 		}
 	}
@@ -61,15 +61,23 @@ func (bx *Box) UpdateSettings(v Settings) (err error) {
 	return
 }
 
-func getGameFromSteam() (game string, steam string, ok bool) {
-	steam, _ = programs.FindSteam()
-	if steam == "" {
+func getGameFromSteam() (game programs.Game, ok bool) {
+	var err error
+	if game, err = programs.FindDarkestDungeon(); err != nil {
 		return
 	}
-	game = filepath.Join(steam, "steamapps", "common", "DarkestDungeon")
-	if gameExist, _ := files.Exist(game); !gameExist {
+	if gameExist, _ := files.Exist(game.Dir); !gameExist {
 		return
 	}
 	ok = true
 	return
 }
+
+// GetSteamGame returns the game installed by steam, with its build id.
+func (bx *Box) GetSteamGame() (v programs.Game, err error) {
+	if v, err = programs.FindDarkestDungeon(); err != nil {
+		err = failure.Failed("获取游戏信息失败", "未找到 Steam 安装的游戏").Wrap(err)
+		return
+	}
+	return
+}
@@ -0,0 +1,44 @@
+package box
+
+import (
+	"fmt"
+
+	"DarkestDungeonModBoxLite/backend/pkg/failure"
+	"DarkestDungeonModBoxLite/backend/pkg/tasks"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+const (
+	// tasksEvent every task info is emitted to "tasks" and "tasks:{pid}"
+	tasksEvent = "tasks"
+
+	workshopSyncTaskName = "workshop:sync"
+	importTaskName       = "import"
+	deployTaskName       = "deploy"
+	gameScanTaskName     = "game:scan"
+	packTaskName         = "pack"
+)
+
+func (bx *Box) emitTask(info tasks.Info) {
+	if bx.ctx == nil {
+		return
+	}
+	runtime.EventsEmit(bx.ctx, tasksEvent, info)
+	runtime.EventsEmit(bx.ctx, fmt.Sprintf("%s:%s", tasksEvent, info.Id), info)
+}
+
+func (bx *Box) ListTasks() (v []tasks.Info, err error) {
+	v = bx.processes.List()
+	return
+}
+
+func (bx *Box) GetTask(pid string) (v tasks.Info, err error) {
+	info, ok := bx.processes.Get(pid)
+	if !ok {
+		err = failure.Failed("任务", fmt.Sprintf("%s 不存在或已结束", pid))
+		return
+	}
+	v = info
+	return
+}
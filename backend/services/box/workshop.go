@@ -1,45 +1,201 @@
 package box
 
 import (
+	"context"
 	"encoding/xml"
 	"fmt"
 	"os"
 	"path/filepath"
-	"slices"
-	"sort"
+	"strings"
 	"time"
 
 	"DarkestDungeonModBoxLite/backend/pkg/databases"
 	"DarkestDungeonModBoxLite/backend/pkg/failure"
 	"DarkestDungeonModBoxLite/backend/pkg/files"
+	"DarkestDungeonModBoxLite/backend/pkg/programs"
+	"DarkestDungeonModBoxLite/backend/pkg/tasks"
 )
 
 func (bx *Box) SyncWorkshopMods() (pid string, err error) {
-	// todo get settings > compare mods > start task
+	bx.syncing.Lock()
+	defer bx.syncing.Unlock()
+	if bx.syncPid != "" && bx.processes.Running(bx.syncPid) {
+		pid = bx.syncPid
+		return
+	}
+	items, scanErr := bx.scanWorkshop()
+	if scanErr != nil {
+		err = scanErr
+		return
+	}
+	task := &workshopSyncTask{
+		bx:    bx,
+		items: make([]workshopItem, 0, len(items)),
+	}
+	for _, item := range items {
+		synced, updated, syncedErr := bx.workshopItemSynced(item)
+		if syncedErr != nil {
+			err = syncedErr
+			return
+		}
+		if !synced || updated {
+			task.items = append(task.items, item)
+		}
+	}
+	pid = bx.processes.Execute(bx.ctx, workshopSyncTaskName, task)
+	bx.syncPid = pid
 	return
 }
 
 func (bx *Box) StopSyncWorkshopMods(pid string) (err error) {
-
+	if !bx.processes.Cancel(pid) {
+		err = failure.Failed("工坊", "同步任务不存在或已结束")
+		return
+	}
 	return
 }
 
-type WorkshopModule struct {
-	Id      string   `json:"id"`
-	Title   string   `json:"title"`
-	Icon    string   `json:"icon"`
-	Synced  bool     `json:"synced"`
-	Version Version  `json:"version"`
-	Tags    []string `json:"tags"`
+type workshopItem struct {
+	id      string
+	dir     *files.DirFS
+	project ModuleProject
+	steam   programs.WorkshopItem
 }
 
-func (bx *Box) ListWorkshopModules() (v []WorkshopModule, err error) {
+type workshopSyncTask struct {
+	bx    *Box
+	items []workshopItem
+}
+
+// Handle syncs items one by one, a failed item does not stop others, failed items are returned at last.
+func (task *workshopSyncTask) Handle(ctx context.Context, progress *tasks.Progress) (err error) {
+	var failed failure.Failures
+	for _, item := range task.items {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		progress.Step(fmt.Sprintf("同步 %s", item.project.Title))
+		if syncErr := task.bx.syncWorkshopItem(ctx, item, progress); syncErr != nil {
+			if err = ctx.Err(); err != nil {
+				return
+			}
+			if failed == nil {
+				failed = failure.Failed("工坊", "部分模组同步失败")
+			}
+			failed = failed.Append(item.id, item.project.Title).Wrap(syncErr)
+		}
+	}
+	if len(failed) > 0 {
+		err = failed
+	}
+	return
+}
+
+// syncWorkshopItem imports item as a new version of module, nothing is left in mods when it is canceled.
+func (bx *Box) syncWorkshopItem(ctx context.Context, item workshopItem, progress *tasks.Progress) (err error) {
 	var (
-		db *databases.Database
+		db    *databases.Database
+		plan  *ImportPlan
+		stage *ImportStage
 	)
 	if db, err = bx.database(); err != nil {
 		return
 	}
+	if plan, err = MakeModuleImportPlanByDir(ctx, MakeModuleImportPlanParam{Filename: item.dir.Path()}); err != nil {
+		return
+	}
+	plan.IsDir = true
+	modulePlan := &plan.Modules[0]
+	modulePlan.PublishFileId = item.id
+	// an updated item of the same version overrides it
+	if err = bx.resolveModulePlanDst(modulePlan); err != nil {
+		return
+	}
+	modulePlan.Existed = modulePlan.Dst != nil
+	if stage, err = StageModulesByDir(ctx, bx.moduleFS, plan, progress); err != nil {
+		return
+	}
+	if err = ctx.Err(); err != nil {
+		stage.Rollback()
+		return
+	}
+	// remember when steam updated it in the same commit, so that later updates are detected
+	for _, entry := range stage.entries {
+		entry.workshopUpdateAT = item.steam.TimeUpdated
+	}
+	if _, err = stage.Commit(db); err != nil {
+		stage.Rollback()
+		return
+	}
+	return
+}
+
+// workshopItemSynced
+// synced means the version of item is imported, updated means steam updated item after last sync.
+func (bx *Box) workshopItemSynced(item workshopItem) (synced bool, updated bool, err error) {
+	exists, existsErr := bx.ExistsModule(item.id)
+	if existsErr != nil {
+		err = existsErr
+		return
+	}
+	if !exists {
+		return
+	}
+	module, moduleErr := bx.GetModule(item.id)
+	if moduleErr != nil {
+		err = moduleErr
+		return
+	}
+	version, _ := item.project.Version()
+	if _, synced = module.ExistVersion(version); !synced || item.steam.TimeUpdated.IsZero() {
+		return
+	}
+	syncAT := module.WorkshopUpdateAT
+	if syncAT.IsZero() {
+		// imported before sync time was kept, or from archives and dirs, then the version dir tells when it was imported
+		if bx.moduleFS != nil {
+			if stat, statErr := os.Stat(filepath.Join(bx.moduleFS.Path(), module.Id, version.String())); statErr == nil {
+				syncAT = stat.ModTime()
+			}
+		}
+	}
+	// unknown sync time is taken as possibly updated
+	updated = syncAT.IsZero() || item.steam.TimeUpdated.After(syncAT)
+	return
+}
+
+// markWorkshopUpdateAT remembers when steam updated the workshop items of modules, which are imported from source,
+// only items imported from their dirs in workshop are marked, so later updates are detected by sync.
+func (bx *Box) markWorkshopUpdateAT(db *databases.Database, source string, modules []*Module) (err error) {
+	settings, settingsErr := bx.Settings()
+	if settingsErr != nil || settings.Workshop == "" {
+		return
+	}
+	if !strings.EqualFold(filepath.Clean(filepath.Dir(source)), filepath.Clean(settings.Workshop)) {
+		return
+	}
+	manifest, manifestErr := programs.ReadWorkshopManifest(programs.WorkshopManifestFilename(settings.Workshop))
+	if manifestErr != nil {
+		return
+	}
+	item, has := manifest.Items[filepath.Base(source)]
+	if !has || item.TimeUpdated.IsZero() {
+		return
+	}
+	for _, module := range modules {
+		if module.PublishId != item.Id && module.PublishId != filepath.Base(source) {
+			continue
+		}
+		module.WorkshopUpdateAT = item.TimeUpdated
+		if err = db.Update(moduleKey(module.Id), module); err != nil {
+			err = failure.Failed("工坊", fmt.Sprintf("保存 %s 失败", module.Id)).Wrap(err)
+			return
+		}
+	}
+	return
+}
+
+func (bx *Box) scanWorkshop() (items []workshopItem, err error) {
 	settings, settingsErr := bx.Settings()
 	if settingsErr != nil {
 		err = failure.Failed("工坊", "扫描本地存储错误").Wrap(settingsErr)
@@ -53,20 +209,12 @@ func (bx *Box) ListWorkshopModules() (v []WorkshopModule, err error) {
 		err = failure.Failed("工坊", "加载工坊失败").Wrap(dirErr)
 		return
 	}
+	manifest, _ := programs.ReadWorkshopManifest(programs.WorkshopManifestFilename(settings.Workshop))
 	entries, entriesErr := dir.ListDir()
 	if entriesErr != nil {
 		err = failure.Failed("工坊", "加载工坊失败").Wrap(entriesErr)
 		return
 	}
-	if len(entries) == 0 {
-		return
-	}
-	// list mods
-	locals, _ := bx.moduleFS.ListDir()
-	if len(locals) > 0 {
-		sort.Strings(locals)
-	}
-
 	for _, entry := range entries {
 		sub := dir.Dir(entry)
 		projectBytes, readProjectErr := sub.ReadFile("project.xml")
@@ -86,23 +234,69 @@ func (bx *Box) ListWorkshopModules() (v []WorkshopModule, err error) {
 			err = failure.Failed("工坊", fmt.Sprintf("读取 %s 错误", entry)).Append("解析 project.xml 失败", projectErr.Error())
 			return
 		}
-		_, found := slices.BinarySearch[[]string](locals, project.PublishedFileId)
+		id := strings.TrimSpace(project.PublishedFileId)
+		if id == "" {
+			id = entry
+		}
+		items = append(items, workshopItem{
+			id:      id,
+			dir:     sub,
+			project: project,
+			steam:   manifest.Items[entry],
+		})
+	}
+	return
+}
+
+type WorkshopModule struct {
+	Id       string    `json:"id"`
+	Title    string    `json:"title"`
+	Icon     string    `json:"icon"`
+	Synced   bool      `json:"synced"`
+	Updated  bool      `json:"updated"`
+	UpdateAT time.Time `json:"updateAT"`
+	Size     int64     `json:"size"`
+	Version  Version   `json:"version"`
+	Tags     []string  `json:"tags"`
+}
+
+func (bx *Box) ListWorkshopModules() (v []WorkshopModule, err error) {
+	var (
+		db *databases.Database
+	)
+	if db, err = bx.database(); err != nil {
+		return
+	}
+	items, scanErr := bx.scanWorkshop()
+	if scanErr != nil {
+		err = scanErr
+		return
+	}
+	for _, item := range items {
+		synced, updated, syncedErr := bx.workshopItemSynced(item)
+		if syncedErr != nil {
+			err = failure.Failed("工坊", fmt.Sprintf("读取 %s 错误", item.id)).Wrap(syncedErr)
+			return
+		}
 
-		icon := project.PreviewIconFile
+		icon := item.project.PreviewIconFile
 		if icon != "" {
-			icon = filepath.Join(sub.Path(), icon)
+			icon = filepath.Join(item.dir.Path(), icon)
 			_, _ = db.GetImage(icon, 15*24*time.Hour)
 		}
 
-		version, _ := project.Version()
+		version, _ := item.project.Version()
 
 		v = append(v, WorkshopModule{
-			Id:      project.PublishedFileId,
-			Title:   project.Title,
-			Icon:    icon,
-			Synced:  found,
-			Version: version,
-			Tags:    project.ListTags(),
+			Id:       item.id,
+			Title:    item.project.Title,
+			Icon:     icon,
+			Synced:   synced,
+			Updated:  updated,
+			UpdateAT: item.steam.TimeUpdated,
+			Size:     item.steam.Size,
+			Version:  version,
+			Tags:     item.project.ListTags(),
 		})
 	}
 	return
@@ -0,0 +1,198 @@
+package box
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"DarkestDungeonModBoxLite/backend/pkg/files"
+	"DarkestDungeonModBoxLite/backend/pkg/programs"
+	"DarkestDungeonModBoxLite/backend/pkg/tasks"
+)
+
+func TestBox_workshopItemSynced(t *testing.T) {
+	bx := newTestBox(t)
+	steamAT := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	version := Version{Major: 1}
+	cases := []struct {
+		name     string
+		syncAT   time.Time
+		dirAT    time.Time
+		versions []Version
+		synced   bool
+		updated  bool
+	}{
+		{"not synced", time.Time{}, time.Time{}, []Version{{Major: 2}}, false, false},
+		{"synced before update", steamAT.Add(-time.Hour), time.Time{}, []Version{version}, true, true},
+		{"synced after update", steamAT, time.Time{}, []Version{version}, true, false},
+		{"imported before update", time.Time{}, steamAT.Add(-time.Hour), []Version{version}, true, true},
+		{"imported after update", time.Time{}, steamAT.Add(time.Hour), []Version{version}, true, false},
+		{"unknown", time.Time{}, time.Time{}, []Version{version}, true, true},
+	}
+	for i, c := range cases {
+		id := string(rune('a' + i))
+		module := &Module{Id: id, PublishId: id, WorkshopUpdateAT: c.syncAT}
+		for _, v := range c.versions {
+			module.Add(VersionedModule{Version: v})
+		}
+		if err := bx.db.Update(moduleKey(id), module); err != nil {
+			t.Fatal(err)
+		}
+		if !c.dirAT.IsZero() {
+			dir := filepath.Join(bx.moduleFS.Path(), id, version.String())
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(dir, c.dirAT, c.dirAT); err != nil {
+				t.Fatal(err)
+			}
+		}
+		item := workshopItem{
+			id:      id,
+			project: ModuleProject{VersionMajor: "1"},
+			steam:   programs.WorkshopItem{Id: id, TimeUpdated: steamAT},
+		}
+		synced, updated, err := bx.workshopItemSynced(item)
+		if err != nil {
+			t.Fatal(c.name, err)
+		}
+		if synced != c.synced || updated != c.updated {
+			t.Error(c.name, "synced", synced, "updated", updated)
+		}
+	}
+}
+
+func TestBox_markWorkshopUpdateAT(t *testing.T) {
+	bx := newTestBox(t)
+	workshop := filepath.Join(t.TempDir(), "steamapps", "workshop", "content", "262060")
+	if err := os.MkdirAll(filepath.Join(workshop, "2936390129"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	manifest := `"AppWorkshop"
+{
+	"appid"		"262060"
+	"WorkshopItemsInstalled"
+	{
+		"2936390129"
+		{
+			"timeupdated"		"1690000000"
+		}
+	}
+}`
+	if err := os.WriteFile(programs.WorkshopManifestFilename(workshop), []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := bx.UpdateSettings(Settings{Game: t.TempDir(), Workshop: workshop}); err != nil {
+		t.Fatal(err)
+	}
+	module := &Module{Id: "2936390129", PublishId: "2936390129"}
+	// imported from other dirs
+	if err := bx.markWorkshopUpdateAT(bx.db, filepath.Join(t.TempDir(), "2936390129"), []*Module{module}); err != nil {
+		t.Fatal(err)
+	}
+	if !module.WorkshopUpdateAT.IsZero() {
+		t.Error("module imported from other dir is marked")
+	}
+	// imported from the workshop dir
+	if err := bx.markWorkshopUpdateAT(bx.db, filepath.Join(workshop, "2936390129"), []*Module{module}); err != nil {
+		t.Fatal(err)
+	}
+	saved, getErr := bx.GetModule(module.Id)
+	if getErr != nil {
+		t.Fatal(getErr)
+	}
+	if !saved.WorkshopUpdateAT.Equal(time.Unix(1690000000, 0)) {
+		t.Error("workshop update at is", saved.WorkshopUpdateAT)
+	}
+}
+
+// newTestWorkshopItem writes a workshop item of version 1.0 into workshop, it has no icon when broken.
+func newTestWorkshopItem(t *testing.T, workshop string, id string, broken bool) (item workshopItem) {
+	dir := filepath.Join(workshop, id)
+	project := "<project><Title>" + id + "</Title><PublishedFileId>" + id + "</PublishedFileId><VersionMajor>1</VersionMajor></project>"
+	writes := map[string][]byte{
+		"project.xml":                           []byte(project),
+		"heroes/crusader/crusader.info.darkest": []byte("hero: .id \"crusader\""),
+	}
+	if !broken {
+		writes["preview_icon.png"] = testPreviewIcon(t)
+	}
+	for name, content := range writes {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sub, subErr := files.NewDirFS(dir)
+	if subErr != nil {
+		t.Fatal(subErr)
+	}
+	item = workshopItem{
+		id:      id,
+		dir:     sub,
+		project: ModuleProject{Title: id, PublishedFileId: id, VersionMajor: "1"},
+		steam:   programs.WorkshopItem{Id: id, TimeUpdated: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)},
+	}
+	return
+}
+
+func TestWorkshopSyncTask_Handle(t *testing.T) {
+	bx := newTestBox(t)
+	workshop := t.TempDir()
+	task := &workshopSyncTask{bx: bx, items: []workshopItem{
+		newTestWorkshopItem(t, workshop, "1001", true),
+		newTestWorkshopItem(t, workshop, "1002", false),
+	}}
+	// a failed item does not stop others
+	_, err := bx.processes.Run(context.Background(), workshopSyncTaskName, task)
+	t.Log(err)
+	if err == nil || !strings.Contains(err.Error(), "1001") {
+		t.Error("failed item is not returned", err)
+	}
+	if exists, _ := bx.ExistsModule("1001"); exists {
+		t.Error("failed item is saved")
+	}
+	module, getErr := bx.GetModule("1002")
+	if getErr != nil {
+		t.Fatal(getErr)
+	}
+	if !module.WorkshopUpdateAT.Equal(task.items[1].steam.TimeUpdated) {
+		t.Error("workshop update at is", module.WorkshopUpdateAT)
+	}
+	if _, statErr := os.Stat(filepath.Join(bx.moduleFS.Path(), "1002", "v1.0.0", "heroes", "crusader", "crusader.info.darkest")); statErr != nil {
+		t.Error("files are not synced", statErr)
+	}
+	if synced, updated, syncedErr := bx.workshopItemSynced(task.items[1]); syncedErr != nil || !synced || updated {
+		t.Error("synced item is", synced, updated, syncedErr)
+	}
+}
+
+func TestWorkshopSyncTask_Handle_Canceled(t *testing.T) {
+	bx := newTestBox(t)
+	task := &workshopSyncTask{bx: bx, items: []workshopItem{newTestWorkshopItem(t, t.TempDir(), "1002", false)}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := bx.processes.Run(ctx, workshopSyncTaskName, task); !errors.Is(err, context.Canceled) {
+		t.Error("canceled task returns", err)
+	}
+	// nothing is left when it is canceled while syncing
+	_, err := bx.processes.Run(ctx, workshopSyncTaskName, tasks.TaskFunc(func(ctx context.Context, progress *tasks.Progress) error {
+		return bx.syncWorkshopItem(ctx, task.items[0], progress)
+	}))
+	if err == nil {
+		t.Error("canceled item is synced")
+	}
+	if exists, _ := bx.ExistsModule("1002"); exists {
+		t.Error("canceled item is saved")
+	}
+	if _, statErr := os.Stat(filepath.Join(bx.moduleFS.Path(), "1002")); statErr == nil {
+		t.Error("canceled item is left in mods")
+	}
+}